@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+// Allocator supplies the memory that a GetBuffer fills when it unpacks byte
+// sequences. Alloc returns a slice of length n. Assign an
+// allocator to a get buffer with its SetAllocator method.
+type Allocator interface {
+	Alloc(n int) []byte
+}
+
+// HeapAllocator is an Allocator that obtains each slice from the garbage
+// collected heap. This is the behavior of a GetBuffer that has no assigned
+// allocator.
+type HeapAllocator struct{}
+
+// Alloc implements the Allocator interface.
+func (HeapAllocator) Alloc(n int) []byte {
+	return make([]byte, n)
+}
+
+const arenaChunkSize = 64 * 1024
+
+// Arena is an Allocator that carves slices out of large chunks of memory. This
+// reduces the number of individual allocations made when many records are
+// decoded. The memory of a chunk is reclaimed by the garbage collector only
+// when none of the slices carved from it are referenced, or it is reused
+// after a call to Reset. The zero value for a variable of type Arena is
+// ready to use.
+type Arena struct {
+	chunk  []byte
+	size   int
+	chunks [][]byte
+	next   int
+	reused bool
+}
+
+// NewArena returns an arena that allocates memory in chunks of the specified
+// size. Requests larger than a quarter of the chunk size are satisfied
+// directly from the heap. A size of zero or less selects a default chunk size
+// of 64 KB.
+func NewArena(chunkSize int) *Arena {
+	return &Arena{size: chunkSize}
+}
+
+// Alloc implements the Allocator interface. The capacity of each returned
+// slice equals its length so that appending to it cannot overwrite memory
+// belonging to another slice.
+func (a *Arena) Alloc(n int) (sl []byte) {
+	if a.size <= 0 {
+		a.size = arenaChunkSize
+	}
+	if n > a.size/4 {
+		return make([]byte, n)
+	}
+	if n > len(a.chunk) {
+		a.reused = a.next < len(a.chunks)
+		if a.reused {
+			a.chunk = a.chunks[a.next]
+		} else {
+			a.chunk = make([]byte, a.size)
+			a.chunks = append(a.chunks, a.chunk)
+		}
+		a.next++
+	}
+	sl = a.chunk[:n:n]
+	a.chunk = a.chunk[n:]
+	if a.reused {
+		for j := range sl {
+			sl[j] = 0
+		}
+	}
+	return
+}
+
+// Reset releases, all at once, every slice that the receiving arena has
+// allocated so that its chunks can be reused by subsequent calls to Alloc.
+// Typically Reset is called after a batch of records has been processed. The
+// contents of previously allocated slices are overwritten by later
+// allocations, so none of them may be used after Reset is called. Strings
+// unpacked by a get buffer are not allocated from the arena and remain valid.
+func (a *Arena) Reset() {
+	a.chunk = nil
+	a.next = 0
+}
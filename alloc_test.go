@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Ensure that byte sequences unpacked into an arena are distinct and intact
+func TestArena(t *testing.T) {
+	var put PutBuffer
+	for j := 0; j < 100; j++ {
+		put.Bytes(bytes.Repeat([]byte{byte(j)}, j))
+	}
+	put.Bytes(make([]byte, 300))
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := NewGetBuffer(data)
+	get.SetAllocator(NewArena(1024))
+	list := make([][]byte, 101)
+	for j := range list {
+		get.Bytes(&list[j])
+	}
+	if err = get.Done(); err != nil {
+		t.Fatal(err)
+	}
+	list[1] = append(list[1], 0xee)
+	for j := 0; j < 100; j++ {
+		if !bytes.Equal(list[j][:j], bytes.Repeat([]byte{byte(j)}, j)) {
+			t.Fatalf("unpacked sequence %d is corrupt", j)
+		}
+	}
+	if len(list[100]) != 300 {
+		t.Fatal("large sequence not unpacked")
+	}
+	if len(HeapAllocator{}.Alloc(12)) != 12 {
+		t.Fatal("heap allocation has wrong length")
+	}
+}
+
+// Ensure that an arena reuses its chunks after a reset
+func TestArena_Reset(t *testing.T) {
+	a := NewArena(64)
+	first := a.Alloc(10)
+	for j := range first {
+		first[j] = 0xaa
+	}
+	a.Alloc(10)
+	a.Alloc(60 / 4)
+	a.Reset()
+	allocs := testing.AllocsPerRun(50, func() {
+		a.Reset()
+		for j := 0; j < 8; j++ {
+			a.Alloc(16)
+		}
+	})
+	if allocs > 0 {
+		t.Fatalf("expecting no allocations, got %.1f", allocs)
+	}
+	a.Reset()
+	sl := a.Alloc(10)
+	if &sl[0] != &first[0] || !bytes.Equal(sl, make([]byte, 10)) {
+		t.Fatal("reused memory not cleared")
+	}
+}
+
+// Ensure that strings are allocated once and not from the arena
+func TestArena_Str(t *testing.T) {
+	var put PutBuffer
+	put.Str("gear and pinion")
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewArena(1024)
+	get := NewGetBuffer(data)
+	get.SetAllocator(a)
+	var str string
+	allocs := testing.AllocsPerRun(50, func() {
+		get.Reset(data)
+		get.Str(&str)
+	})
+	if err = get.Done(); err != nil || str != "gear and pinion" {
+		t.Fatalf("unexpected string %q: %v", str, err)
+	}
+	if allocs != 1 || len(a.chunks) != 0 {
+		t.Fatalf("expecting one allocation outside the arena, got %.1f and %d chunks", allocs, len(a.chunks))
+	}
+	get = NewGetReader(bytes.NewReader(data))
+	get.Str(&str)
+	if err = get.Done(); err != nil || str != "gear and pinion" {
+		t.Fatalf("unexpected string %q from reader: %v", str, err)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+// batchDictMax limits the number of distinct strings held by the dictionary
+// of a batch. Strings encountered after the dictionary is full are packed
+// inline.
+const batchDictMax = 1 << 16
+
+// batchDictMin is the length of the shortest string that is entered in the
+// dictionary of a batch. A reference to a shorter string would save nothing.
+const batchDictMin = 2
+
+// strDict assigns an index to each distinct string packed into a batch.
+type strDict struct {
+	index map[string]uint64
+	list  []string
+}
+
+// lookup returns the index of str, entering it in the dictionary if
+// necessary. The second return value is false if str is to be packed inline.
+func (d *strDict) lookup(str string) (idx uint64, ok bool) {
+	if idx, ok = d.index[str]; !ok && len(str) >= batchDictMin && len(d.list) < batchDictMax {
+		idx, ok = uint64(len(d.list)), true
+		d.index[str] = idx
+		d.list = append(d.list, str)
+	}
+	return
+}
+
+// dictStr assigns the dictionary string with the specified index to str.
+func (get *GetBuffer) dictStr(idx uint64, str *string) {
+	if idx < uint64(len(get.dict)) {
+		*str = get.dict[idx]
+	} else {
+		get.err = categoryErrorf(ErrValueRange, "string index %d exceeds dictionary of %d strings", idx, len(get.dict))
+	}
+}
+
+// BatchWriter packs many records into one batch while sharing a dictionary of
+// strings among them. Each distinct string packed with PutBuffer.Str,
+// including the keys and values of PutBuffer.StrMap, is stored once in the
+// dictionary at the start of the batch, and records refer to it by index.
+// This greatly reduces the size of exports in which the same strings repeat
+// across many records. Records are packed with the usual PutBuffer methods,
+// so generated StorePut methods can be used unchanged. The zero value for a
+// variable of type BatchWriter is ready to use.
+type BatchWriter struct {
+	recs PutBuffer
+	rec  PutBuffer
+	out  PutBuffer
+	dict strDict
+}
+
+// Record calls fn to pack one record into the receiving batch. An error held
+// by the put buffer after fn returns becomes the error of the batch writer,
+// after which further records are ignored.
+func (bw *BatchWriter) Record(fn func(put *PutBuffer)) {
+	if bw.recs.err == nil {
+		if bw.dict.index == nil {
+			bw.dict.index = make(map[string]uint64)
+		}
+		bw.rec.Reset()
+		bw.rec.dict = &bw.dict
+		fn(&bw.rec)
+		if data, err := bw.rec.DataUnsafe(); err == nil {
+			bw.recs.Bytes(data)
+		} else {
+			bw.recs.SetError(err)
+		}
+	}
+}
+
+// Add packs p as the next record of the receiving batch.
+func (bw *BatchWriter) Add(p Putter) {
+	bw.Record(p.StorePut)
+}
+
+// SetError permits the caller to assign an error value to the batch writer.
+// This method unconditionally overwrites the current internal error value.
+func (bw *BatchWriter) SetError(err error) {
+	bw.recs.SetError(err)
+}
+
+// Data returns the packed batch in the form of a byte slice. The second
+// return value is an error code that will be nil if all records have been
+// successfully packed. The returned slice is valid until the next call to
+// Data or Reset.
+func (bw *BatchWriter) Data() ([]byte, error) {
+	bw.out.Reset()
+	bw.out.SetError(bw.recs.err)
+	bw.out.Uint64(uint64(len(bw.dict.list)))
+	for _, str := range bw.dict.list {
+		bw.out.Str(str)
+	}
+	bw.out.Raw(bw.recs.buf)
+	return bw.out.DataUnsafe()
+}
+
+// Reset discards the records and dictionary of the receiving batch writer,
+// and clears its internal error, so that another batch can be packed. Memory
+// allocated for previous batches is reused.
+func (bw *BatchWriter) Reset() {
+	bw.recs.Reset()
+	for str := range bw.dict.index {
+		delete(bw.dict.index, str)
+	}
+	bw.dict.list = bw.dict.list[:0]
+}
+
+// BatchReader extracts records from a byte sequence that was generated using
+// a BatchWriter.
+type BatchReader struct {
+	get *GetBuffer
+	rec GetBuffer
+	buf []byte
+}
+
+// NewBatchReader returns an initialized reader that can be used to extract
+// records from data.
+func NewBatchReader(data []byte) *BatchReader {
+	br := &BatchReader{get: NewGetBuffer(data)}
+	var count uint64
+	br.get.Uint64(&count)
+	if br.get.err == nil && count > uint64(br.get.Remaining()) {
+		br.get.err = categoryErrorf(ErrValueRange, "dictionary of %d strings exceeds batch length", count)
+	}
+	if br.get.err == nil {
+		br.rec.dict = make([]string, count)
+		for j := range br.rec.dict {
+			br.get.Str(&br.rec.dict[j])
+		}
+	}
+	return br
+}
+
+// Next advances the reader to the next record in the batch. It returns false
+// when no records remain or an error has occurred; call Done to distinguish
+// between these cases.
+func (br *BatchReader) Next() bool {
+	get := br.get
+	if get.err != nil || !get.more() {
+		return false
+	}
+	br.buf, _ = get.BytesInto(br.buf[:0])
+	if get.err == nil {
+		br.rec.Reset(br.buf)
+	}
+	return get.err == nil
+}
+
+// Get returns a get buffer that holds the current record and resolves its
+// strings with the batch dictionary. The buffer is reloaded by the next call
+// to Next; its Done method reports whether the record was completely
+// unpacked.
+func (br *BatchReader) Get() *GetBuffer {
+	return &br.rec
+}
+
+// Done is called to indicate that the batch has been read. If no error has
+// occurred and no content remains buffered, nil is returned, otherwise an
+// appropriate error value.
+func (br *BatchReader) Done() error {
+	return br.get.Done()
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func ExampleBatchWriter() {
+	var bw BatchWriter
+	var plain PutBuffer
+	for j := 0; j < 100; j++ {
+		region := []string{"us-east", "eu-west"}[j%2]
+		bw.Record(func(put *PutBuffer) {
+			put.Uint32(uint32(j))
+			put.Str(region)
+			put.Str("active")
+		})
+		plain.Uint32(uint32(j))
+		plain.Str(region)
+		plain.Str("active")
+	}
+	data, _ := bw.Data()
+	fmt.Println(len(data), plain.Len())
+	br := NewBatchReader(data)
+	for br.Next() {
+		var id uint32
+		var region, status string
+		get := br.Get()
+		get.Uint32(&id)
+		get.Str(&region)
+		get.Str(&status)
+		if get.Done() == nil && id >= 98 {
+			fmt.Println(id, region, status)
+		}
+	}
+	fmt.Println(br.Done())
+	// Output:
+	// 424 1600
+	// 98 us-east active
+	// 99 eu-west active
+	// <nil>
+}
+
+func TestBatchReader(t *testing.T) {
+	var bw BatchWriter
+	for j := 0; j < 2; j++ {
+		bw.Reset()
+		bw.Record(func(put *PutBuffer) {
+			put.Str("x")
+			put.StrMap(map[string]string{"color": "red", "size": "large"})
+			put.Str("red")
+		})
+		bw.Record(func(put *PutBuffer) {
+			put.StrMap(map[string]string{"color": "red", "size": "large"})
+		})
+		data, err := bw.Data()
+		if err != nil {
+			t.Fatal(err)
+		}
+		br := NewBatchReader(data)
+		if len(br.rec.dict) != 4 {
+			t.Fatalf("expecting 4 dictionary strings, got %q", br.rec.dict)
+		}
+		var strs []string
+		for br.Next() {
+			get := br.Get()
+			var first, last string
+			if len(strs) == 0 {
+				get.Str(&first)
+			}
+			get.StrMapIter(func(k, v string) bool {
+				strs = append(strs, k, v)
+				return false
+			})
+			if len(strs) == 2 {
+				get.Str(&last)
+			}
+			if err = get.Done(); err != nil {
+				t.Fatal(err)
+			}
+			strs = append(strs, first, last)
+		}
+		if err = br.Done(); err != nil || len(strs) != 8 || strs[2] != "x" || strs[3] != "red" {
+			t.Fatalf("unexpected strings %q: %v", strs, err)
+		}
+	}
+	bw.SetError(errTest)
+	bw.Record(func(put *PutBuffer) { t.Fatal("record packed after error") })
+	if _, err := bw.Data(); err != errTest {
+		t.Fatalf("expecting test error, got %v", err)
+	}
+	get := NewGetBuffer(nil)
+	get.dict = []string{"only"}
+	var str string
+	get.Reset([]byte{2, 'a'})
+	get.Str(&str)
+	if get.Done() != nil || str != "a" {
+		t.Fatalf("unexpected inline string %q", str)
+	}
+	get.Reset([]byte{5})
+	get.Str(&str)
+	if err := get.Done(); !errors.Is(err, ErrValueRange) {
+		t.Fatalf("expecting range error, got %v", err)
+	}
+}
+
+// Ensure that a clone of a batch record shares the batch dictionary
+func TestBatchWriter_Clone(t *testing.T) {
+	var bw BatchWriter
+	bw.Record(func(put *PutBuffer) {
+		clone := put.Clone()
+		clone.Str("colour")
+		clone.Str("x")
+		data, err := clone.Data()
+		put.SetError(err)
+		put.Raw(data)
+	})
+	data, err := bw.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a, b string
+	br := NewBatchReader(data)
+	for br.Next() {
+		get := br.Get()
+		get.Str(&a)
+		get.Str(&b)
+		if err = get.Done(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = br.Done(); err != nil || a != "colour" || b != "x" {
+		t.Fatalf("unexpected strings %q %q: %v", a, b, err)
+	}
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var errKeyBigInt = errors.New("invalid arbitrary-precision integer in key")
+
+// Leading bytes of a key segment that holds an arbitrary-precision integer
+const (
+	bigNeg  = 0x7f
+	bigZero = 0x80
+	bigPos  = 0x81
+)
+
+// BigInt stores the specified arbitrary-precision integer into the receiving
+// key buffer using a variable-length encoding that preserves numeric order. A
+// marker byte indicating the sign is followed, for nonzero values, by the
+// length of the magnitude as stored by Uvarint and then the big-endian
+// magnitude itself. For negative values, the length and magnitude bytes are
+// complemented so that values of greater magnitude sort first. A nil val sets
+// an internal error that matches ErrValueRange.
+func (kb *KeyBuffer) BigInt(val *big.Int) {
+	if val == nil {
+		if kb.err == nil {
+			kb.err = categoryErrorf(ErrValueRange, "arbitrary-precision integer key value is nil")
+		}
+		return
+	}
+	switch val.Sign() {
+	case 0:
+		kb.Uint8(bigZero)
+	case 1:
+		kb.Uint8(bigPos)
+		mag := val.Bytes()
+		kb.Uvarint(uint64(len(mag)))
+		kb.write(mag)
+	default:
+		mag := val.Bytes()
+		sl := keyUvarint([]byte{bigNeg}, 0, uint64(len(mag)))
+		sl = append(sl, mag...)
+		for j := 1; j < len(sl); j++ {
+			sl[j] = ^sl[j]
+		}
+		kb.write(sl)
+	}
+}
+
+// BigInt extracts an arbitrary-precision integer that was stored with
+// KeyBuffer.BigInt from the receiving key buffer.
+func (kg *KeyGetBuffer) BigInt(val *big.Int) {
+	var marker uint8
+	kg.Uint8(&marker)
+	if kg.err == nil {
+		switch marker {
+		case bigZero:
+			val.SetInt64(0)
+		case bigPos:
+			var n uint64
+			kg.Uvarint(&n)
+			if kg.err == nil && n > uint64(len(kg.buf)) {
+				kg.err = errKeyBigInt
+			} else if sl := kg.next(int(n)); sl != nil {
+				val.SetBytes(sl)
+			}
+		case bigNeg:
+			var hdr, n uint64
+			if sl := kg.next(1); sl != nil {
+				hdr = uint64(^sl[0])
+			}
+			if hdr > 8 {
+				kg.err = errKeyBigInt
+			} else if sl := kg.next(int(hdr)); sl != nil {
+				for _, b := range sl {
+					n = n<<8 | uint64(^b)
+				}
+				if n > uint64(len(kg.buf)) {
+					kg.err = errKeyBigInt
+				} else if sl = kg.next(int(n)); sl != nil {
+					mag := make([]byte, len(sl))
+					for j, b := range sl {
+						mag[j] = ^b
+					}
+					val.SetBytes(mag)
+					val.Neg(val)
+				}
+			}
+		default:
+			kg.err = errKeyBigInt
+		}
+	}
+}
+
+// decimalScale returns 10 raised to the power scale.
+func decimalScale(scale uint) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+}
+
+// Decimal stores the fixed-point decimal number represented by str, for
+// example "-1234.56", into the receiving key buffer. scale specifies the
+// number of digits after the decimal point that are retained; the value is
+// stored with BigInt as an integer number of units of 10 raised to the power
+// -scale. The internal error is set if str is not a valid number or has more
+// significant fractional digits than scale permits. All values stored in a
+// particular key field must use the same scale.
+func (kb *KeyBuffer) Decimal(str string, scale uint) {
+	if kb.err == nil {
+		r, ok := new(big.Rat).SetString(str)
+		if !ok {
+			kb.err = fmt.Errorf("invalid decimal value %q", str)
+			return
+		}
+		r.Mul(r, new(big.Rat).SetInt(decimalScale(scale)))
+		if !r.IsInt() {
+			kb.err = fmt.Errorf("decimal value %q exceeds scale %d", str, scale)
+			return
+		}
+		kb.BigInt(r.Num())
+	}
+}
+
+// Decimal extracts a fixed-point decimal number that was stored with
+// KeyBuffer.Decimal from the receiving key buffer. scale must match the scale
+// used when the key was built. The number is formatted with exactly scale
+// digits after the decimal point.
+func (kg *KeyGetBuffer) Decimal(str *string, scale uint) {
+	var val big.Int
+	kg.BigInt(&val)
+	if kg.err == nil {
+		*str = new(big.Rat).SetFrac(&val, decimalScale(scale)).FloatString(int(scale))
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/piniondb/store/storetest"
+)
+
+// Ensure that arbitrary-precision integer keys preserve order and are
+// restored
+func TestKeyBuffer_BigInt(t *testing.T) {
+	storetest.KeyOrder(t, 5000, func(r *rand.Rand) interface{} {
+		val := new(big.Int).Rand(r, new(big.Int).Lsh(big.NewInt(1), uint(r.Intn(300))))
+		if r.Intn(2) == 0 {
+			val.Neg(val)
+		}
+		return val
+	}, func(val interface{}) ([]byte, error) {
+		var kb KeyBuffer
+		var restored big.Int
+		kb.BigInt(val.(*big.Int))
+		kb.Uint8(0xff)
+		key, err := kb.Data()
+		if err == nil {
+			kg := NewKeyGetBuffer(key)
+			kg.BigInt(&restored)
+			kg.Uint8(new(uint8))
+			err = kg.Done()
+		}
+		if err == nil && restored.Cmp(val.(*big.Int)) != 0 {
+			err = fmt.Errorf("%s restored as %s", val, &restored)
+		}
+		return key, err
+	}, func(a, b interface{}) int {
+		return a.(*big.Int).Cmp(b.(*big.Int))
+	})
+}
+
+// Ensure that arbitrary-precision integer keys with corrupt lengths are
+// rejected
+func TestKeyGetBuffer_BigInt(t *testing.T) {
+	for _, key := range [][]byte{
+		{0x7f, 0xf7, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0x7f, 0xfe, 0xfd},
+		{0x81, 0x09, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		{0x81, 0x01, 0x02},
+	} {
+		var val big.Int
+		kg := NewKeyGetBuffer(key)
+		kg.BigInt(&val)
+		if kg.Done() == nil {
+			t.Fatalf("corrupt key % x unpacked as %s", key, &val)
+		}
+	}
+}
+
+// Ensure that a nil arbitrary-precision integer is reported rather than
+// causing a panic
+func TestKeyBuffer_BigIntNil(t *testing.T) {
+	if _, err := Key(uint8(1), (*big.Int)(nil)); !errors.Is(err, ErrValueRange) {
+		t.Fatalf("expecting range error, got %v", err)
+	}
+}
+
+// ExampleKeyBuffer_Decimal demonstrates fixed-point decimal key fields.
+func ExampleKeyBuffer_Decimal() {
+	for _, str := range []string{"-12.5", "0", "3", "123456789012345678901234.75", "1.234"} {
+		var kb KeyBuffer
+		kb.Decimal(str, 2)
+		key, err := kb.Data()
+		if err == nil {
+			var restored string
+			kg := NewKeyGetBuffer(key)
+			kg.Decimal(&restored, 2)
+			err = kg.Done()
+			if err == nil {
+				fmt.Printf("%s: % x\n", restored, key)
+			}
+		}
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	// Output:
+	// -12.50: 7f fe fd fb 1d
+	// 0.00: 80
+	// 3.00: 81 01 02 01 2c
+	// 123456789012345678901234.75: 81 01 0b 0a 36 4c 98 22 7e aa 6a dc ba d3
+	// decimal value "1.234" exceeds scale 2
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+var errBlobHash = errors.New("resolved blob does not match its content hash")
+
+// BlobRef refers to a large payload that is kept outside of a record, for
+// example in an object store. Only the SHA-256 hash of the payload, its size
+// and a locator such as a URL or object name are packed into the record, so
+// records remain small. The payload is obtained with Resolve.
+type BlobRef struct {
+	Hash    [sha256.Size]byte
+	Size    uint64
+	Locator string
+}
+
+// NewBlobRef returns a reference to data that will be found at locator.
+func NewBlobRef(data []byte, locator string) BlobRef {
+	return BlobRef{Hash: sha256.Sum256(data), Size: uint64(len(data)), Locator: locator}
+}
+
+// BlobResolver retrieves the payload of a blob reference. Resolve returns the
+// complete payload, typically by fetching ref.Locator from external storage.
+type BlobResolver interface {
+	Resolve(ref BlobRef) ([]byte, error)
+}
+
+// Resolve retrieves the payload of the receiving reference with res and
+// verifies its size and hash. An error is returned if retrieval fails or if
+// the payload does not match the reference.
+func (ref BlobRef) Resolve(res BlobResolver) ([]byte, error) {
+	data, err := res.Resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) != ref.Size {
+		return nil, fmt.Errorf("resolved blob has %d bytes, expecting %d", len(data), ref.Size)
+	}
+	if sha256.Sum256(data) != ref.Hash {
+		return nil, errBlobHash
+	}
+	return data, nil
+}
+
+// BlobRef packs the specified blob reference into the receiving storage
+// buffer.
+func (put *PutBuffer) BlobRef(ref BlobRef) {
+	put.write(ref.Hash[:])
+	put.Uint64(ref.Size)
+	put.Str(ref.Locator)
+}
+
+// BlobRef unpacks a blob reference from the receiving storage buffer. The
+// payload itself is not retrieved.
+func (get *GetBuffer) BlobRef(ref *BlobRef) {
+	get.fill(ref.Hash[:])
+	get.Uint64(&ref.Size)
+	get.Str(&ref.Locator)
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// mapResolver resolves blob references by locator from memory.
+type mapResolver map[string][]byte
+
+func (mr mapResolver) Resolve(ref BlobRef) ([]byte, error) {
+	if data, ok := mr[ref.Locator]; ok {
+		return data, nil
+	}
+	return nil, fmt.Errorf("blob %s not found", ref.Locator)
+}
+
+// Ensure that blob references are packed and verified on resolution
+func TestBlobRef(t *testing.T) {
+	payload := bytes.Repeat([]byte("payload"), 1000)
+	res := mapResolver{"obj/1": payload, "obj/2": payload[1:]}
+	var put PutBuffer
+	put.BlobRef(NewBlobRef(payload, "obj/1"))
+	put.BlobRef(NewBlobRef(payload, "obj/2"))
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > 100 {
+		t.Fatalf("record of %d bytes is unexpectedly large", len(data))
+	}
+	var a, b BlobRef
+	get := NewGetBuffer(data)
+	get.BlobRef(&a)
+	get.BlobRef(&b)
+	if err = get.Done(); err != nil {
+		t.Fatal(err)
+	}
+	sl, err := a.Resolve(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sl, payload) {
+		t.Fatal("resolved blob differs from original")
+	}
+	if _, err = b.Resolve(res); err == nil {
+		t.Fatal("mismatched blob not reported")
+	}
+	b.Size--
+	if _, err = b.Resolve(res); err == nil {
+		t.Fatal("mismatched blob hash not reported")
+	}
+}
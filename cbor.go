@@ -0,0 +1,296 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// The CBOR major types used by ToCBOR and FromCBOR.
+const (
+	cborUint  = 0
+	cborNeg   = 1
+	cborBytes = 2
+	cborText  = 3
+	cborMap   = 5
+	cborTag   = 6
+)
+
+// cborEpoch is the CBOR tag for a time expressed in seconds since the Unix
+// epoch.
+const cborEpoch = 1
+
+var errCBOR = errors.New("malformed or unsupported CBOR item")
+
+// cborHead appends the initial bytes of a CBOR data item of the specified
+// major type and argument to buf, in the shortest form.
+func cborHead(buf []byte, major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return append(buf, major|byte(arg))
+	case arg <= math.MaxUint8:
+		return append(buf, major|24, byte(arg))
+	case arg <= math.MaxUint16:
+		return append(buf, major|25, byte(arg>>8), byte(arg))
+	case arg <= math.MaxUint32:
+		var hold [4]byte
+		binary.BigEndian.PutUint32(hold[:], uint32(arg))
+		return append(append(buf, major|26), hold[:]...)
+	}
+	var hold [8]byte
+	binary.BigEndian.PutUint64(hold[:], arg)
+	return append(append(buf, major|27), hold[:]...)
+}
+
+// cborInt appends the CBOR integer val to buf.
+func cborInt(buf []byte, val int64) []byte {
+	if val < 0 {
+		return cborHead(buf, cborNeg, uint64(-1-val))
+	}
+	return cborHead(buf, cborUint, uint64(val))
+}
+
+// ToCBOR returns a CBOR (RFC 8949) map that holds the values of a record
+// that conforms to schema s. Map keys are the field names as text strings,
+// in schema order. Integers are encoded as CBOR integers, str fields as text
+// strings, bytes fields as byte strings, and time fields as epoch-based
+// date/time values (tag 1) with integer seconds.
+func ToCBOR(s *Schema, data []byte) ([]byte, error) {
+	rec, err := s.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	buf := cborHead(nil, cborMap, uint64(len(s.fields)))
+	for _, f := range s.fields {
+		buf = append(cborHead(buf, cborText, uint64(len(f.Name))), f.Name...)
+		switch v := rec[f.Name].(type) {
+		case time.Time:
+			buf = cborInt(cborHead(buf, cborTag, cborEpoch), v.Unix())
+		case string:
+			buf = append(cborHead(buf, cborText, uint64(len(v))), v...)
+		case []byte:
+			buf = append(cborHead(buf, cborBytes, uint64(len(v))), v...)
+		default:
+			rv := reflect.ValueOf(v)
+			if rv.CanUint() {
+				buf = cborHead(buf, cborUint, rv.Uint())
+			} else {
+				buf = cborInt(buf, rv.Int())
+			}
+		}
+	}
+	return buf, nil
+}
+
+// cborReader unpacks the data items of a CBOR encoding.
+type cborReader struct {
+	data []byte
+	pos  int
+}
+
+// head returns the major type and argument of the next data item.
+// Indefinite-length items are not supported.
+func (r *cborReader) head() (major byte, arg uint64, err error) {
+	if r.pos >= len(r.data) {
+		return 0, 0, ErrShortBuffer
+	}
+	b := r.data[r.pos]
+	r.pos++
+	major, arg = b>>5, uint64(b&31)
+	if arg >= 24 {
+		n := 0
+		switch arg {
+		case 24, 25, 26, 27:
+			n = 1 << (arg - 24)
+		default:
+			return 0, 0, errCBOR
+		}
+		if len(r.data)-r.pos < n {
+			return 0, 0, ErrShortBuffer
+		}
+		arg = 0
+		for _, b = range r.data[r.pos : r.pos+n] {
+			arg = arg<<8 | uint64(b)
+		}
+		r.pos += n
+	}
+	return
+}
+
+// str returns the content of the next data item, which must be a string of
+// the specified major type.
+func (r *cborReader) str(major byte) ([]byte, error) {
+	m, n, err := r.head()
+	if err == nil && m != major {
+		err = errCBOR
+	}
+	if err == nil && n > uint64(len(r.data)-r.pos) {
+		err = ErrShortBuffer
+	}
+	if err != nil {
+		return nil, err
+	}
+	sl := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return sl, nil
+}
+
+// int returns the value of the next data item, which must be an integer
+// that fits in an int64 if it is negative.
+func (r *cborReader) int() (neg bool, val uint64, err error) {
+	var major byte
+	major, val, err = r.head()
+	if err == nil {
+		switch {
+		case major == cborNeg && val <= math.MaxInt64:
+			neg = true
+		case major != cborUint:
+			err = errCBOR
+		}
+	}
+	return
+}
+
+// kindRange holds the range of values of each integer kind.
+var kindRange = map[Kind]struct {
+	min int64
+	max uint64
+}{
+	KindUint64: {0, math.MaxUint64},
+	KindInt64:  {math.MinInt64, math.MaxInt64},
+	KindUint32: {0, math.MaxUint32},
+	KindInt32:  {math.MinInt32, math.MaxInt32},
+	KindUint16: {0, math.MaxUint16},
+	KindInt16:  {math.MinInt16, math.MaxInt16},
+	KindUint8:  {0, math.MaxUint8},
+	KindInt8:   {math.MinInt8, math.MaxInt8},
+}
+
+// fromCBOR returns the Go value of the kind of field f held by the next data
+// item of r.
+func (f Field) fromCBOR(r *cborReader) (val interface{}, err error) {
+	switch f.Kind {
+	case KindStr:
+		var sl []byte
+		if sl, err = r.str(cborText); err == nil {
+			val = string(sl)
+		}
+		return
+	case KindBytes:
+		var sl []byte
+		if sl, err = r.str(cborBytes); err == nil {
+			val = append([]byte{}, sl...)
+		}
+		return
+	case KindTime:
+		var major byte
+		var tag uint64
+		if major, tag, err = r.head(); err == nil && (major != cborTag || tag != cborEpoch) {
+			err = errCBOR
+		}
+		if err != nil {
+			return
+		}
+	}
+	bounds, ok := kindRange[f.Kind]
+	if f.Kind == KindTime {
+		bounds, ok = kindRange[KindInt64], true
+	}
+	if !ok {
+		return nil, fmt.Errorf("unsupported kind %s", f.Kind)
+	}
+	neg, n, err := r.int()
+	if err != nil {
+		return nil, err
+	}
+	if neg && -1-int64(n) < bounds.min || !neg && n > bounds.max {
+		return nil, categoryErrorf(ErrValueRange, "value is out of range for %s", f.Kind)
+	}
+	s := int64(n)
+	if neg {
+		s = -1 - s
+	}
+	switch f.Kind {
+	case KindTime:
+		val = time.Unix(s, 0)
+	case KindUint64:
+		val = n
+	case KindInt64:
+		val = s
+	case KindUint32:
+		val = uint32(n)
+	case KindInt32:
+		val = int32(s)
+	case KindUint16:
+		val = uint16(n)
+	case KindInt16:
+		val = int16(s)
+	case KindUint8:
+		val = uint8(n)
+	case KindInt8:
+		val = int8(s)
+	}
+	return
+}
+
+// FromCBOR packs a record that conforms to schema s from a CBOR map in the
+// form produced by ToCBOR. The map must have a text key for each field of s
+// and no others, and must be the only data item in cborData.
+func FromCBOR(s *Schema, cborData []byte) ([]byte, error) {
+	r := cborReader{data: cborData}
+	major, n, err := r.head()
+	if err == nil && major != cborMap {
+		err = errCBOR
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n != uint64(len(s.fields)) {
+		return nil, errFieldCount
+	}
+	fields := make(map[string]Field, len(s.fields))
+	for _, f := range s.fields {
+		fields[f.Name] = f
+	}
+	rec := make(map[string]interface{}, len(s.fields))
+	for j := uint64(0); j < n; j++ {
+		var name []byte
+		if name, err = r.str(cborText); err != nil {
+			return nil, err
+		}
+		f, ok := fields[string(name)]
+		if !ok {
+			return nil, fmt.Errorf("CBOR map has key %q that is not in schema", name)
+		}
+		if _, ok = rec[f.Name]; ok {
+			return nil, fmt.Errorf("CBOR map has duplicate key %q", name)
+		}
+		if rec[f.Name], err = f.fromCBOR(&r); err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+	}
+	if r.pos != len(cborData) {
+		return nil, categoryErrorf(ErrLeftover, "content follows CBOR map")
+	}
+	return s.Encode(rec)
+}
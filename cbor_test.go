@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func ExampleToCBOR() {
+	s, _ := ParseSchema("id:uint32, at:time, delta:int16, key:bytes")
+	var put PutBuffer
+	put.Uint32(1000)
+	put.Time(time.Unix(1500000000, 0))
+	put.Int16(-2)
+	put.Bytes([]byte{1, 2})
+	data, _ := put.Data()
+	cbor, err := ToCBOR(s, data)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%x\n", cbor)
+	rec, err := FromCBOR(s, cbor)
+	fmt.Println(bytes.Equal(rec, data), err)
+	// Output:
+	// a46269641903e8626174c11a59682f006564656c746121636b6579420102
+	// true <nil>
+}
+
+func TestFromCBOR(t *testing.T) {
+	s, _ := ParseSchema("a:uint8, b:int64, c:str, d:uint64")
+	var put PutBuffer
+	put.Uint8(255)
+	put.Int64(-1 << 63)
+	put.Str("pinion")
+	put.Uint64(1<<64 - 1)
+	data, _ := put.Data()
+	cbor, err := ToCBOR(s, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := FromCBOR(s, cbor)
+	if err != nil || !bytes.Equal(rec, data) {
+		t.Fatalf("unexpected result %x %v", rec, err)
+	}
+	for _, c := range []struct {
+		cbor   []byte
+		target error
+	}{
+		{cbor[:len(cbor)-1], ErrShortBuffer},
+		{append(cbor, 0), ErrLeftover},
+		{[]byte{0xa1, 0x61, 'a', 0x19, 0x01, 0x00}, errFieldCount},
+		{[]byte{0xa4, 0x61, 'a', 0x19, 0x01, 0x00, 0x61, 'b', 0, 0x61, 'c', 0x60, 0x61, 'd', 0}, ErrValueRange},
+		{[]byte{0xa4, 0x61, 'a', 0x20, 0x61, 'b', 0, 0x61, 'c', 0x60, 0x61, 'd', 0}, ErrValueRange},
+		{[]byte{0xa4, 0x61, 'a', 0, 0x61, 'b', 0, 0x61, 'c', 0x40, 0x61, 'd', 0}, errCBOR},
+		{[]byte{0xa4, 0x61, 'a', 0, 0x61, 'b', 0, 0x61, 'c', 0x7f, 0x61, 'd', 0}, errCBOR},
+		{[]byte{0x80}, errCBOR},
+	} {
+		if _, err = FromCBOR(s, c.cbor); !errors.Is(err, c.target) {
+			t.Fatalf("%x: expecting %v, got %v", c.cbor, c.target, err)
+		}
+	}
+	if _, err = FromCBOR(s, []byte{0xa4, 0x61, 'a', 0, 0x61, 'a', 0, 0x61, 'c', 0x60, 0x61, 'd', 0}); err == nil {
+		t.Fatal("expecting error for duplicate key")
+	}
+}
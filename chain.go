@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"time"
+)
+
+// PutChain wraps a put buffer so that values can be packed in a single
+// expression, as in put.Chain().Uint32(a).Str(s).Time(tm). Each packing
+// method packs its value as the PutBuffer method of the same name does and
+// returns the chain. Errors are latched in the put buffer as usual, so they
+// are reported by Data or Error after the last value is packed. Other
+// PutBuffer methods, including Data, are available through the embedded
+// pointer.
+type PutChain struct {
+	*PutBuffer
+}
+
+// Chain returns a chain that packs values into the receiving storage buffer.
+func (put *PutBuffer) Chain() PutChain {
+	return PutChain{put}
+}
+
+// Time packs tm and returns the receiving chain.
+func (c PutChain) Time(tm time.Time) PutChain {
+	c.PutBuffer.Time(tm)
+	return c
+}
+
+// Uint64 packs val and returns the receiving chain.
+func (c PutChain) Uint64(val uint64) PutChain {
+	c.PutBuffer.Uint64(val)
+	return c
+}
+
+// Int64 packs val and returns the receiving chain.
+func (c PutChain) Int64(val int64) PutChain {
+	c.PutBuffer.Int64(val)
+	return c
+}
+
+// Uint32 packs val and returns the receiving chain.
+func (c PutChain) Uint32(val uint32) PutChain {
+	c.PutBuffer.Uint32(val)
+	return c
+}
+
+// Int32 packs val and returns the receiving chain.
+func (c PutChain) Int32(val int32) PutChain {
+	c.PutBuffer.Int32(val)
+	return c
+}
+
+// Uint16 packs val and returns the receiving chain.
+func (c PutChain) Uint16(val uint16) PutChain {
+	c.PutBuffer.Uint16(val)
+	return c
+}
+
+// Int16 packs val and returns the receiving chain.
+func (c PutChain) Int16(val int16) PutChain {
+	c.PutBuffer.Int16(val)
+	return c
+}
+
+// Uint8 packs val and returns the receiving chain.
+func (c PutChain) Uint8(val uint8) PutChain {
+	c.PutBuffer.Uint8(val)
+	return c
+}
+
+// Int8 packs val and returns the receiving chain.
+func (c PutChain) Int8(val int8) PutChain {
+	c.PutBuffer.Int8(val)
+	return c
+}
+
+// Uint64Fixed packs val and returns the receiving chain.
+func (c PutChain) Uint64Fixed(val uint64) PutChain {
+	c.PutBuffer.Uint64Fixed(val)
+	return c
+}
+
+// Uint32Fixed packs val and returns the receiving chain.
+func (c PutChain) Uint32Fixed(val uint32) PutChain {
+	c.PutBuffer.Uint32Fixed(val)
+	return c
+}
+
+// Str packs str and returns the receiving chain.
+func (c PutChain) Str(str string) PutChain {
+	c.PutBuffer.Str(str)
+	return c
+}
+
+// Bytes packs sl and returns the receiving chain.
+func (c PutChain) Bytes(sl []byte) PutChain {
+	c.PutBuffer.Bytes(sl)
+	return c
+}
+
+// Raw packs sl without a length prefix and returns the receiving chain.
+func (c PutChain) Raw(sl []byte) PutChain {
+	c.PutBuffer.Raw(sl)
+	return c
+}
+
+// StrMap packs mp and returns the receiving chain.
+func (c PutChain) StrMap(mp map[string]string) PutChain {
+	c.PutBuffer.StrMap(mp)
+	return c
+}
+
+// ID packs id and returns the receiving chain.
+func (c PutChain) ID(id ID) PutChain {
+	c.PutBuffer.ID(id)
+	return c
+}
+
+// BlobRef packs ref and returns the receiving chain.
+func (c PutChain) BlobRef(ref BlobRef) PutChain {
+	c.PutBuffer.BlobRef(ref)
+	return c
+}
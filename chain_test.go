@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+)
+
+// ExamplePutChain demonstrates packing a simple record in one expression.
+func ExamplePutChain() {
+	var put PutBuffer
+	data, err := put.Chain().Uint32(300).Str("abc").Int8(-1).Data()
+	if err == nil {
+		var a uint32
+		var s string
+		var b int8
+		get := NewGetBuffer(data)
+		get.Uint32(&a)
+		get.Str(&s)
+		get.Int8(&b)
+		err = get.Done()
+		fmt.Printf("% x\n%d %s %d\n", data, a, s, b)
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// ac 02 03 61 62 63 ff
+	// 300 abc -1
+}
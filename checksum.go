@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// SetChecksum controls whether a CRC-32 checksum, using the Castagnoli
+// polynomial, is appended to the packed fields of the receiving storage
+// buffer when they are retrieved with Data, DataUnsafe, AppendTo or WriteTo.
+// When compression is also enabled, the checksum covers the compressed form.
+// The output must be unpacked by a GetBuffer with checksums enabled; see
+// GetBuffer.SetChecksum.
+func (put *PutBuffer) SetChecksum(enabled bool) {
+	put.checksum = enabled
+	put.out = nil
+}
+
+// SetChecksum controls whether the receiving get buffer expects content
+// produced by a PutBuffer with checksums enabled. When it is enabled, the
+// checksum of content that is currently loaded and has not yet been read, as
+// well as content subsequently loaded with Reset or ReadFrom, is verified and
+// removed before values are unpacked. A mismatch, as produced by damaged or
+// truncated content, sets an internal error that matches ErrChecksum. The
+// internal error is also set if the buffer was returned by NewGetReader.
+func (get *GetBuffer) SetChecksum(enabled bool) {
+	get.verify = enabled
+	get.reload()
+}
+
+// appendChecksum appends content followed by its checksum to dst.
+func appendChecksum(dst, content []byte) []byte {
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.Checksum(content, logTable))
+	return append(append(dst, content...), sum[:]...)
+}
+
+// verifyChecksum returns the content of data, which ends with a checksum
+// appended by appendChecksum.
+func verifyChecksum(data []byte) ([]byte, error) {
+	n := len(data) - 4
+	if n < 0 {
+		return nil, categoryErrorf(ErrChecksum, "content of %d bytes is too short to hold a checksum", len(data))
+	}
+	if crc32.Checksum(data[:n], logTable) != binary.BigEndian.Uint32(data[n:]) {
+		return nil, categoryErrorf(ErrChecksum, "checksum does not match content of %d bytes", n)
+	}
+	return data[:n], nil
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGetBuffer_SetChecksum(t *testing.T) {
+	var put PutBuffer
+	put.SetChecksum(true)
+	put.Uint32(42)
+	put.Str("pinion")
+	data, err := put.DataUnsafe()
+	if err != nil || len(data) != len(put.buf)+4 {
+		t.Fatalf("unexpected output %x: %v", data, err)
+	}
+	put.Str("more")
+	if !bytes.Equal(data[:len(data)-4], put.buf[:len(data)-4]) {
+		t.Fatal("output altered by subsequent packing")
+	}
+	var id uint32
+	var name string
+	get := NewGetBuffer(data)
+	get.SetChecksum(true)
+	get.Uint32(&id)
+	get.Str(&name)
+	if err = get.Done(); err != nil || id != 42 || name != "pinion" {
+		t.Fatalf("unexpected result %d %q: %v", id, name, err)
+	}
+	damaged := append([]byte(nil), data...)
+	damaged[2] ^= 0x10
+	for _, sl := range [][]byte{damaged, data[:len(data)-1], data[:3]} {
+		get.Reset(sl)
+		get.Uint32(&id)
+		if err = get.Done(); !errors.Is(err, ErrChecksum) {
+			t.Fatalf("expecting checksum error for %x, got %v", sl, err)
+		}
+	}
+	get = NewGetBuffer(data)
+	get.SetChecksum(true)
+	get.SetChecksum(false)
+	get.Uint32(&id)
+	get.Str(&name)
+	if err = get.Done(); !errors.Is(err, ErrLeftover) {
+		t.Fatalf("expecting leftover content with checksum disabled, got %v", err)
+	}
+}
+
+// Ensure that checksums and compression can be combined and enabled in either
+// order
+func TestGetBuffer_SetChecksumCompression(t *testing.T) {
+	var put PutBuffer
+	put.SetChecksum(true)
+	put.SetCompression(64)
+	put.Str(strings.Repeat("gear ", 100))
+	data, _ := put.Data()
+	var b bytes.Buffer
+	put.WriteTo(&b)
+	if !bytes.Equal(b.Bytes(), data) || len(data) > 100 {
+		t.Fatalf("unexpected output of %d bytes", len(data))
+	}
+	for _, checksumFirst := range []bool{false, true} {
+		var str string
+		get := NewGetBuffer(data)
+		if checksumFirst {
+			get.SetChecksum(true)
+			get.SetCompression(true)
+		} else {
+			get.SetCompression(true)
+			get.SetChecksum(true)
+		}
+		get.Str(&str)
+		if err := get.Done(); err != nil || len(str) != 500 {
+			t.Fatalf("unexpected result of %d bytes: %v", len(str), err)
+		}
+	}
+}
+
+// Ensure that an error produced by an envelope setting is cleared when the
+// setting is withdrawn before any values are unpacked
+func TestGetBuffer_SetChecksumWithdrawn(t *testing.T) {
+	get := NewGetBuffer([]byte{9})
+	get.SetChecksum(true)
+	if !errors.Is(get.err, ErrChecksum) {
+		t.Fatalf("expecting checksum error, got %v", get.err)
+	}
+	get.SetChecksum(false)
+	var val uint8
+	get.Uint8(&val)
+	if err := get.Done(); err != nil || val != 9 {
+		t.Fatalf("unexpected result %d: %v", val, err)
+	}
+	get.Reset([]byte{9})
+	get.SetError(errTest)
+	get.SetChecksum(true)
+	if get.Done() != errTest {
+		t.Fatal("assigned error was cleared")
+	}
+}
@@ -0,0 +1,135 @@
+// Code generated by storegen; DO NOT EDIT.
+
+package sample
+
+import (
+	"github.com/piniondb/store"
+)
+
+// StorePut packs the receiving Order into put.
+func (r *Order) StorePut(put *store.PutBuffer) {
+	put.ID(r.ID)
+	put.Time(r.Placed)
+	u1 := uint8(r.Status)
+	put.Uint8(u1)
+	put.Uint32(uint32(len(r.Lines)))
+	for _, e2 := range r.Lines {
+		e2.StorePut(put)
+	}
+	put.Uint32(uint32(len(r.Notes)))
+	for k3, e4 := range r.Notes {
+		put.Str(k3)
+		put.Str(e4)
+	}
+	if r.Rush {
+		put.Uint8(1)
+	} else {
+		put.Uint8(0)
+	}
+	u5 := []string(r.Tags)
+	put.Uint32(uint32(len(u5)))
+	for _, e6 := range u5 {
+		put.Str(e6)
+	}
+	if r.Parent == nil {
+		put.Uint8(0)
+	} else {
+		put.Uint8(1)
+		(*r.Parent).StorePut(put)
+	}
+	put.Raw(r.Checksum[:])
+	for j7 := range r.Scores {
+		put.Int64(int64(r.Scores[j7]))
+	}
+}
+
+// StoreGet unpacks the receiving Order from get.
+func (r *Order) StoreGet(get *store.GetBuffer) {
+	get.ID(&r.ID)
+	get.Time(&r.Placed)
+	var u8 uint8
+	get.Uint8(&u8)
+	r.Status = Status(u8)
+	var n9 uint32
+	get.Uint32(&n9)
+	r.Lines = nil
+	for j10 := uint32(0); j10 < n9 && get.Error() == nil; j10++ {
+		var e11 Line
+		e11.StoreGet(get)
+		r.Lines = append(r.Lines, e11)
+	}
+	var n12 uint32
+	get.Uint32(&n12)
+	r.Notes = make(map[string]string)
+	for j13 := uint32(0); j13 < n12 && get.Error() == nil; j13++ {
+		var k14 string
+		var e15 string
+		get.Str(&k14)
+		get.Str(&e15)
+		r.Notes[k14] = e15
+	}
+	var u16 uint8
+	get.Uint8(&u16)
+	r.Rush = u16 != 0
+	var u17 []string
+	var n18 uint32
+	get.Uint32(&n18)
+	u17 = nil
+	for j19 := uint32(0); j19 < n18 && get.Error() == nil; j19++ {
+		var e20 string
+		get.Str(&e20)
+		u17 = append(u17, e20)
+	}
+	r.Tags = Tags(u17)
+	var p21 uint8
+	get.Uint8(&p21)
+	r.Parent = nil
+	if p21 != 0 {
+		r.Parent = new(Order)
+		(*r.Parent).StoreGet(get)
+	}
+	var b22 []byte
+	get.Raw(len(r.Checksum), &b22)
+	copy(r.Checksum[:], b22)
+	for j23 := range r.Scores {
+		var u24 int64
+		get.Int64(&u24)
+		r.Scores[j23] = int(u24)
+	}
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (r *Order) MarshalBinary() ([]byte, error) {
+	return store.Marshal(r)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (r *Order) UnmarshalBinary(data []byte) error {
+	return store.Unmarshal(data, r)
+}
+
+// StorePut packs the receiving Line into put.
+func (r *Line) StorePut(put *store.PutBuffer) {
+	put.Str(r.SKU)
+	put.Uint32(r.Quantity)
+	put.Int64(r.Price)
+	put.Bytes(r.Data)
+}
+
+// StoreGet unpacks the receiving Line from get.
+func (r *Line) StoreGet(get *store.GetBuffer) {
+	get.Str(&r.SKU)
+	get.Uint32(&r.Quantity)
+	get.Int64(&r.Price)
+	get.Bytes(&r.Data)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (r *Line) MarshalBinary() ([]byte, error) {
+	return store.Marshal(r)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (r *Line) UnmarshalBinary(data []byte) error {
+	return store.Unmarshal(data, r)
+}
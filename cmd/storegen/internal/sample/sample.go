@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package sample holds types whose store methods are generated by storegen.
+// The generated file is kept up to date by the storegen tests.
+package sample
+
+//go:generate go run github.com/piniondb/store/cmd/storegen -type Order,Line
+
+import (
+	"time"
+
+	"github.com/piniondb/store"
+)
+
+// Status is the state of an order.
+type Status uint8
+
+// Tags labels an order.
+type Tags []string
+
+// Order is a record with fields of most supported kinds.
+type Order struct {
+	ID       store.ID          `store:"1"`
+	Placed   time.Time         `store:"2"`
+	Status   Status            `store:"3"`
+	Lines    []Line            `store:"4"`
+	Notes    map[string]string `store:"5"`
+	Tags     Tags              `store:"7"`
+	Rush     bool              `store:"6"`
+	Parent   *Order            `store:"8"`
+	Checksum [4]byte           `store:"9"`
+	Scores   [2]int            `store:"10"`
+	cache    []byte
+}
+
+// Line is an order line that is nested within Order.
+type Line struct {
+	SKU      string `store:"1"`
+	Quantity uint32 `store:"2"`
+	Price    int64  `store:"3"`
+	Data     []byte `store:"4"`
+}
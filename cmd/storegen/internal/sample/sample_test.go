@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package sample
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/piniondb/store"
+)
+
+// Ensure that generated methods restore every tagged field
+func TestOrder_MarshalBinary(t *testing.T) {
+	src := Order{
+		ID:     [16]byte{1, 2, 3},
+		Placed: time.Unix(1500000000, 0),
+		Status: 3,
+		Lines: []Line{
+			{SKU: "A-1", Quantity: 2, Price: -150, Data: []byte{9}},
+			{SKU: "B-2", Quantity: 1, Price: 75, Data: []byte{}},
+		},
+		Notes:    map[string]string{"gift": "yes"},
+		Tags:     Tags{"new", "priority"},
+		Rush:     true,
+		Parent:   &Order{Notes: map[string]string{}, Placed: time.Unix(0, 0)},
+		Checksum: [4]byte{0xde, 0xad, 0xbe, 0xef},
+		Scores:   [2]int{-1, 1 << 40},
+		cache:    []byte("ignored"),
+	}
+	data, err := src.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dst Order
+	if err = dst.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	src.cache = nil
+	if !reflect.DeepEqual(src, dst) {
+		t.Fatalf("expecting %+v, got %+v", src, dst)
+	}
+	if err = dst.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Fatal("short record not reported")
+	}
+}
+
+// Ensure that generated methods and reflection-based encoding agree
+func TestLine_Encode(t *testing.T) {
+	line := Line{SKU: "C-3", Quantity: 4, Price: -9, Data: []byte("abc")}
+	want, err := line.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A defined type without methods is walked field by field
+	type plainLine Line
+	data, err := store.Encode(plainLine(line))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Fatalf("expecting %x, got %x", want, data)
+	}
+}
@@ -0,0 +1,517 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+/*
+Storegen generates methods that pack and unpack struct types with the store
+package, so that the mirrored put and get calls do not have to be written by
+hand. It is intended to be run by go generate:
+
+	//go:generate storegen -type Order,Line
+
+For each named type, storegen emits the methods StorePut and StoreGet, which
+implement store.Putter and store.Getter, along with MarshalBinary and
+UnmarshalBinary. Only fields with a store tag are packed, in ascending order
+of the tag's field number:
+
+	type Order struct {
+		ID     uint64            `store:"1"`
+		Placed time.Time         `store:"2"`
+		Lines  []Line            `store:"3"`
+		Notes  map[string]string `store:"4"`
+		cache  []byte
+	}
+
+Since values are packed positionally, field numbers of existing fields must
+not change once data has been written; new fields should be given higher
+numbers. Supported field types are the sized integer types, int, uint, bool,
+string, []byte, time.Time, store.ID, fixed-size arrays, slices, maps, pointers
+to supported types, types declared in the same package with one of these as
+their underlying type, and other types that implement store.Putter and
+store.Getter, including those generated by storegen.
+
+Usage:
+
+	storegen -type T[,T...] [-output file] [dir]
+
+The package in dir, by default the current directory, is parsed. The output
+file defaults to the lower-case name of the first type followed by
+_store.go.
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const storePath = "github.com/piniondb/store"
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("storegen: ")
+	typeList := flag.String("type", "", "comma-separated list of type names; required")
+	output := flag.String("output", "", "output file name; default <type>_store.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: storegen -type T[,T...] [-output file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeList == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	names := strings.Split(*typeList, ",")
+	src, err := generate(dir, names)
+	if err != nil {
+		log.Fatal(err)
+	}
+	name := *output
+	if name == "" {
+		name = filepath.Join(dir, strings.ToLower(names[0])+"_store.go")
+	}
+	if err = ioutil.WriteFile(name, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generator accumulates the generated source for one package.
+type generator struct {
+	buf     bytes.Buffer
+	specs   map[string]*ast.TypeSpec
+	imports map[string]string // package name to import path
+	used    map[string]bool   // import paths referenced by generated code
+	tmp     int
+}
+
+// field describes a tagged struct field.
+type field struct {
+	name string
+	num  int
+	typ  ast.Expr
+}
+
+// generate returns the formatted source of the methods for the named types,
+// which are declared in the package in dir.
+func generate(dir string, names []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{specs: map[string]*ast.TypeSpec{}, imports: map[string]string{},
+		used: map[string]bool{storePath: true}}
+	pkg := ""
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		if pkg == "" {
+			pkg = file.Name.Name
+		}
+		for _, imp := range file.Imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			name := path[strings.LastIndex(path, "/")+1:]
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			g.imports[name] = path
+		}
+		for _, decl := range file.Decls {
+			if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
+				for _, spec := range gd.Specs {
+					ts := spec.(*ast.TypeSpec)
+					g.specs[ts.Name.Name] = ts
+				}
+			}
+		}
+	}
+	if pkg == "" {
+		return nil, fmt.Errorf("no Go source files in %s", dir)
+	}
+	var body bytes.Buffer
+	for _, name := range names {
+		g.buf.Reset()
+		if err = g.typ(name); err != nil {
+			return nil, err
+		}
+		body.Write(g.buf.Bytes())
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by storegen; DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	var list []string
+	for path := range g.used {
+		list = append(list, path)
+	}
+	sort.Strings(list)
+	for _, path := range list {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	out.WriteString(")\n")
+	out.Write(body.Bytes())
+	return format.Source(out.Bytes())
+}
+
+// typ generates the methods for the named struct type.
+func (g *generator) typ(name string) error {
+	ts, ok := g.specs[name]
+	if !ok {
+		return fmt.Errorf("type %s not found", name)
+	}
+	st, ok := ts.Type.(*ast.StructType)
+	if !ok {
+		return fmt.Errorf("type %s is not a struct", name)
+	}
+	fields, err := tagged(st)
+	if err != nil {
+		return fmt.Errorf("type %s: %s", name, err)
+	}
+	g.printf("\n// StorePut packs the receiving %s into put.\n", name)
+	g.printf("func (r *%s) StorePut(put *store.PutBuffer) {\n", name)
+	for _, f := range fields {
+		if err = g.put("r."+f.name, f.typ); err != nil {
+			return fmt.Errorf("field %s.%s: %s", name, f.name, err)
+		}
+	}
+	g.printf("}\n\n// StoreGet unpacks the receiving %s from get.\n", name)
+	g.printf("func (r *%s) StoreGet(get *store.GetBuffer) {\n", name)
+	for _, f := range fields {
+		if err = g.get("r."+f.name, f.typ); err != nil {
+			return fmt.Errorf("field %s.%s: %s", name, f.name, err)
+		}
+	}
+	g.printf("}\n\n// MarshalBinary implements the encoding.BinaryMarshaler interface.\n")
+	g.printf("func (r *%s) MarshalBinary() ([]byte, error) {\n\treturn store.Marshal(r)\n}\n\n", name)
+	g.printf("// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.\n")
+	g.printf("func (r *%s) UnmarshalBinary(data []byte) error {\n\treturn store.Unmarshal(data, r)\n}\n", name)
+	return nil
+}
+
+// tagged returns the fields of st that have a store tag, ordered by field
+// number.
+func tagged(st *ast.StructType) (list []field, err error) {
+	seen := map[int]string{}
+	for _, f := range st.Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		tag, _ := strconv.Unquote(f.Tag.Value)
+		val := reflect.StructTag(tag).Get("store")
+		if val == "" || val == "-" {
+			continue
+		}
+		num, err := strconv.Atoi(val)
+		if err != nil || num < 1 {
+			return nil, fmt.Errorf("invalid field number %q", val)
+		}
+		names := f.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{embeddedName(f.Type)}
+		}
+		if len(names) > 1 {
+			return nil, fmt.Errorf("field number %d is shared by %d fields", num, len(names))
+		}
+		if prev, ok := seen[num]; ok {
+			return nil, fmt.Errorf("field number %d used by both %s and %s", num, prev, names[0].Name)
+		}
+		seen[num] = names[0].Name
+		list = append(list, field{name: names[0].Name, num: num, typ: f.Type})
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].num < list[b].num })
+	return
+}
+
+// embeddedName returns the field name of an embedded type.
+func embeddedName(expr ast.Expr) *ast.Ident {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel
+	case *ast.Ident:
+		return t
+	}
+	return ast.NewIdent("_")
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// name returns a new temporary variable name with the specified prefix.
+func (g *generator) name(prefix string) string {
+	g.tmp++
+	return prefix + strconv.Itoa(g.tmp)
+}
+
+// expr returns the source form of a type expression, recording any imports
+// it requires.
+func (g *generator) expr(t ast.Expr) string {
+	ast.Inspect(t, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				if path, ok := g.imports[id.Name]; ok {
+					g.used[path] = true
+				}
+			}
+			return false
+		}
+		return true
+	})
+	return types.ExprString(t)
+}
+
+// basic maps predeclared types to the PutBuffer and GetBuffer method that
+// handles them, and to the type the method operates on if a conversion is
+// needed.
+var basic = map[string][2]string{
+	"uint64": {"Uint64", ""},
+	"int64":  {"Int64", ""},
+	"uint32": {"Uint32", ""},
+	"int32":  {"Int32", ""},
+	"uint16": {"Uint16", ""},
+	"int16":  {"Int16", ""},
+	"uint8":  {"Uint8", ""},
+	"byte":   {"Uint8", ""},
+	"int8":   {"Int8", ""},
+	"string": {"Str", ""},
+	"int":    {"Int64", "int64"},
+	"uint":   {"Uint64", "uint64"},
+}
+
+// isPkgType reports whether sel refers to the named type of the package
+// with the specified import path.
+func (g *generator) isPkgType(sel *ast.SelectorExpr, path, name string) bool {
+	id, ok := sel.X.(*ast.Ident)
+	return ok && g.imports[id.Name] == path && sel.Sel.Name == name
+}
+
+// underlying returns the type expression of the named type declared in the
+// package if it is neither a struct nor an interface, in which case values
+// are converted to that type for packing. Otherwise nil is returned, and the
+// type is expected to implement store.Putter and store.Getter.
+func (g *generator) underlying(name string) ast.Expr {
+	if ts, ok := g.specs[name]; ok && !ts.Assign.IsValid() {
+		switch ts.Type.(type) {
+		case *ast.StructType, *ast.InterfaceType:
+		default:
+			return ts.Type
+		}
+	}
+	return nil
+}
+
+// isByte reports whether t is byte or uint8.
+func isByte(t ast.Expr) bool {
+	id, ok := t.(*ast.Ident)
+	return ok && (id.Name == "byte" || id.Name == "uint8")
+}
+
+// put generates the packing of the addressable value v of type t.
+func (g *generator) put(v string, t ast.Expr) error {
+	switch t := t.(type) {
+	case *ast.Ident:
+		if b, ok := basic[t.Name]; ok {
+			if b[1] != "" {
+				v = b[1] + "(" + v + ")"
+			}
+			g.printf("put.%s(%s)\n", b[0], v)
+			return nil
+		}
+		if t.Name == "bool" {
+			g.printf("if %s {\nput.Uint8(1)\n} else {\nput.Uint8(0)\n}\n", v)
+			return nil
+		}
+		if u := g.underlying(t.Name); u != nil {
+			tmp := g.name("u")
+			g.printf("%s := %s(%s)\n", tmp, g.expr(u), v)
+			return g.put(tmp, u)
+		}
+	case *ast.SelectorExpr:
+		switch {
+		case g.isPkgType(t, "time", "Time"):
+			g.printf("put.Time(%s)\n", v)
+			return nil
+		case g.isPkgType(t, storePath, "ID"):
+			g.printf("put.ID(%s)\n", v)
+			return nil
+		}
+		g.expr(t)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			if isByte(t.Elt) {
+				g.printf("put.Bytes(%s)\n", v)
+				return nil
+			}
+			e := g.name("e")
+			g.printf("put.Uint32(uint32(len(%s)))\nfor _, %s := range %s {\n", v, e, v)
+			if err := g.put(e, t.Elt); err != nil {
+				return err
+			}
+			g.printf("}\n")
+			return nil
+		}
+		if isByte(t.Elt) {
+			g.printf("put.Raw(%s[:])\n", v)
+			return nil
+		}
+		j := g.name("j")
+		g.printf("for %s := range %s {\n", j, v)
+		if err := g.put(v+"["+j+"]", t.Elt); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	case *ast.MapType:
+		k, e := g.name("k"), g.name("e")
+		g.printf("put.Uint32(uint32(len(%s)))\nfor %s, %s := range %s {\n", v, k, e, v)
+		if err := g.put(k, t.Key); err != nil {
+			return err
+		}
+		if err := g.put(e, t.Value); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	case *ast.StarExpr:
+		g.printf("if %s == nil {\nput.Uint8(0)\n} else {\nput.Uint8(1)\n", v)
+		if err := g.put("(*"+v+")", t.X); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	default:
+		return fmt.Errorf("unsupported type %s", types.ExprString(t))
+	}
+	g.printf("%s.StorePut(put)\n", v)
+	return nil
+}
+
+// get generates the unpacking of the addressable value v of type t.
+func (g *generator) get(v string, t ast.Expr) error {
+	switch t := t.(type) {
+	case *ast.Ident:
+		if b, ok := basic[t.Name]; ok {
+			if b[1] != "" {
+				tmp := g.name("u")
+				g.printf("var %s %s\nget.%s(&%s)\n%s = %s(%s)\n", tmp, b[1], b[0], tmp, v, t.Name, tmp)
+			} else {
+				g.printf("get.%s(&%s)\n", b[0], v)
+			}
+			return nil
+		}
+		if t.Name == "bool" {
+			tmp := g.name("u")
+			g.printf("var %s uint8\nget.Uint8(&%s)\n%s = %s != 0\n", tmp, tmp, v, tmp)
+			return nil
+		}
+		if u := g.underlying(t.Name); u != nil {
+			tmp := g.name("u")
+			g.printf("var %s %s\n", tmp, g.expr(u))
+			if err := g.get(tmp, u); err != nil {
+				return err
+			}
+			g.printf("%s = %s(%s)\n", v, t.Name, tmp)
+			return nil
+		}
+	case *ast.SelectorExpr:
+		switch {
+		case g.isPkgType(t, "time", "Time"):
+			g.printf("get.Time(&%s)\n", v)
+			return nil
+		case g.isPkgType(t, storePath, "ID"):
+			g.printf("get.ID(&%s)\n", v)
+			return nil
+		}
+		g.expr(t)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			if isByte(t.Elt) {
+				g.printf("get.Bytes(&%s)\n", v)
+				return nil
+			}
+			// Elements are appended rather than allocated in advance so
+			// that a corrupt count cannot cause a huge allocation.
+			n, j, e := g.name("n"), g.name("j"), g.name("e")
+			g.printf("var %s uint32\nget.Uint32(&%s)\n%s = nil\n", n, n, v)
+			g.printf("for %s := uint32(0); %s < %s && get.Error() == nil; %s++ {\n", j, j, n, j)
+			g.printf("var %s %s\n", e, g.expr(t.Elt))
+			if err := g.get(e, t.Elt); err != nil {
+				return err
+			}
+			g.printf("%s = append(%s, %s)\n}\n", v, v, e)
+			return nil
+		}
+		if isByte(t.Elt) {
+			tmp := g.name("b")
+			g.printf("var %s []byte\nget.Raw(len(%s), &%s)\ncopy(%s[:], %s)\n", tmp, v, tmp, v, tmp)
+			return nil
+		}
+		j := g.name("j")
+		g.printf("for %s := range %s {\n", j, v)
+		if err := g.get(v+"["+j+"]", t.Elt); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	case *ast.MapType:
+		n, j, k, e := g.name("n"), g.name("j"), g.name("k"), g.name("e")
+		g.printf("var %s uint32\nget.Uint32(&%s)\n%s = make(%s)\n", n, n, v, g.expr(t))
+		g.printf("for %s := uint32(0); %s < %s && get.Error() == nil; %s++ {\n", j, j, n, j)
+		g.printf("var %s %s\nvar %s %s\n", k, g.expr(t.Key), e, g.expr(t.Value))
+		if err := g.get(k, t.Key); err != nil {
+			return err
+		}
+		if err := g.get(e, t.Value); err != nil {
+			return err
+		}
+		g.printf("%s[%s] = %s\n}\n", v, k, e)
+		return nil
+	case *ast.StarExpr:
+		p := g.name("p")
+		g.printf("var %s uint8\nget.Uint8(&%s)\n%s = nil\nif %s != 0 {\n%s = new(%s)\n", p, p, v, p, v, g.expr(t.X))
+		if err := g.get("(*"+v+")", t.X); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	default:
+		return fmt.Errorf("unsupported type %s", types.ExprString(t))
+	}
+	g.printf("%s.StoreGet(get)\n", v)
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Ensure that the committed sample output matches the generator
+func TestGenerate(t *testing.T) {
+	dir := filepath.Join("internal", "sample")
+	src, err := generate(dir, []string{"Order", "Line"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile(filepath.Join(dir, "order_store.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Fatal("generated source differs from order_store.go; run go generate in internal/sample")
+	}
+}
+
+// Ensure that unsupported declarations are reported
+func TestGenerate_Errors(t *testing.T) {
+	for _, c := range []struct{ src, msg string }{
+		{"type T struct { A int `store:\"1\"`; B int `store:\"1\"` }", "used by both"},
+		{"type T struct { A int `store:\"x\"` }", "invalid field number"},
+		{"type T struct { A func() `store:\"1\"` }", "unsupported type"},
+		{"type T int", "not a struct"},
+		{"type U struct{}", "not found"},
+	} {
+		dir := t.TempDir()
+		err := ioutil.WriteFile(filepath.Join(dir, "t.go"), []byte("package p\n"+c.src+"\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = generate(dir, []string{"T"})
+		if err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Fatalf("expecting error containing %q for %s, got %v", c.msg, c.src, err)
+		}
+	}
+	if _, err := generate(os.DevNull, []string{"T"}); err == nil {
+		t.Fatal("missing source not reported")
+	}
+}
@@ -0,0 +1,251 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+/*
+Storejs generates a JavaScript module that decodes records described by a
+store schema, so that services not written in Go can consume values packed
+with the store package. The schema is given in the text form accepted by
+store.ParseSchema:
+
+	storejs -name Order -schema "id:uint64, placed:time, note:str"
+
+The generated module exports a function named decode followed by the
+record name, here decodeOrder, that accepts a Uint8Array and returns an
+object with a property for each field. Fields of kind uint64 and int64 are
+returned as BigInt values, time fields as Date values, str fields as strings
+and bytes fields as Uint8Array values; the remaining integer kinds are
+returned as numbers. An Error is thrown if the record is truncated, has
+leftover content or holds a malformed value. As with a store.GetBuffer in
+strict mode, a uint32, int32, uint16 or int16 field that holds a value outside
+the range of its kind is also reported. The module has no dependencies and requires an
+ECMAScript 2020 environment.
+
+Usage:
+
+	storejs -name N -schema S [-output file]
+
+The output file defaults to the lower-case record name followed by .js.
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/piniondb/store"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("storejs: ")
+	name := flag.String("name", "", "record name used in the decoder function name; required")
+	schema := flag.String("schema", "", "record schema in the form accepted by store.ParseSchema; required")
+	output := flag.String("output", "", "output file name; default <name>.js")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: storejs -name N -schema S [-output file]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *name == "" || *schema == "" || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	s, err := store.ParseSchema(*schema)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(*name, s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	file := *output
+	if file == "" {
+		file = strings.ToLower(*name) + ".js"
+	}
+	if err = ioutil.WriteFile(file, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// identRe matches names that can be used as JavaScript identifiers without
+// quoting.
+var identRe = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// readers maps each record field kind to the method of the generated
+// StoreReader class that unpacks it.
+var readers = map[store.Kind]string{
+	store.KindTime:   "time",
+	store.KindUint64: "uint64",
+	store.KindInt64:  "int64",
+	store.KindUint32: "uint32",
+	store.KindInt32:  "int32",
+	store.KindUint16: "uint16",
+	store.KindInt16:  "int16",
+	store.KindUint8:  "uint8",
+	store.KindInt8:   "int8",
+	store.KindStr:    "str",
+	store.KindBytes:  "bytes",
+}
+
+// generate returns the source of a JavaScript module that decodes records
+// described by s.
+func generate(name string, s *store.Schema) ([]byte, error) {
+	if !identRe.MatchString(name) {
+		return nil, fmt.Errorf("record name %q is not a valid identifier", name)
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by storejs; DO NOT EDIT.\n")
+	fmt.Fprintf(&buf, "// Schema: %s\n", s)
+	buf.WriteString(runtime)
+	fmt.Fprintf(&buf, "\nexport function decode%s(data) {\n", name)
+	buf.WriteString("  const r = new StoreReader(data);\n")
+	buf.WriteString("  const rec = {};\n")
+	for _, f := range s.Fields() {
+		method, ok := readers[f.Kind]
+		if !ok {
+			return nil, fmt.Errorf("field %s has unsupported kind %s", f.Name, f.Kind)
+		}
+		prop := "." + f.Name
+		if !identRe.MatchString(f.Name) {
+			prop = fmt.Sprintf("[%q]", f.Name)
+		}
+		fmt.Fprintf(&buf, "  rec%s = r.%s(%q);\n", prop, method, f.Name)
+	}
+	buf.WriteString("  r.done();\n")
+	buf.WriteString("  return rec;\n")
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+// runtime is the decoding support emitted ahead of each decoder function. Its
+// methods mirror those of store.GetBuffer.
+const runtime = `
+class StoreReader {
+  constructor(data) {
+    this.data = data;
+    this.pos = 0;
+  }
+
+  fail(field, msg) {
+    throw new Error("unpacking " + field + " at offset " + this.pos + ": " + msg);
+  }
+
+  byte(field) {
+    if (this.pos >= this.data.length) {
+      this.fail(field, "short buffer");
+    }
+    return this.data[this.pos++];
+  }
+
+  uvarint(field) {
+    let val = 0n;
+    for (let shift = 0n; shift < 70n; shift += 7n) {
+      const b = this.byte(field);
+      val |= BigInt(b & 0x7f) << shift;
+      if (b < 0x80) {
+        if (val >= 1n << 64n) {
+          break;
+        }
+        return val;
+      }
+    }
+    this.fail(field, "variable-length integer overflows 64 bits");
+  }
+
+  varint(field) {
+    const u = this.uvarint(field);
+    return (u >> 1n) ^ -(u & 1n);
+  }
+
+  uint64(field) {
+    return this.uvarint(field);
+  }
+
+  int64(field) {
+    return this.varint(field);
+  }
+
+  uint32(field) {
+    const u = this.uvarint(field);
+    if (u > 0xffffffffn) {
+      this.fail(field, "unpacked value " + u + " is out of range for uint32");
+    }
+    return Number(u);
+  }
+
+  int32(field) {
+    const s = this.varint(field);
+    if (s < -0x80000000n || s > 0x7fffffffn) {
+      this.fail(field, "unpacked value " + s + " is out of range for int32");
+    }
+    return Number(s);
+  }
+
+  uint16(field) {
+    const u = this.uvarint(field);
+    if (u > 0xffffn) {
+      this.fail(field, "unpacked value " + u + " is out of range for uint16");
+    }
+    return Number(u);
+  }
+
+  int16(field) {
+    const s = this.varint(field);
+    if (s < -0x8000n || s > 0x7fffn) {
+      this.fail(field, "unpacked value " + s + " is out of range for int16");
+    }
+    return Number(s);
+  }
+
+  uint8(field) {
+    return this.byte(field);
+  }
+
+  int8(field) {
+    return (this.byte(field) << 24) >> 24;
+  }
+
+  time(field) {
+    return new Date(Number(this.varint(field)) * 1000);
+  }
+
+  bytes(field) {
+    const n = Number(this.uvarint(field));
+    if (n > this.data.length - this.pos) {
+      this.fail(field, "short buffer");
+    }
+    const sl = this.data.slice(this.pos, this.pos + n);
+    this.pos += n;
+    return sl;
+  }
+
+  str(field) {
+    return new TextDecoder("utf-8").decode(this.bytes(field));
+  }
+
+  done() {
+    if (this.pos != this.data.length) {
+      throw new Error("the get buffer has not been completely emptied");
+    }
+  }
+}
+`
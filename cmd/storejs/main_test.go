@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piniondb/store"
+)
+
+const orderSchema = "id:uint64, placed:time, qty:int16, flag:uint8, delta:int8, note:str, key:bytes, count:uint32, adj:int64"
+
+// Ensure that the committed sample output matches the generator
+func TestGenerate(t *testing.T) {
+	s, err := store.ParseSchema(orderSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate("Order", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile(filepath.Join("testdata", "order.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Fatal("generated source differs from testdata/order.js")
+	}
+	if _, err = generate("bad name", s); err == nil {
+		t.Fatal("invalid record name not reported")
+	}
+	s, _ = store.ParseSchema("port:uint16")
+	if src, err = generate("Port", s); err != nil || !bytes.Contains(src, []byte(`r.uint16("port")`)) {
+		t.Fatalf("uint16 field not decoded with uint16 reader: %v", err)
+	}
+}
+
+// Ensure that the generated decoder agrees with GetBuffer when Node.js is
+// available
+func TestGenerate_Node(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+	src, err := ioutil.ReadFile(filepath.Join("testdata", "order.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Int16 and Int32, like Uint32 and Uint64, pack the same bytes, so the
+	// wider kinds can supply qty and count values that are out of range
+	pack := func(qty int32, count uint64) []byte {
+		var put store.PutBuffer
+		put.Uint64(1 << 60)
+		put.Time(time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC))
+		put.Int32(qty)
+		put.Uint8(200)
+		put.Int8(-5)
+		put.Str("gear ⚙")
+		put.Bytes([]byte{1, 2, 3})
+		put.Uint64(count)
+		put.Int64(-1 << 62)
+		data, err := put.Data()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	data, wide, large := pack(-300, 1<<31), pack(-70000, 1<<31), pack(-300, 1<<32)
+	var qty int16
+	get := store.NewGetBuffer(wide)
+	get.SetStrict(true)
+	get.Uint64(new(uint64))
+	get.Time(new(time.Time))
+	get.Int16(&qty)
+	if !errors.Is(get.Error(), store.ErrValueRange) {
+		t.Fatalf("out of range int16 not reported by GetBuffer: %v", get.Error())
+	}
+	var count uint32
+	get.Reset(large)
+	get.Uint64(new(uint64))
+	get.Time(new(time.Time))
+	get.Int16(&qty)
+	get.Uint8(new(uint8))
+	get.Int8(new(int8))
+	get.Str(new(string))
+	get.Bytes(new([]byte))
+	get.Uint32(&count)
+	if !errors.Is(get.Error(), store.ErrValueRange) {
+		t.Fatalf("out of range uint32 not reported by GetBuffer: %v", get.Error())
+	}
+	dir := t.TempDir()
+	script := fmt.Sprintf(`import { decodeOrder } from "./order.mjs";
+const rec = decodeOrder(new Uint8Array(%s));
+console.log(JSON.stringify(rec, (k, v) => typeof v === "bigint" ? v.toString() :
+  v instanceof Uint8Array ? Array.from(v) : v));
+for (const bad of [new Uint8Array(%[1]s).subarray(0, 20), new Uint8Array(%s), new Uint8Array(%s)]) {
+  try {
+    decodeOrder(bad);
+  } catch (e) {
+    console.log(e.message);
+  }
+}
+`, strings.Join(strings.Fields(fmt.Sprint(data)), ","), strings.Join(strings.Fields(fmt.Sprint(wide)), ","),
+		strings.Join(strings.Fields(fmt.Sprint(large)), ","))
+	for name, content := range map[string][]byte{"order.mjs": src, "main.mjs": []byte(script)} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := exec.Command(node, filepath.Join(dir, "main.mjs")).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	want := `{"id":"1152921504606846976","placed":"2016-05-01T12:00:00.000Z","qty":-300,` +
+		`"flag":200,"delta":-5,"note":"gear ⚙","key":[1,2,3],"count":2147483648,"adj":"-4611686018427387904"}
+unpacking note at offset 19: short buffer
+unpacking qty at offset 17: unpacked value -70000 is out of range for int16
+unpacking count at offset 36: unpacked value 4294967296 is out of range for uint32
+`
+	if string(out) != want {
+		t.Fatalf("unexpected node output:\n%s", out)
+	}
+}
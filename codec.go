@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Codec holds the functions that pack and unpack values of type T. Enc
+// packs a value into a storage buffer and Dec unpacks it in the same order.
+type Codec[T any] struct {
+	Enc func(put *PutBuffer, val T)
+	Dec func(get *GetBuffer, val *T)
+}
+
+// Put returns the packed form of val.
+func (c Codec[T]) Put(val T) ([]byte, error) {
+	var put PutBuffer
+	c.Enc(&put, val)
+	return put.DataUnsafe()
+}
+
+// Get unpacks a value from data. An error is returned if data is not fully
+// consumed.
+func (c Codec[T]) Get(data []byte) (val T, err error) {
+	get := NewGetBuffer(data)
+	c.Dec(get, &val)
+	err = get.Done()
+	return
+}
+
+// codecs maps a nil *T to the Codec[T] registered for type T. Keying by a
+// typed nil pointer distinguishes types without the use of reflection.
+var codecs sync.Map
+
+// RegisterCodec associates c with type T for use by Put, Get and
+// LookupCodec. A later registration for the same type replaces an earlier
+// one.
+func RegisterCodec[T any](c Codec[T]) {
+	codecs.Store((*T)(nil), c)
+}
+
+// LookupCodec returns the codec registered for type T, so that code that is
+// generic over record types, such as containers and record writers, can pack
+// and unpack values without reflection. If no codec has been registered but
+// *T implements both Putter and Getter, as types processed by storegen do, a
+// codec that uses those methods is returned. The second return value is false
+// if neither applies.
+func LookupCodec[T any]() (c Codec[T], ok bool) {
+	var val interface{}
+	if val, ok = codecs.Load((*T)(nil)); ok {
+		return val.(Codec[T]), true
+	}
+	if _, ok = interface{}(new(T)).(interface {
+		Putter
+		Getter
+	}); ok {
+		c.Enc = func(put *PutBuffer, val T) {
+			interface{}(&val).(Putter).StorePut(put)
+		}
+		c.Dec = func(get *GetBuffer, val *T) {
+			interface{}(val).(Getter).StoreGet(get)
+		}
+	}
+	return
+}
+
+func lookupCodec[T any]() (c Codec[T], err error) {
+	var ok bool
+	if c, ok = LookupCodec[T](); !ok {
+		var zero T
+		err = fmt.Errorf("no codec registered for type %T", zero)
+	}
+	return
+}
+
+// Put returns the packed form of val using the codec registered for type T.
+func Put[T any](val T) ([]byte, error) {
+	c, err := lookupCodec[T]()
+	if err != nil {
+		return nil, err
+	}
+	return c.Put(val)
+}
+
+// Get unpacks a value of type T from data using the codec registered for
+// that type.
+func Get[T any](data []byte) (val T, err error) {
+	var c Codec[T]
+	if c, err = lookupCodec[T](); err == nil {
+		val, err = c.Get(data)
+	}
+	return
+}
+
+// Putter is implemented by types that pack themselves into a storage buffer,
+// such as those for which methods are generated by the storegen command.
+type Putter interface {
+	StorePut(put *PutBuffer)
+}
+
+// Getter is implemented by types that unpack themselves from a storage
+// buffer. StoreGet must mirror the corresponding StorePut method.
+type Getter interface {
+	StoreGet(get *GetBuffer)
+}
+
+// Marshal returns the packed form of p. It is suitable for implementing the
+// encoding.BinaryMarshaler interface.
+func Marshal(p Putter) ([]byte, error) {
+	var put PutBuffer
+	p.StorePut(&put)
+	return put.DataUnsafe()
+}
+
+// Unmarshal unpacks g from data, which must be consumed completely. It is
+// suitable for implementing the encoding.BinaryUnmarshaler interface.
+func Unmarshal(data []byte, g Getter) error {
+	get := NewGetBuffer(data)
+	g.StoreGet(get)
+	return get.Done()
+}
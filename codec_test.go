@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+type codecPoint struct {
+	name string
+	x, y int32
+}
+
+// ExampleRegisterCodec demonstrates one-call packing and unpacking of a
+// record type with a registered codec.
+func ExampleRegisterCodec() {
+	RegisterCodec(Codec[codecPoint]{
+		Enc: func(put *PutBuffer, p codecPoint) {
+			put.Str(p.name)
+			put.Int32(p.x)
+			put.Int32(p.y)
+		},
+		Dec: func(get *GetBuffer, p *codecPoint) {
+			get.Str(&p.name)
+			get.Int32(&p.x)
+			get.Int32(&p.y)
+		},
+	})
+	data, err := Put(codecPoint{"origin", 0, -1})
+	if err == nil {
+		var p codecPoint
+		p, err = Get[codecPoint](data)
+		if err == nil {
+			fmt.Printf("% x\n%s %d %d\n", data, p.name, p.x, p.y)
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// 06 6f 72 69 67 69 6e 00 01
+	// origin 0 -1
+}
+
+// Ensure that a missing codec and trailing data are reported
+func TestGet_Codec(t *testing.T) {
+	type unregistered struct{}
+	if _, err := Put(unregistered{}); err == nil {
+		t.Fatal("missing codec not reported")
+	}
+	if _, err := Get[unregistered](nil); err == nil {
+		t.Fatal("missing codec not reported")
+	}
+	c := Codec[uint16]{
+		Enc: func(put *PutBuffer, v uint16) { put.Uint16(v) },
+		Dec: func(get *GetBuffer, v *uint16) { get.Uint16(v) },
+	}
+	data, err := c.Put(300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Get(append(data, 0)); err == nil {
+		t.Fatal("trailing data not reported")
+	}
+	if v, err := c.Get(data); err != nil || v != 300 {
+		t.Fatalf("expecting 300, got %d (%v)", v, err)
+	}
+}
+
+type codecSelf struct {
+	n uint16
+}
+
+func (c *codecSelf) StorePut(put *PutBuffer) { put.Uint16(c.n) }
+func (c *codecSelf) StoreGet(get *GetBuffer) { get.Uint16(&c.n) }
+
+// Ensure that LookupCodec finds registered codecs and self-packing types
+func TestLookupCodec(t *testing.T) {
+	if _, ok := LookupCodec[struct{ a int }](); ok {
+		t.Fatal("codec reported for unregistered type")
+	}
+	RegisterCodec(Codec[int16]{
+		Enc: func(put *PutBuffer, v int16) { put.Int16(v) },
+		Dec: func(get *GetBuffer, v *int16) { get.Int16(v) },
+	})
+	if c, ok := LookupCodec[int16](); !ok || c.Enc == nil {
+		t.Fatal("registered codec not found")
+	}
+	c, ok := LookupCodec[codecSelf]()
+	if !ok {
+		t.Fatal("codec not derived from Putter and Getter")
+	}
+	data, err := c.Put(codecSelf{300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := Get[codecSelf](data); err != nil || v.n != 300 {
+		t.Fatalf("expecting 300, got %d (%v)", v.n, err)
+	}
+}
@@ -0,0 +1,228 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"time"
+)
+
+// ColumnWriter packs a batch of records that conform to a schema in column
+// order: the values of each field are stored contiguously rather than record
+// by record. Integer and time values are stored as the variable-length
+// difference from the preceding value in their column, so slowly changing
+// series such as timestamps, counters and sensor readings pack into one or
+// two bytes per value. Strings and byte sequences are stored as usual. Use a
+// ColumnReader to recover the records.
+type ColumnWriter struct {
+	s    *Schema
+	cols []PutBuffer
+	prev []uint64
+	n    uint64
+	err  error
+}
+
+// NewColumnWriter returns a column writer for records that conform to s.
+func NewColumnWriter(s *Schema) *ColumnWriter {
+	return &ColumnWriter{s: s, cols: make([]PutBuffer, len(s.fields)), prev: make([]uint64, len(s.fields))}
+}
+
+// bits returns the integer form of val, a value of an integer or time kind,
+// with signed values sign-extended to 64 bits.
+func bits(val interface{}) uint64 {
+	switch v := val.(type) {
+	case time.Time:
+		return uint64(v.Unix())
+	case uint64:
+		return v
+	case int64:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case int32:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case int16:
+		return uint64(v)
+	case uint8:
+		return uint64(v)
+	case int8:
+		return uint64(v)
+	}
+	return 0
+}
+
+// fromBits returns the value of kind k with integer form u. The second
+// return value is false if u is out of range for k.
+func (k Kind) fromBits(u uint64) (val interface{}, ok bool) {
+	switch k {
+	case KindTime:
+		val = time.Unix(int64(u), 0)
+	case KindUint64:
+		val = u
+	case KindInt64:
+		val = int64(u)
+	case KindUint32:
+		val = uint32(u)
+	case KindInt32:
+		val = int32(u)
+	case KindUint16:
+		val = uint16(u)
+	case KindInt16:
+		val = int16(u)
+	case KindUint8:
+		val = uint8(u)
+	case KindInt8:
+		val = int8(u)
+	}
+	return val, bits(val) == u
+}
+
+// Add appends rec, a record packed in the form described by the writer's
+// schema, to the receiving batch. Once an error has occurred, further
+// records are ignored and the error is returned by Data.
+func (cw *ColumnWriter) Add(rec []byte) {
+	if cw.err != nil {
+		return
+	}
+	get := NewGetBuffer(rec)
+	vals := make([]interface{}, len(cw.s.fields))
+	for j, f := range cw.s.fields {
+		vals[j] = f.get(get)
+	}
+	if cw.err = get.Done(); cw.err != nil {
+		return
+	}
+	for j, f := range cw.s.fields {
+		switch f.Kind {
+		case KindStr, KindBytes:
+			f.put(&cw.cols[j], vals[j])
+		default:
+			u := bits(vals[j])
+			cw.cols[j].Int64(int64(u - cw.prev[j]))
+			cw.prev[j] = u
+		}
+	}
+	cw.n++
+}
+
+// Len returns the number of records in the receiving batch.
+func (cw *ColumnWriter) Len() int {
+	return int(cw.n)
+}
+
+// Data returns the packed batch in the form of a byte slice. The batch holds
+// the fingerprint of the schema, the number of records and the packed
+// columns. The second return value is an error code that will be nil if all
+// records have been successfully added.
+func (cw *ColumnWriter) Data() ([]byte, error) {
+	var put PutBuffer
+	put.SetError(cw.err)
+	put.Fingerprint(cw.s)
+	put.Uint64(cw.n)
+	for j := range cw.cols {
+		col, err := cw.cols[j].DataUnsafe()
+		if err != nil {
+			put.SetError(err)
+		}
+		put.Bytes(col)
+	}
+	return put.DataUnsafe()
+}
+
+// ColumnReader extracts records from a byte sequence that was generated using
+// a ColumnWriter.
+type ColumnReader struct {
+	s    *Schema
+	cols []*GetBuffer
+	prev []uint64
+	n    uint64
+	put  PutBuffer
+	err  error
+}
+
+// NewColumnReader returns an initialized reader that can be used to extract
+// records that conform to s from data. An error that matches
+// ErrSchemaMismatch is reported by Done if the batch was packed with a
+// different schema.
+func NewColumnReader(s *Schema, data []byte) *ColumnReader {
+	cr := &ColumnReader{s: s, prev: make([]uint64, len(s.fields))}
+	get := NewGetBuffer(data)
+	get.Fingerprint(s)
+	get.Uint64(&cr.n)
+	for range s.fields {
+		var col []byte
+		get.Bytes(&col)
+		cr.cols = append(cr.cols, &GetBuffer{data: col})
+	}
+	cr.err = get.Done()
+	return cr
+}
+
+// Next advances the reader to the next record in the batch. It returns false
+// when no records remain or an error has occurred; call Done to distinguish
+// between these cases.
+func (cr *ColumnReader) Next() bool {
+	if cr.err != nil || cr.n == 0 {
+		return false
+	}
+	cr.put.Reset()
+	for j, f := range cr.s.fields {
+		get := cr.cols[j]
+		get.Field(f.Name)
+		switch f.Kind {
+		case KindStr, KindBytes:
+			f.put(&cr.put, f.get(get))
+		default:
+			var d int64
+			get.Int64(&d)
+			u := cr.prev[j] + uint64(d)
+			val, ok := f.Kind.fromBits(u)
+			if get.err == nil && !ok {
+				get.err = categoryErrorf(ErrValueRange, "unpacked value %d is out of range for %s", int64(u), f.Kind)
+			}
+			cr.prev[j] = u
+			f.put(&cr.put, val)
+		}
+		if get.err != nil {
+			cr.err = get.context()
+			return false
+		}
+	}
+	cr.n--
+	return true
+}
+
+// Record returns the current record in the form described by the reader's
+// schema. The returned slice is overwritten by the next call to Next.
+func (cr *ColumnReader) Record() []byte {
+	return cr.put.buf
+}
+
+// Done is called to indicate that the batch has been read. If no error has
+// occurred and no content remains in any column, nil is returned, otherwise
+// an appropriate error value.
+func (cr *ColumnReader) Done() error {
+	if cr.err == nil {
+		for _, get := range cr.cols {
+			if cr.err = get.Done(); cr.err != nil {
+				break
+			}
+		}
+	}
+	return cr.err
+}
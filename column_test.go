@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func ExampleColumnWriter() {
+	s, _ := ParseSchema("at:time, sensor:str, reading:int32")
+	cw := NewColumnWriter(s)
+	var rows int
+	start := time.Date(2016, 5, 1, 0, 0, 0, 0, time.UTC)
+	for j := 0; j < 1000; j++ {
+		var put PutBuffer
+		put.Time(start.Add(time.Duration(j) * time.Minute))
+		put.Str("t1")
+		put.Int32(int32(2000 + j%5))
+		rec, _ := put.Data()
+		rows += len(rec)
+		cw.Add(rec)
+	}
+	data, err := cw.Data()
+	fmt.Println(rows, len(data), err)
+	cr := NewColumnReader(s, data)
+	var count int
+	for cr.Next() {
+		count++
+	}
+	fmt.Println(count, cr.Done())
+	// Output:
+	// 10000 5017 <nil>
+	// 1000 <nil>
+}
+
+func TestColumnReader(t *testing.T) {
+	s, _ := ParseSchema("a:uint64, b:int8, c:bytes, d:uint8")
+	cw := NewColumnWriter(s)
+	var recs [][]byte
+	for _, vals := range []struct {
+		a uint64
+		b int8
+		d uint8
+	}{{1<<64 - 1, -128, 255}, {0, 127, 0}, {1 << 63, 0, 1}} {
+		var put PutBuffer
+		put.Uint64(vals.a)
+		put.Int8(vals.b)
+		put.Bytes([]byte{vals.d})
+		put.Uint8(vals.d)
+		rec, _ := put.Data()
+		recs = append(recs, rec)
+		cw.Add(rec)
+	}
+	data, err := cw.Data()
+	if err != nil || cw.Len() != 3 {
+		t.Fatal(err)
+	}
+	cr := NewColumnReader(s, data)
+	for j := 0; cr.Next(); j++ {
+		if !bytes.Equal(cr.Record(), recs[j]) {
+			t.Fatalf("record %d: expecting %x, got %x", j, recs[j], cr.Record())
+		}
+	}
+	if err = cr.Done(); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := ParseSchema("a:uint64, b:int8, c:bytes, d:uint16")
+	if cr = NewColumnReader(other, data); cr.Next() || !errors.Is(cr.Done(), ErrSchemaMismatch) {
+		t.Fatalf("expecting schema mismatch, got %v", cr.Done())
+	}
+	cw.Add([]byte{1})
+	if _, err = cw.Data(); err == nil {
+		t.Fatal("expecting error for malformed record")
+	}
+	// A delta that takes column d beyond the range of uint8
+	var put PutBuffer
+	put.Fingerprint(s)
+	put.Uint64(1)
+	for _, col := range [][]byte{{0}, {0}, {0}, {0x80, 0x04}} {
+		put.Bytes(col)
+	}
+	data, _ = put.Data()
+	if cr = NewColumnReader(s, data); cr.Next() || !errors.Is(cr.Done(), ErrValueRange) {
+		t.Fatalf("expecting range error, got %v", cr.Done())
+	}
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+)
+
+// Compressed content begins with envelopeMagic followed by a byte that
+// identifies the form of the content that follows. PutBuffer never packs the
+// leading bytes of the magic at the start of a variable-length integer, since
+// they would form a longer encoding of zero than necessary.
+const (
+	envelopeMagic = "\xff\x00z"
+	envelopeRaw   = 0
+	envelopeFlate = 1
+)
+
+// inflateMax limits the length of decompressed content when no total
+// allocation limit has been assigned to a get buffer.
+const inflateMax = 64 << 20
+
+var (
+	errEnvelope       = errors.New("unrecognized compression envelope")
+	errEnvelopeReader = errors.New("envelopes are not supported by get buffers that read from an io.Reader")
+)
+
+// isEnvelope reports whether data begins with a compression header.
+func isEnvelope(data []byte) bool {
+	return len(data) > len(envelopeMagic) && string(data[:len(envelopeMagic)]) == envelopeMagic
+}
+
+// SetCompression assigns the length at or above which the packed fields of
+// the receiving storage buffer are compressed with DEFLATE when they are
+// retrieved with Data, DataUnsafe, AppendTo or WriteTo. A threshold of zero,
+// the default, disables compression. Compressed content begins with a short
+// header that a GetBuffer with compression enabled recognizes, so such a
+// reader handles compressed and uncompressed content alike; see
+// GetBuffer.SetCompression. Content below the threshold, or that does not
+// shrink, is stored as is. The compressed form is retained until the buffer is
+// next modified, so retrieving it repeatedly does not compress it again.
+// Compression does not affect Len, dry run mode or the bytes copied to a tee
+// writer.
+func (put *PutBuffer) SetCompression(threshold int) {
+	put.compress = threshold
+	put.out = nil
+}
+
+// output returns the packed fields of put in the form selected by
+// SetCompression and SetChecksum. Any envelope is retained in put.out until
+// more values are packed or the buffer is otherwise modified.
+func (put *PutBuffer) output() []byte {
+	if put.compress == 0 && !put.checksum {
+		return put.buf
+	}
+	if put.out == nil || put.outLen != len(put.buf) {
+		out := put.buf
+		if put.compress > 0 {
+			out = put.compressed()
+		}
+		if put.checksum {
+			out = appendChecksum(make([]byte, 0, len(out)+4), out)
+		}
+		put.out, put.outLen = out, len(put.buf)
+	}
+	return put.out
+}
+
+// compressed returns the packed fields of put, compressed if that makes them
+// shorter. Content that begins with the compression header is stored in an
+// envelope so that a reader does not mistake it for compressed content.
+func (put *PutBuffer) compressed() []byte {
+	if len(put.buf) >= put.compress {
+		if out, ok := deflate(append([]byte(envelopeMagic), envelopeFlate), put.buf); ok {
+			return out
+		}
+	}
+	if isEnvelope(put.buf) {
+		out := make([]byte, 0, len(envelopeMagic)+1+len(put.buf))
+		out = append(append(out, envelopeMagic...), envelopeRaw)
+		return append(out, put.buf...)
+	}
+	return put.buf
+}
+
+// deflate appends the compressed form of src to dst. The second return value
+// is false if the compressed form is not shorter than src.
+func deflate(dst, src []byte) ([]byte, bool) {
+	b := bytes.NewBuffer(dst)
+	fw, _ := flate.NewWriter(b, flate.DefaultCompression)
+	fw.Write(src)
+	fw.Close()
+	return b.Bytes(), b.Len()-len(dst) < len(src)
+}
+
+// inflate returns the decompressed form of src. If limit is positive, an
+// error that matches ErrLimitExceeded is returned if the decompressed form
+// would exceed limit bytes.
+func inflate(src []byte, limit uint64) ([]byte, error) {
+	var r io.Reader = flate.NewReader(bytes.NewReader(src))
+	if limit > 0 {
+		r = io.LimitReader(r, int64(limit)+1)
+	}
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	if limit > 0 && uint64(b.Len()) > limit {
+		return nil, categoryErrorf(ErrLimitExceeded, "decompressed content exceeds limit of %d bytes", limit)
+	}
+	return b.Bytes(), nil
+}
+
+// SetCompression controls whether the receiving get buffer recognizes content
+// compressed by a PutBuffer; see PutBuffer.SetCompression. By default it does
+// not. When it is enabled, content that is currently loaded and has not yet
+// been read, as well as content subsequently loaded with Reset or ReadFrom,
+// is decompressed before values are unpacked from it if it begins with a
+// compression header. Decompressed content is limited to the total
+// allocation limit assigned with SetLimits or, if none has been assigned, to
+// 64 MiB. The internal error is set if the content is not a valid compression
+// envelope or if the buffer was returned by NewGetReader. Enable compression only for content written by a PutBuffer with
+// compression enabled: a record packed without it whose first value has a
+// fixed width, such as one packed with Uint8 or Uint32Fixed, could begin with
+// the bytes of a compression header.
+func (get *GetBuffer) SetCompression(enabled bool) {
+	get.inflate = enabled
+	get.reload()
+}
+
+// load retains the content just loaded into get and, if it may hold a
+// compression or checksum envelope, replaces it with the content of the
+// envelope.
+func (get *GetBuffer) load() {
+	if get.inflate || get.verify {
+		get.loaded = get.data
+		get.unwrap()
+	}
+}
+
+// reload applies the current envelope settings to content that is loaded but
+// has not yet been read.
+func (get *GetBuffer) reload() {
+	switch {
+	case get.rd != nil:
+		if get.inflate || get.verify {
+			get.err = errEnvelopeReader
+		}
+	case get.pos == 0 && get.index == 0 && !get.errSet:
+		// Any error is the result of applying the previous settings
+		get.err = nil
+		if get.loaded != nil {
+			get.data = get.loaded
+		}
+		get.load()
+	}
+}
+
+// unwrap sets the content of get to that of the envelope held by
+// get.loaded, verifying its checksum and decompressing it as configured.
+func (get *GetBuffer) unwrap() {
+	data := get.loaded
+	if get.verify {
+		if data, get.err = verifyChecksum(data); get.err != nil {
+			return
+		}
+	}
+	if get.inflate && isEnvelope(data) {
+		body := data[len(envelopeMagic)+1:]
+		switch data[len(envelopeMagic)] {
+		case envelopeRaw:
+			data = body
+		case envelopeFlate:
+			limit := get.totalMax
+			if limit == 0 {
+				limit = inflateMax
+			}
+			if data, get.err = inflate(body, limit); get.err != nil {
+				return
+			}
+		default:
+			get.err = errEnvelope
+			return
+		}
+	}
+	get.data = data
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func ExamplePutBuffer_SetCompression() {
+	var put PutBuffer
+	put.SetCompression(256)
+	put.Uint32(42)
+	put.Str(strings.Repeat("the quick brown fox ", 50))
+	data, _ := put.Data()
+	fmt.Println(put.Len(), len(data) < put.Len())
+	get := NewGetBuffer(data)
+	get.SetCompression(true)
+	var id uint32
+	var text string
+	get.Uint32(&id)
+	get.Str(&text)
+	fmt.Println(id, len(text), get.Done())
+	// Output:
+	// 1003 true
+	// 42 1000 <nil>
+}
+
+func TestGetBuffer_SetCompression(t *testing.T) {
+	var put PutBuffer
+	put.SetCompression(256)
+	put.Str("short")
+	small, _ := put.DataUnsafe()
+	if !bytes.Equal(small, put.buf) {
+		t.Fatalf("unexpected envelope %x", small)
+	}
+	put.Bytes(bytes.Repeat([]byte{7}, 1000))
+	var b bytes.Buffer
+	if _, err := put.WriteTo(&b); err != nil || !isEnvelope(b.Bytes()) || b.Bytes()[3] != envelopeFlate {
+		t.Fatalf("expecting compressed content: %v", err)
+	}
+	big, _ := put.AppendTo(nil)
+	if !bytes.Equal(big, b.Bytes()) || put.Clone().compress != 256 {
+		t.Fatal("inconsistent output")
+	}
+	first, _ := put.DataUnsafe()
+	second, _ := put.DataUnsafe()
+	if &first[0] != &second[0] {
+		t.Fatal("compressed content not retained")
+	}
+	get := NewGetBuffer(nil)
+	get.SetCompression(true)
+	for _, data := range [][]byte{small, big} {
+		get.Reset(data)
+		var str string
+		var sl []byte
+		get.Str(&str)
+		if len(data) == len(big) {
+			get.Bytes(&sl)
+		}
+		if err := get.Done(); err != nil || str != "short" {
+			t.Fatalf("unexpected result %q: %v", str, err)
+		}
+	}
+	get.SetLimits(0, 100)
+	get.Reset(big)
+	if err := get.Done(); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expecting limit exceeded, got %v", err)
+	}
+	get.SetLimits(0, 0)
+	for _, data := range [][]byte{[]byte(envelopeMagic + "\x09\x01"), []byte(envelopeMagic + "\x01\x01\x02")} {
+		get.Reset(data)
+		if get.Done() == nil {
+			t.Fatalf("expecting error for envelope %x", data)
+		}
+	}
+	get.SetCompression(false)
+	get.Reset(big)
+	var marker uint8
+	get.Uint8(&marker)
+	if err := get.Error(); err != nil || marker != envelopeMagic[0] {
+		t.Fatalf("unexpected result %d with compression disabled: %v", marker, err)
+	}
+	get = NewGetReader(bytes.NewReader(big))
+	get.SetCompression(true)
+	if get.Done() != errEnvelopeReader {
+		t.Fatal("expecting error for reader")
+	}
+}
+
+// Ensure that content that begins with the compression header is not
+// mistaken for compressed content
+func TestPutBuffer_SetCompressionMagic(t *testing.T) {
+	var put PutBuffer
+	put.SetCompression(256)
+	put.Raw([]byte(envelopeMagic))
+	put.Uint8(envelopeFlate)
+	data, err := put.Data()
+	if err != nil || len(data) != 8 {
+		t.Fatalf("expecting stored envelope, got %x: %v", data, err)
+	}
+	var sl []byte
+	get := NewGetBuffer(data)
+	get.SetCompression(true)
+	get.Raw(4, &sl)
+	if err = get.Done(); err != nil || string(sl) != envelopeMagic+"\x01" {
+		t.Fatalf("unexpected content %x: %v", sl, err)
+	}
+}
+
+// Ensure that content is not taken for a compression envelope unless
+// compression is enabled and that decompression is bounded by default
+func TestGetBuffer_SetCompressionDefault(t *testing.T) {
+	var put PutBuffer
+	put.Raw([]byte(envelopeMagic))
+	put.Uint8(envelopeRaw)
+	put.Str("hello")
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var str string
+	get := NewGetBuffer(data)
+	get.Raw(4, new([]byte))
+	get.Str(&str)
+	if err = get.Done(); err != nil || str != "hello" {
+		t.Fatalf("unexpected result %q: %v", str, err)
+	}
+	data, _ = deflate(append([]byte(envelopeMagic), envelopeFlate), make([]byte, inflateMax+1))
+	get = NewGetBuffer(data)
+	get.SetCompression(true)
+	if err = get.Done(); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expecting limit exceeded, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+	"io"
+)
+
+// columnBatchLen is the number of records per column batch used by
+// RowsToColumns when no positive batch length is specified.
+const columnBatchLen = 1024
+
+// RowsToColumns converts a row-oriented record stream to a columnar one. It
+// reads the records of the stream src, which was written by a RecordWriter
+// and whose records each conform to s, and writes them to dst as a record
+// stream in which each record is a batch of up to batchLen records packed by
+// a ColumnWriter. A batchLen that is not positive selects a default of 1024.
+// Batches should be kept small enough to remain within the limit of the
+// RecordReader that will read them back. The first error encountered is
+// returned; an error in a record of src identifies the record by its
+// zero-based position in the stream.
+func RowsToColumns(dst io.Writer, src io.Reader, s *Schema, batchLen int) error {
+	if batchLen <= 0 {
+		batchLen = columnBatchLen
+	}
+	rr := NewRecordReader(src)
+	rw := NewRecordWriter(dst)
+	cw := NewColumnWriter(s)
+	flush := func() error {
+		data, err := cw.Data()
+		if err == nil {
+			err = rw.Write(data)
+		}
+		cw = NewColumnWriter(s)
+		return err
+	}
+	for pos := 0; rr.Next(); pos++ {
+		if cw.Add(rr.Record()); cw.err != nil {
+			return fmt.Errorf("record %d: %w", pos, cw.err)
+		}
+		if cw.Len() == batchLen {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rr.Done(); err != nil {
+		return err
+	}
+	if cw.Len() > 0 {
+		return flush()
+	}
+	return nil
+}
+
+// ColumnsToRows reverses RowsToColumns. It reads the column batches of the
+// record stream src, each packed by a ColumnWriter with schema s, and writes
+// their records in order to dst as a row-oriented record stream that can be
+// read with a RecordReader. The first error encountered is returned; an
+// error in a batch, such as one that matches ErrSchemaMismatch if it was
+// packed with a different schema, identifies the batch by its zero-based
+// position in the stream.
+func ColumnsToRows(dst io.Writer, src io.Reader, s *Schema) error {
+	rr := NewRecordReader(src)
+	rw := NewRecordWriter(dst)
+	for pos := 0; rr.Next(); pos++ {
+		cr := NewColumnReader(s, rr.Record())
+		for cr.Next() {
+			if err := rw.Write(cr.Record()); err != nil {
+				return err
+			}
+		}
+		if err := cr.Done(); err != nil {
+			return fmt.Errorf("batch %d: %w", pos, err)
+		}
+	}
+	return rr.Done()
+}
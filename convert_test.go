@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func ExampleRowsToColumns() {
+	s, _ := ParseSchema("id:uint32, name:str")
+	var rows bytes.Buffer
+	rw := NewRecordWriter(&rows)
+	for j, name := range []string{"ann", "bob", "cy"} {
+		var put PutBuffer
+		put.Uint32(uint32(100 + j))
+		put.Str(name)
+		rw.Put(&put)
+	}
+	var cols, back bytes.Buffer
+	fmt.Println(RowsToColumns(&cols, bytes.NewReader(rows.Bytes()), s, 2))
+	fmt.Println(ColumnsToRows(&back, &cols, s))
+	fmt.Println(bytes.Equal(rows.Bytes(), back.Bytes()))
+	// Output:
+	// <nil>
+	// <nil>
+	// true
+}
+
+func TestRowsToColumns(t *testing.T) {
+	s, _ := ParseSchema("a:int64, b:bytes")
+	var rows bytes.Buffer
+	rw := NewRecordWriter(&rows)
+	for j := 0; j < 10; j++ {
+		var put PutBuffer
+		put.Int64(int64(j * j))
+		put.Bytes(bytes.Repeat([]byte{byte(j)}, j))
+		rw.Put(&put)
+	}
+	for _, batchLen := range []int{0, 1, 3, 10, 11} {
+		var cols, back bytes.Buffer
+		if err := RowsToColumns(&cols, bytes.NewReader(rows.Bytes()), s, batchLen); err != nil {
+			t.Fatal(err)
+		}
+		want := 1
+		if batchLen > 0 {
+			want = (10 + batchLen - 1) / batchLen
+		}
+		var batches int
+		rr := NewRecordReader(bytes.NewReader(cols.Bytes()))
+		for rr.Next() {
+			batches++
+		}
+		if err := rr.Done(); err != nil || batches != want {
+			t.Fatalf("batch length %d: expecting %d batches, got %d, %v", batchLen, want, batches, err)
+		}
+		if err := ColumnsToRows(&back, &cols, s); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(rows.Bytes(), back.Bytes()) {
+			t.Fatalf("batch length %d: row stream does not survive conversion", batchLen)
+		}
+	}
+
+	// An empty stream converts to an empty stream
+	var cols bytes.Buffer
+	if err := RowsToColumns(&cols, bytes.NewReader(nil), s, 0); err != nil || cols.Len() != 0 {
+		t.Fatalf("expecting empty stream, got %d bytes, %v", cols.Len(), err)
+	}
+
+	// A row that does not conform to the schema is identified
+	good := append([]byte(nil), rows.Bytes()...)
+	rw = NewRecordWriter(&rows)
+	rw.Write([]byte{1})
+	err := RowsToColumns(&cols, bytes.NewReader(rows.Bytes()), s, 4)
+	if !errors.Is(err, ErrShortBuffer) || err.Error()[:10] != "record 10:" {
+		t.Fatalf("expecting short buffer error in record 10, got %v", err)
+	}
+
+	// A torn row stream is reported
+	err = RowsToColumns(&cols, bytes.NewReader(good[:len(good)-1]), s, 4)
+	if !errors.Is(err, ErrShortBuffer) {
+		t.Fatalf("expecting short buffer error, got %v", err)
+	}
+
+	// Batches packed with another schema are rejected
+	cols.Reset()
+	if err = RowsToColumns(&cols, bytes.NewReader(good), s, 4); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := ParseSchema("a:int64, b:str")
+	err = ColumnsToRows(new(bytes.Buffer), &cols, other)
+	if !errors.Is(err, ErrSchemaMismatch) || err.Error()[:8] != "batch 0:" {
+		t.Fatalf("expecting schema mismatch in batch 0, got %v", err)
+	}
+}
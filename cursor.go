@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+var (
+	errCursorInvalid = errors.New("cursor is malformed")
+	errCursorExpired = errors.New("cursor has expired")
+)
+
+// cursorVersion identifies the layout of a packed cursor.
+const cursorVersion = 1
+
+// Cursor records the position of a paginated range scan so that the scan can
+// be resumed in a later request. After holds the last key that was returned
+// to the client. Expires, if not zero, is the time after which the cursor is
+// no longer accepted.
+type Cursor struct {
+	After   []byte
+	Expires time.Time
+}
+
+// Start returns the smallest key that sorts after c.After. This is the
+// inclusive start of the range scan that resumes the paginated scan.
+func (c Cursor) Start() []byte {
+	return append(append([]byte(nil), c.After...), 0)
+}
+
+// Encode returns a URL-safe string that holds the receiving cursor. If ts is
+// not nil, the string is sealed by it so that alterations by a client are
+// detected by ParseCursor.
+func (c Cursor) Encode(ts *TokenSealer) (string, error) {
+	var put PutBuffer
+	put.Uint8(cursorVersion)
+	put.Bytes(c.After)
+	if c.Expires.IsZero() {
+		put.Uint8(0)
+	} else {
+		put.Uint8(1)
+		put.Time(c.Expires)
+	}
+	data, err := put.DataUnsafe()
+	if err != nil {
+		return "", err
+	}
+	if ts != nil {
+		return ts.Seal(data), nil
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// ParseCursor returns the cursor held by str, which was generated by
+// Cursor.Encode. ts must be the sealer, or nil, that was passed to Encode. An
+// error is returned if str is malformed or has been altered, or if the cursor
+// expired before now.
+func ParseCursor(str string, ts *TokenSealer, now time.Time) (c Cursor, err error) {
+	var data []byte
+	if ts != nil {
+		data, err = ts.Open(str)
+	} else {
+		data, err = base64.RawURLEncoding.DecodeString(str)
+	}
+	if err != nil {
+		return c, errCursorInvalid
+	}
+	var version, expires uint8
+	get := NewGetBuffer(data)
+	get.Uint8(&version)
+	get.Bytes(&c.After)
+	get.Uint8(&expires)
+	if expires == 1 {
+		get.Time(&c.Expires)
+	}
+	if get.Done() != nil || version != cursorVersion || expires > 1 {
+		return Cursor{}, errCursorInvalid
+	}
+	if !c.Expires.IsZero() && now.After(c.Expires) {
+		return Cursor{}, errCursorExpired
+	}
+	return
+}
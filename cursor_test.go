@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// Ensure that cursors are restored, validated and expired
+func TestCursor(t *testing.T) {
+	ts := NewTokenSealer([]byte("0123456789abcdef0123456789abcdef"))
+	key, err := Key(uint32(42), "last")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Cursor{After: key, Expires: timeTest.Add(time.Hour)}
+	for _, sealer := range []*TokenSealer{nil, ts} {
+		str, err := c.Encode(sealer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored, err := ParseCursor(str, sealer, timeTest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(restored.After, key) || !restored.Expires.Equal(c.Expires) {
+			t.Fatal("cursor not restored")
+		}
+		if _, err = ParseCursor(str, sealer, timeTest.Add(2*time.Hour)); err != errCursorExpired {
+			t.Fatalf("expired cursor not reported: %v", err)
+		}
+		if _, err = ParseCursor(str[1:], sealer, timeTest); err == nil {
+			t.Fatal("malformed cursor not reported")
+		}
+	}
+	str, err := Cursor{After: key}.Encode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseCursor(str, ts, timeTest); err == nil {
+		t.Fatal("unsealed cursor accepted by sealer")
+	}
+	if c, err = ParseCursor(str, nil, timeTest.Add(1000*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if start := c.Start(); bytes.Compare(start, key) <= 0 || !bytes.HasPrefix(start, key) {
+		t.Fatalf("unexpected start key %x", start)
+	}
+}
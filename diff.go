@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// spans returns the offset within data of each field of a record that
+// conforms to the receiving schema, followed by the length of data.
+func (s *Schema) spans(data []byte) ([]int, error) {
+	get := NewGetBuffer(data)
+	list := make([]int, 0, len(s.fields)+1)
+	for _, f := range s.fields {
+		list = append(list, get.pos)
+		f.get(get)
+	}
+	if err := get.Done(); err != nil {
+		return nil, err
+	}
+	return append(list, len(data)), nil
+}
+
+// Diff returns a patch that transforms record old into record new, both of
+// which conform to the receiving schema. The patch holds the schema's
+// fingerprint followed by the packed values of the fields that differ, each
+// preceded by the number of unchanged fields that precede it. Fields are
+// compared by their packed form, so the patch is usually much smaller than
+// new when few fields change. Use Apply to reproduce new.
+func (s *Schema) Diff(old, new []byte) ([]byte, error) {
+	oldSpans, err := s.spans(old)
+	if err != nil {
+		return nil, fmt.Errorf("old record: %w", err)
+	}
+	newSpans, err := s.spans(new)
+	if err != nil {
+		return nil, fmt.Errorf("new record: %w", err)
+	}
+	var put PutBuffer
+	put.Fingerprint(s)
+	var skip uint32
+	for j := range s.fields {
+		oldVal := old[oldSpans[j]:oldSpans[j+1]]
+		newVal := new[newSpans[j]:newSpans[j+1]]
+		if bytes.Equal(oldVal, newVal) {
+			skip++
+		} else {
+			put.Uint32(skip)
+			put.Raw(newVal)
+			skip = 0
+		}
+	}
+	return put.DataUnsafe()
+}
+
+// Apply returns the record produced by applying patch, which was returned by
+// Diff, to record old. Both the patch and old must conform to the receiving
+// schema. An error that matches ErrSchemaMismatch is returned if the patch
+// was produced with a different schema.
+func (s *Schema) Apply(old, patch []byte) ([]byte, error) {
+	spans, err := s.spans(old)
+	if err != nil {
+		return nil, fmt.Errorf("old record: %w", err)
+	}
+	var put PutBuffer
+	put.Grow(len(old))
+	get := NewGetBuffer(patch)
+	get.Fingerprint(s)
+	j := 0
+	for get.err == nil && get.more() {
+		var skip uint32
+		get.Uint32(&skip)
+		if get.err == nil && int(skip) >= len(s.fields)-j {
+			get.err = categoryErrorf(ErrValueRange, "patch refers to field beyond %d fields", len(s.fields))
+		}
+		if get.err == nil {
+			put.Raw(old[spans[j]:spans[j+int(skip)]])
+			j += int(skip)
+			pos := get.pos
+			s.fields[j].get(get)
+			put.Raw(patch[pos:get.pos])
+			j++
+		}
+	}
+	if err = get.Done(); err != nil {
+		return nil, err
+	}
+	put.Raw(old[spans[j]:])
+	return put.Data()
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func ExampleSchema_Diff() {
+	s, _ := ParseSchema("id:uint32, name:str, notes:str, qty:uint16")
+	old, _ := s.Encode(map[string]interface{}{
+		"id": uint32(42), "name": "gear", "notes": "a long note that does not change", "qty": uint16(3)})
+	new, _ := s.Encode(map[string]interface{}{
+		"id": uint32(42), "name": "gear", "notes": "a long note that does not change", "qty": uint16(4)})
+	patch, err := s.Diff(old, new)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	rec, err := s.Apply(old, patch)
+	fmt.Println(len(new), len(patch), bytes.Equal(rec, new), err)
+	// Output:
+	// 40 6 true <nil>
+}
+
+func TestSchema_Apply(t *testing.T) {
+	s, _ := ParseSchema("a:uint8, b:str, c:str")
+	old := []byte{1, 1, 'x', 1, 'y'}
+	for _, c := range []struct {
+		new   []byte
+		patch int
+	}{
+		{[]byte{1, 1, 'x', 1, 'y'}, 4},
+		{[]byte{2, 1, 'x', 1, 'y'}, 6},
+		{[]byte{1, 1, 'x', 2, 'y', 'z'}, 8},
+		{[]byte{2, 0, 0}, 10},
+	} {
+		patch, err := s.Diff(old, c.new)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec, err := s.Apply(old, patch)
+		if err != nil || !bytes.Equal(rec, c.new) || len(patch) != c.patch {
+			t.Fatalf("unexpected result %v %d %v", rec, len(patch), err)
+		}
+	}
+	if _, err := s.Diff(old, []byte{1}); err == nil {
+		t.Fatal("expecting error for malformed new record")
+	}
+	patch, _ := s.Diff(old, []byte{2, 1, 'x', 1, 'y'})
+	other, _ := ParseSchema("a:uint8, b:str, c:bytes, d:uint8")
+	if _, err := other.Apply([]byte{1, 1, 'x', 1, 'y', 0}, patch); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expecting schema mismatch, got %v", err)
+	}
+	bad := append(append([]byte(nil), patch[:4]...), 3, 0)
+	if _, err := s.Apply(old, bad); !errors.Is(err, ErrValueRange) {
+		t.Fatalf("expecting range error, got %v", err)
+	}
+}
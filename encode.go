@@ -0,0 +1,272 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var (
+	errDecodeTarget = errors.New("decode target must be a non-nil pointer")
+	timeType        = reflect.TypeOf(time.Time{})
+	putterType      = reflect.TypeOf((*Putter)(nil)).Elem()
+	getterType      = reflect.TypeOf((*Getter)(nil)).Elem()
+)
+
+// Encode returns the packed form of v, which is typically a struct or a
+// pointer to one, using reflection to walk its exported fields in declaration
+// order. Fields tagged with store:"-" are skipped. It is considerably slower
+// than packing with a PutBuffer directly, but is convenient for prototyping:
+// the output is identical to that of methods generated by storegen for the
+// same fields numbered in declaration order, so Encode can later be replaced
+// by generated or hand-written code without changing stored data.
+//
+// Values are packed as storegen packs them. Sized integers and strings use
+// the PutBuffer method of the same name, int and uint are packed as 64-bit
+// values, a bool is packed as one byte, a []byte with Bytes, a time.Time with
+// Time, a slice or map as a Uint32 count followed by its elements, an array as
+// its elements alone, and a pointer as a presence byte followed, if not nil,
+// by the value it refers to. A value whose pointer implements Putter is packed
+// with its StorePut method. Other kinds, such as floating point numbers and
+// interfaces, are reported as an error.
+func Encode(v interface{}) ([]byte, error) {
+	var put PutBuffer
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || rv.Kind() == reflect.Ptr {
+		return nil, errors.New("cannot encode nil value")
+	}
+	encodeValue(&put, rv)
+	return put.DataUnsafe()
+}
+
+// Decode unpacks data, which must have been packed by Encode or equivalent
+// code, into the value that v points to. data must be consumed completely.
+func Decode(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errDecodeTarget
+	}
+	get := NewGetBuffer(data)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	decodeValue(get, rv)
+	return get.Done()
+}
+
+func encodeValue(put *PutBuffer, v reflect.Value) {
+	if put.err != nil {
+		return
+	}
+	t := v.Type()
+	if !v.CanAddr() && (t.Kind() == reflect.Struct || t.Kind() == reflect.Array) {
+		// Copy into addressable memory so that pointer methods are found
+		pv := reflect.New(t).Elem()
+		pv.Set(v)
+		v = pv
+	}
+	if v.CanAddr() && t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(putterType) {
+		v.Addr().Interface().(Putter).StorePut(put)
+		return
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			put.Uint8(1)
+		} else {
+			put.Uint8(0)
+		}
+	case reflect.Int8:
+		put.Int8(int8(v.Int()))
+	case reflect.Int16:
+		put.Int16(int16(v.Int()))
+	case reflect.Int32:
+		put.Int32(int32(v.Int()))
+	case reflect.Int, reflect.Int64:
+		put.Int64(v.Int())
+	case reflect.Uint8:
+		put.Uint8(uint8(v.Uint()))
+	case reflect.Uint16:
+		put.Uint16(uint16(v.Uint()))
+	case reflect.Uint32:
+		put.Uint32(uint32(v.Uint()))
+	case reflect.Uint, reflect.Uint64:
+		put.Uint64(v.Uint())
+	case reflect.String:
+		put.Str(v.String())
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			put.Bytes(v.Bytes())
+			return
+		}
+		put.Uint32(uint32(v.Len()))
+		for j := 0; j < v.Len(); j++ {
+			encodeValue(put, v.Index(j))
+		}
+	case reflect.Array:
+		for j := 0; j < v.Len(); j++ {
+			encodeValue(put, v.Index(j))
+		}
+	case reflect.Map:
+		put.Uint32(uint32(v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			encodeValue(put, iter.Key())
+			encodeValue(put, iter.Value())
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			put.Uint8(0)
+		} else {
+			put.Uint8(1)
+			encodeValue(put, v.Elem())
+		}
+	case reflect.Struct:
+		if t == timeType {
+			put.Time(v.Interface().(time.Time))
+			return
+		}
+		for j := 0; j < t.NumField(); j++ {
+			if f := t.Field(j); f.PkgPath == "" && f.Tag.Get("store") != "-" {
+				encodeValue(put, v.Field(j))
+			}
+		}
+	default:
+		put.err = fmt.Errorf("cannot encode value of type %s", t)
+	}
+}
+
+// decodeValue unpacks into v, which must be settable.
+func decodeValue(get *GetBuffer, v reflect.Value) {
+	if get.err != nil {
+		return
+	}
+	t := v.Type()
+	if t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(getterType) {
+		v.Addr().Interface().(Getter).StoreGet(get)
+		return
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		var u uint8
+		get.Uint8(&u)
+		v.SetBool(u != 0)
+	case reflect.Int8:
+		var s int8
+		get.Int8(&s)
+		v.SetInt(int64(s))
+	case reflect.Int16:
+		var s int16
+		get.Int16(&s)
+		v.SetInt(int64(s))
+	case reflect.Int32:
+		var s int32
+		get.Int32(&s)
+		v.SetInt(int64(s))
+	case reflect.Int, reflect.Int64:
+		var s int64
+		get.Int64(&s)
+		v.SetInt(s)
+	case reflect.Uint8:
+		var u uint8
+		get.Uint8(&u)
+		v.SetUint(uint64(u))
+	case reflect.Uint16:
+		var u uint16
+		get.Uint16(&u)
+		v.SetUint(uint64(u))
+	case reflect.Uint32:
+		var u uint32
+		get.Uint32(&u)
+		v.SetUint(uint64(u))
+	case reflect.Uint, reflect.Uint64:
+		var u uint64
+		get.Uint64(&u)
+		v.SetUint(u)
+	case reflect.String:
+		var str string
+		get.Str(&str)
+		v.SetString(str)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			var sl []byte
+			get.Bytes(&sl)
+			v.SetBytes(sl)
+			return
+		}
+		// Elements are appended rather than allocated in advance so that a
+		// corrupt count cannot cause a huge allocation.
+		var n uint32
+		get.Uint32(&n)
+		sl := reflect.Zero(t)
+		for j := uint32(0); j < n && get.err == nil; j++ {
+			e := reflect.New(t.Elem()).Elem()
+			decodeValue(get, e)
+			sl = reflect.Append(sl, e)
+		}
+		v.Set(sl)
+	case reflect.Array:
+		for j := 0; j < v.Len(); j++ {
+			decodeValue(get, v.Index(j))
+		}
+	case reflect.Map:
+		var n uint32
+		get.Uint32(&n)
+		mp := reflect.MakeMap(t)
+		for j := uint32(0); j < n && get.err == nil; j++ {
+			k := reflect.New(t.Key()).Elem()
+			e := reflect.New(t.Elem()).Elem()
+			decodeValue(get, k)
+			decodeValue(get, e)
+			mp.SetMapIndex(k, e)
+		}
+		v.Set(mp)
+	case reflect.Ptr:
+		var u uint8
+		get.Uint8(&u)
+		if u == 0 {
+			v.Set(reflect.Zero(t))
+		} else {
+			p := reflect.New(t.Elem())
+			decodeValue(get, p.Elem())
+			v.Set(p)
+		}
+	case reflect.Struct:
+		if t == timeType {
+			var tm time.Time
+			get.Time(&tm)
+			v.Set(reflect.ValueOf(tm))
+			return
+		}
+		for j := 0; j < t.NumField(); j++ {
+			if f := t.Field(j); f.PkgPath == "" && f.Tag.Get("store") != "-" {
+				decodeValue(get, v.Field(j))
+			}
+		}
+	default:
+		get.err = fmt.Errorf("cannot decode value of type %s", t)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type encodeInner struct {
+	Name string
+	Qty  uint16
+}
+
+type encodeRec struct {
+	A     uint32
+	B     int
+	C     bool
+	D     []byte
+	T     time.Time
+	ID    ID
+	Items []encodeInner
+	Attrs map[string]int8
+	Next  *encodeInner
+	Skip  string `store:"-"`
+	hide  string
+}
+
+// Ensure that reflection-based encoding matches hand-written packing
+func TestEncode(t *testing.T) {
+	src := encodeRec{A: 300, B: -2, C: true, D: []byte("xy"), T: time.Unix(1500000000, 0),
+		ID: ID{1}, Items: []encodeInner{{"a", 1}, {"b", 2}}, Attrs: map[string]int8{"k": -1},
+		Next: &encodeInner{"n", 3}, Skip: "skip", hide: "hide"}
+	var put PutBuffer
+	put.Uint32(src.A)
+	put.Int64(int64(src.B))
+	put.Uint8(1)
+	put.Bytes(src.D)
+	put.Time(src.T)
+	put.ID(src.ID)
+	put.Uint32(2)
+	for _, item := range src.Items {
+		put.Str(item.Name)
+		put.Uint16(item.Qty)
+	}
+	put.Uint32(1)
+	put.Str("k")
+	put.Int8(-1)
+	put.Uint8(1)
+	put.Str("n")
+	put.Uint16(3)
+	want, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Encode(&src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Fatalf("expecting %x, got %x", want, data)
+	}
+	var dst encodeRec
+	if err = Decode(data, &dst); err != nil {
+		t.Fatal(err)
+	}
+	src.Skip, src.hide = "", ""
+	if !reflect.DeepEqual(src, dst) {
+		t.Fatalf("expecting %+v, got %+v", src, dst)
+	}
+	if _, err = Encode(struct{ F float64 }{1}); err == nil {
+		t.Fatal("unsupported type not reported by Encode")
+	}
+	if err = Decode(data, dst); err == nil {
+		t.Fatal("non-pointer target not reported")
+	}
+	if err = Decode(data[:len(data)-1], &dst); err == nil {
+		t.Fatal("short record not reported")
+	}
+}
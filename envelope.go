@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The flag bits of an envelope header record how the payload was
+// transformed by Envelope.Seal.
+const (
+	flagCompressed = 1 << iota
+	flagEncrypted
+	flagChecksummed
+	flagAll = flagCompressed | flagEncrypted | flagChecksummed
+)
+
+var (
+	errEnvelopeMagic = errors.New("content does not begin with the expected magic number")
+	errEnvelopeOpen  = errors.New("envelope payload cannot be decrypted")
+)
+
+// Envelope wraps payloads, such as packed records or entire files, in a
+// self-identifying header. The header holds a magic number, a format
+// version and flag bits that record whether the payload is compressed,
+// encrypted and checksummed, so a reader can recognize the content and
+// undo each transformation without prior knowledge of how it was written.
+// The format version permits the layout of the payload to change over time.
+type Envelope struct {
+	// Magic identifies content written with this envelope. It is packed as
+	// the first four bytes in big-endian order.
+	Magic uint32
+	// Version is the format version written by Seal.
+	Version uint16
+	// Compress is the payload length at or above which Seal compresses the
+	// payload with DEFLATE. Zero disables compression.
+	Compress int
+	// Checksum indicates that Seal appends a CRC-32 checksum that covers the
+	// header and the transformed payload.
+	Checksum bool
+	// AEAD, if not nil, encrypts and authenticates payloads in Seal and is
+	// required by Open for encrypted payloads. A random nonce is generated
+	// for each payload and packed ahead of it. The header is authenticated
+	// as additional data, so a change to the magic number, version or flags
+	// of an encrypted envelope is detected by Open.
+	AEAD cipher.AEAD
+	// Limit, if positive, is the maximum length of a payload decompressed by
+	// Open.
+	Limit int
+}
+
+// Seal returns payload preceded by the envelope header and transformed as
+// configured: compressed, then encrypted, then checksummed.
+func (e *Envelope) Seal(payload []byte) ([]byte, error) {
+	var flags uint8
+	body := payload
+	if e.Compress > 0 && len(payload) >= e.Compress {
+		if sl, ok := deflate(nil, payload); ok {
+			body = sl
+			flags |= flagCompressed
+		}
+	}
+	if e.AEAD != nil {
+		flags |= flagEncrypted
+	}
+	if e.Checksum {
+		flags |= flagChecksummed
+	}
+	var put PutBuffer
+	put.Uint32Fixed(e.Magic)
+	put.Uint16(e.Version)
+	put.Uint8(flags)
+	if e.AEAD != nil {
+		nonce := make([]byte, e.AEAD.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, err
+		}
+		body = e.AEAD.Seal(nonce, nonce, body, put.buf)
+	}
+	put.Raw(body)
+	data, err := put.DataUnsafe()
+	if err == nil && e.Checksum {
+		data = appendChecksum(make([]byte, 0, len(data)+4), data)
+	}
+	return data, err
+}
+
+// Open validates the envelope header of data, which was produced by Seal
+// with the same magic number, and returns the original payload along with
+// the format version recorded in the header. Flag bits that are not
+// recognized cause an error, so envelopes written by a future version that
+// adds a transformation are rejected rather than misread. An error that
+// matches ErrChecksum is returned if the content has been damaged.
+func (e *Envelope) Open(data []byte) (payload []byte, version uint16, err error) {
+	var magic uint32
+	var flags uint8
+	get := &GetBuffer{data: data}
+	get.Uint32Fixed(&magic)
+	if get.err == nil && magic != e.Magic {
+		return nil, 0, errEnvelopeMagic
+	}
+	get.Uint16(&version)
+	get.Uint8(&flags)
+	if err = get.context(); err != nil {
+		return nil, 0, err
+	}
+	if flags&^flagAll != 0 {
+		return nil, 0, fmt.Errorf("envelope has unrecognized flags %#02x", flags&^flagAll)
+	}
+	if flags&flagChecksummed != 0 {
+		if data, err = verifyChecksum(data); err != nil {
+			return nil, 0, err
+		}
+	}
+	payload = data[get.pos:]
+	if flags&flagEncrypted != 0 {
+		if e.AEAD == nil {
+			return nil, 0, errors.New("envelope payload is encrypted but no AEAD is configured")
+		}
+		n := e.AEAD.NonceSize()
+		if len(payload) < n {
+			return nil, 0, errEnvelopeOpen
+		}
+		if payload, err = e.AEAD.Open(nil, payload[:n], payload[n:], data[:get.pos]); err != nil {
+			return nil, 0, errEnvelopeOpen
+		}
+	}
+	if flags&flagCompressed != 0 {
+		var limit uint64
+		if e.Limit > 0 {
+			limit = uint64(e.Limit)
+		}
+		if payload, err = inflate(payload, limit); err != nil {
+			return nil, 0, err
+		}
+	} else if flags&flagEncrypted == 0 {
+		payload = append([]byte(nil), payload...)
+	}
+	return payload, version, nil
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func ExampleEnvelope() {
+	e := Envelope{Magic: 0x50494e31, Version: 3, Compress: 128, Checksum: true}
+	payload := bytes.Repeat([]byte("pinion "), 100)
+	data, err := e.Seal(payload)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%x %d\n", data[:6], len(data))
+	sl, version, err := e.Open(data)
+	fmt.Println(bytes.Equal(sl, payload), version, err)
+	// Output:
+	// 50494e310305 26
+	// true 3 <nil>
+}
+
+func TestEnvelope_Open(t *testing.T) {
+	block, _ := aes.NewCipher(make([]byte, 16))
+	aead, _ := cipher.NewGCM(block)
+	payload := bytes.Repeat([]byte("gear "), 50)
+	for _, e := range []Envelope{
+		{Magic: 1},
+		{Magic: 1, Compress: 1},
+		{Magic: 1, Compress: 1, AEAD: aead},
+		{Magic: 1, Compress: 1, AEAD: aead, Checksum: true, Version: 300},
+	} {
+		data, err := e.Seal(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sl, version, err := e.Open(data)
+		if err != nil || version != e.Version || !bytes.Equal(sl, payload) {
+			t.Fatalf("unexpected result %d: %v", version, err)
+		}
+		if e.AEAD != nil && bytes.Contains(data, []byte("gear")) {
+			t.Fatal("payload not encrypted")
+		}
+	}
+	e := Envelope{Magic: 1, Compress: 1, AEAD: aead, Checksum: true}
+	data, _ := e.Seal(payload)
+	damaged := append([]byte(nil), data...)
+	damaged[10] ^= 1
+	if _, _, err := e.Open(damaged); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("expecting checksum error, got %v", err)
+	}
+	e.Checksum = false
+	data, _ = e.Seal(payload)
+	for _, pos := range []int{4, 5} {
+		damaged = append([]byte(nil), data...)
+		damaged[pos] ^= flagCompressed
+		if _, _, err := e.Open(damaged); err != errEnvelopeOpen {
+			t.Fatalf("expecting authentication error for altered header, got %v", err)
+		}
+	}
+	for _, c := range []struct {
+		e    Envelope
+		data []byte
+	}{
+		{Envelope{Magic: 2}, data},
+		{Envelope{Magic: 1}, data},
+		{Envelope{Magic: 1, AEAD: aead}, []byte{0, 0, 0, 1, 0, 8}},
+		{Envelope{Magic: 1, AEAD: aead}, []byte{0, 0, 0, 1, 0, 2, 1, 2}},
+		{Envelope{Magic: 1}, []byte{0, 0, 0, 1}},
+		{Envelope{Magic: 1, Limit: 10}, mustSeal(t, Envelope{Magic: 1, Compress: 1}, payload)},
+	} {
+		if _, _, err := c.e.Open(c.data); err == nil {
+			t.Fatalf("expecting error for %x", c.data)
+		}
+	}
+}
+
+func mustSeal(t *testing.T, e Envelope, payload []byte) []byte {
+	data, err := e.Seal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"encoding/binary"
+)
+
+// RecordDecoder extracts records framed as by RecordWriter from bytes that
+// arrive in arbitrary pieces, such as the reads of a TCP or WebSocket
+// connection, whose boundaries need not align with those of the records.
+// Bytes are passed to Feed as they arrive, and complete records are then
+// retrieved with Next. Unlike RecordReader, a RecordDecoder never blocks, so
+// it suits event-driven servers.
+type RecordDecoder struct {
+	buf   []byte
+	pos   int
+	rec   []byte
+	limit int
+	err   error
+}
+
+// NewRecordDecoder returns an empty record decoder.
+func NewRecordDecoder() *RecordDecoder {
+	return &RecordDecoder{limit: RecordLimit}
+}
+
+// SetLimit assigns the maximum length of a record. A record with a greater
+// length, which may indicate a corrupt stream, sets an error that matches
+// ErrLimitExceeded rather than being buffered. The default limit is
+// RecordLimit.
+func (d *RecordDecoder) SetLimit(n int) {
+	d.limit = n
+}
+
+// Feed appends sl to the bytes buffered by the decoder. sl is copied, so the
+// caller may reuse it once Feed returns. Records returned by Record before
+// the call to Feed are no longer valid afterward.
+func (d *RecordDecoder) Feed(sl []byte) {
+	if d.err == nil {
+		if d.pos > 0 {
+			d.buf = d.buf[:copy(d.buf, d.buf[d.pos:])]
+			d.pos = 0
+		}
+		d.buf = append(d.buf, sl...)
+	}
+}
+
+// Next advances the decoder to the next record if it has been completely
+// fed. It returns false if more bytes are needed or an error has occurred;
+// call Err to distinguish between these cases.
+func (d *RecordDecoder) Next() bool {
+	if d.err != nil {
+		return false
+	}
+	n, size := binary.Uvarint(d.buf[d.pos:])
+	switch {
+	case size == 0:
+		return false
+	case size < 0:
+		d.err = errVarintOverflow
+		return false
+	case n > uint64(d.limit):
+		d.err = categoryErrorf(ErrLimitExceeded, "record of %d bytes exceeds limit of %d bytes", n, d.limit)
+		return false
+	case n > uint64(len(d.buf)-d.pos-size):
+		return false
+	}
+	start := d.pos + size
+	d.pos = start + int(n)
+	d.rec = d.buf[start:d.pos:d.pos]
+	return true
+}
+
+// Record returns the record at the current position of the decoder. The
+// returned slice refers to the decoder's buffer and is valid until the next
+// call to Feed.
+func (d *RecordDecoder) Record() []byte {
+	return d.rec
+}
+
+// Buffered returns the number of bytes that have been fed but not yet
+// returned as part of a record.
+func (d *RecordDecoder) Buffered() int {
+	return len(d.buf) - d.pos
+}
+
+// Err returns the error, if any, that stopped the decoder. Once an error has
+// occurred, the stream cannot be resynchronized and the connection should be
+// closed.
+func (d *RecordDecoder) Err() error {
+	return d.err
+}
+
+// Done is called to indicate that the stream has ended. It returns the error
+// that stopped the decoder, or an error that matches ErrShortBuffer if the
+// stream ended within a record.
+func (d *RecordDecoder) Done() error {
+	if d.err == nil && d.Buffered() > 0 {
+		return errRecordTorn
+	}
+	return d.err
+}
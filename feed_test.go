@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func ExampleRecordDecoder() {
+	var b bytes.Buffer
+	rw := NewRecordWriter(&b)
+	rw.Write([]byte("hello"))
+	rw.Write([]byte("pinion"))
+	stream := b.Bytes()
+	d := NewRecordDecoder()
+	// Simulate reads that split records
+	for _, piece := range [][]byte{stream[:3], stream[3:8], stream[8:]} {
+		d.Feed(piece)
+		for d.Next() {
+			fmt.Println(string(d.Record()))
+		}
+	}
+	fmt.Println(d.Done())
+	// Output:
+	// hello
+	// pinion
+	// <nil>
+}
+
+func TestRecordDecoder(t *testing.T) {
+	var b bytes.Buffer
+	rw := NewRecordWriter(&b)
+	var recs [][]byte
+	for j := 0; j < 50; j++ {
+		rec := bytes.Repeat([]byte{byte(j)}, j*7)
+		recs = append(recs, rec)
+		rw.Write(rec)
+	}
+	stream := b.Bytes()
+	for _, size := range []int{1, 2, 3, 64, 1000, len(stream)} {
+		d := NewRecordDecoder()
+		var got int
+		for pos := 0; pos < len(stream); pos += size {
+			end := pos + size
+			if end > len(stream) {
+				end = len(stream)
+			}
+			d.Feed(stream[pos:end])
+			for d.Next() {
+				if !bytes.Equal(d.Record(), recs[got]) {
+					t.Fatalf("piece size %d: record %d mismatch", size, got)
+				}
+				got++
+			}
+		}
+		if got != len(recs) || d.Done() != nil || d.Buffered() != 0 {
+			t.Fatalf("piece size %d: got %d records: %v", size, got, d.Done())
+		}
+	}
+	d := NewRecordDecoder()
+	d.Feed(stream[:len(stream)-1])
+	for d.Next() {
+	}
+	if d.Err() != nil || !errors.Is(d.Done(), ErrShortBuffer) {
+		t.Fatalf("expecting torn record, got %v", d.Done())
+	}
+	d = NewRecordDecoder()
+	d.SetLimit(100)
+	d.Feed(stream)
+	for d.Next() {
+	}
+	if !errors.Is(d.Err(), ErrLimitExceeded) {
+		t.Fatalf("expecting limit exceeded, got %v", d.Err())
+	}
+	d = NewRecordDecoder()
+	d.Feed(bytes.Repeat([]byte{0xff}, 11))
+	if d.Next() || d.Err() != errVarintOverflow {
+		t.Fatalf("expecting overflow, got %v", d.Err())
+	}
+}
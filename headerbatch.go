@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+// HeaderBatchWriter packs a batch of records that share a common set of
+// leading fields, such as a tenant identifier or schema version. The shared
+// fields are packed once as the batch header and each record holds only its
+// remaining fields. A HeaderBatchReader merges the header back into each
+// record, so records are unpacked exactly as though they had been packed
+// whole.
+type HeaderBatchWriter struct {
+	put PutBuffer
+}
+
+// NewHeaderBatchWriter returns a writer for a batch whose records all begin
+// with the packed fields in header.
+func NewHeaderBatchWriter(header []byte) *HeaderBatchWriter {
+	hbw := new(HeaderBatchWriter)
+	hbw.put.Bytes(header)
+	return hbw
+}
+
+// Record packs the specified record, less its shared leading fields, into the
+// receiving batch.
+func (hbw *HeaderBatchWriter) Record(rec []byte) {
+	hbw.put.Bytes(rec)
+}
+
+// SetError permits the caller to assign an error value to the batch writer.
+// This method unconditionally overwrites the current internal error value.
+func (hbw *HeaderBatchWriter) SetError(err error) {
+	hbw.put.SetError(err)
+}
+
+// Data returns the packed batch in the form of a byte slice. The second
+// return value is an error code that will be nil if the header and all
+// records have been successfully packed.
+func (hbw *HeaderBatchWriter) Data() ([]byte, error) {
+	return hbw.put.DataUnsafe()
+}
+
+// HeaderBatchReader extracts records from a byte sequence that was generated
+// using a HeaderBatchWriter.
+type HeaderBatchReader struct {
+	get    *GetBuffer
+	header []byte
+	rec    []byte
+}
+
+// NewHeaderBatchReader returns an initialized reader that can be used to
+// extract records from data.
+func NewHeaderBatchReader(data []byte) *HeaderBatchReader {
+	hbr := &HeaderBatchReader{get: NewGetBuffer(data)}
+	hbr.get.Bytes(&hbr.header)
+	return hbr
+}
+
+// Header returns the shared leading fields of the batch.
+func (hbr *HeaderBatchReader) Header() []byte {
+	return hbr.header
+}
+
+// Next advances the reader to the next record in the batch. It returns false
+// when no records remain or an error has occurred; call Done to distinguish
+// between these cases.
+func (hbr *HeaderBatchReader) Next() bool {
+	get := hbr.get
+	if get.err != nil || !get.more() {
+		return false
+	}
+	var rec []byte
+	get.Bytes(&rec)
+	if get.err == nil {
+		hbr.rec = append(append(hbr.rec[:0], hbr.header...), rec...)
+	}
+	return get.err == nil
+}
+
+// Record returns the current record with the shared header fields restored
+// to its beginning. The returned slice is overwritten by the next call to
+// Next.
+func (hbr *HeaderBatchReader) Record() []byte {
+	return hbr.rec
+}
+
+// Done is called to indicate that the batch has been read. If no error has
+// occurred and no content remains buffered, nil is returned, otherwise an
+// appropriate error value.
+func (hbr *HeaderBatchReader) Done() error {
+	return hbr.get.Done()
+}
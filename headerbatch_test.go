@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// ExampleHeaderBatchWriter demonstrates packing fields that are common to a
+// batch of records only once.
+func ExampleHeaderBatchWriter() {
+	var hdr PutBuffer
+	hdr.Uint32(42)
+	hdr.Time(timeTest)
+	header, err := hdr.Data()
+	if err == nil {
+		hbw := NewHeaderBatchWriter(header)
+		for _, name := range []string{"alpha", "beta", "gamma"} {
+			var put PutBuffer
+			put.Str(name)
+			rec, err := put.Data()
+			hbw.Record(rec)
+			hbw.SetError(err)
+		}
+		var data []byte
+		data, err = hbw.Data()
+		if err == nil {
+			hbr := NewHeaderBatchReader(data)
+			for hbr.Next() {
+				var tenant uint32
+				var name string
+				var tm time.Time
+				get := NewGetBuffer(hbr.Record())
+				get.Uint32(&tenant)
+				get.Time(&tm)
+				get.Str(&name)
+				if err = get.Done(); err == nil {
+					fmt.Println(tenant, tm.UTC().Year(), name)
+				}
+			}
+			if err == nil {
+				err = hbr.Done()
+			}
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// 42 1997 alpha
+	// 42 1997 beta
+	// 42 1997 gamma
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errIDOverflow = errors.New("too many identifiers generated within one millisecond")
+	errIDText     = errors.New("invalid identifier text")
+)
+
+const idAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ID is a unique identifier whose byte order matches the order in which
+// identifiers were generated. The first six bytes hold the number of
+// milliseconds since the Unix epoch in big-endian order and the remaining ten
+// bytes are random. An ID is stored as the same sixteen bytes by PutBuffer and
+// KeyBuffer, so it can serve directly as a time-ordered primary key.
+type ID [16]byte
+
+// Time returns the creation time of the receiving identifier with
+// millisecond resolution.
+func (id ID) Time() time.Time {
+	var ms int64
+	for _, b := range id[:6] {
+		ms = ms<<8 | int64(b)
+	}
+	return time.Unix(ms/1000, ms%1000*int64(time.Millisecond))
+}
+
+// String returns the 26 character text form of the receiving identifier. It
+// uses Crockford's base32 alphabet, as ULIDs do, so text forms sort in the
+// same order as the identifiers themselves.
+func (id ID) String() string {
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+	var str [26]byte
+	for j := range str {
+		var v uint64
+		switch shift := uint(125 - 5*j); {
+		case shift >= 64:
+			v = hi >> (shift - 64)
+		case shift == 0:
+			v = lo
+		default:
+			v = lo>>shift | hi<<(64-shift)
+		}
+		str[j] = idAlphabet[v&31]
+	}
+	return string(str[:])
+}
+
+// ParseID returns the identifier represented by str, which is normally a
+// value returned by ID.String. Letters may be in either case, and the
+// letters I, L and O are accepted in place of the digits they resemble.
+func ParseID(str string) (id ID, err error) {
+	if len(str) != 26 {
+		return id, errIDText
+	}
+	var hi, lo uint64
+	for j := 0; j < len(str); j++ {
+		c := str[j]
+		switch c {
+		case 'i', 'I', 'l', 'L':
+			c = '1'
+		case 'o', 'O':
+			c = '0'
+		}
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		v := strings.IndexByte(idAlphabet, c)
+		if v < 0 || (j == 0 && v > 7) {
+			return ID{}, errIDText
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+	binary.BigEndian.PutUint64(id[:8], hi)
+	binary.BigEndian.PutUint64(id[8:], lo)
+	return
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (id ID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (id *ID) UnmarshalText(text []byte) (err error) {
+	*id, err = ParseID(string(text))
+	return
+}
+
+// IDGenerator produces identifiers of type ID. Identifiers generated within
+// the same millisecond by one generator are given successive random parts, so
+// they are ordered as well. An IDGenerator is safe for concurrent use.
+type IDGenerator struct {
+	mu   sync.Mutex
+	rand io.Reader
+	last ID
+	ms   int64
+}
+
+// NewIDGenerator returns a generator that obtains randomness from r. If r is
+// nil, crypto/rand.Reader is used.
+func NewIDGenerator(r io.Reader) *IDGenerator {
+	if r == nil {
+		r = rand.Reader
+	}
+	return &IDGenerator{rand: r}
+}
+
+// New returns an identifier for the current time. An error is returned if
+// randomness cannot be obtained or, in the unlikely event that the random
+// part of the previous identifier cannot be incremented, if too many
+// identifiers are requested within one millisecond.
+func (g *IDGenerator) New() (ID, error) {
+	return g.NewAt(time.Now())
+}
+
+// NewAt returns an identifier for the specified time. If tm does not follow
+// the time of the previously generated identifier, the identifier is ordered
+// after its predecessor as though it were generated in the same millisecond.
+func (g *IDGenerator) NewAt(tm time.Time) (id ID, err error) {
+	ms := tm.UnixNano() / int64(time.Millisecond)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if ms <= g.ms {
+		id = g.last
+		j := len(id) - 1
+		for ; j >= 6; j-- {
+			id[j]++
+			if id[j] != 0 {
+				break
+			}
+		}
+		if j < 6 {
+			return ID{}, errIDOverflow
+		}
+	} else {
+		for j := 5; j >= 0; j-- {
+			id[j] = byte(ms >> (8 * uint(5-j)))
+		}
+		if _, err = io.ReadFull(g.rand, id[6:]); err != nil {
+			return ID{}, err
+		}
+		g.ms = ms
+	}
+	g.last = id
+	return
+}
+
+var defaultIDGenerator = NewIDGenerator(nil)
+
+// NewID returns an identifier for the current time from a package-level
+// generator that obtains randomness from crypto/rand.Reader.
+func NewID() (ID, error) {
+	return defaultIDGenerator.New()
+}
+
+// ID packs the specified identifier into the receiving storage buffer as
+// sixteen bytes without a length prefix.
+func (put *PutBuffer) ID(id ID) {
+	put.write(id[:])
+}
+
+// ID unpacks an identifier from the receiving storage buffer.
+func (get *GetBuffer) ID(id *ID) {
+	get.fill(id[:])
+}
+
+// ID stores the specified identifier into the receiving key buffer. Keys that
+// begin with an identifier are ordered by creation time.
+func (kb *KeyBuffer) ID(id ID) {
+	kb.write(id[:])
+}
+
+// ID extracts an identifier from the receiving key buffer.
+func (kg *KeyGetBuffer) ID(id *ID) {
+	if sl := kg.next(len(id)); sl != nil {
+		copy(id[:], sl)
+	}
+}
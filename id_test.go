@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piniondb/store/storetest"
+)
+
+// Ensure that identifiers are ordered by generation and survive packing
+func TestIDGenerator(t *testing.T) {
+	g := NewIDGenerator(bytes.NewReader(bytes.Repeat([]byte{1}, 20)))
+	a, err := g.NewAt(timeTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Time().Equal(timeTest) {
+		t.Fatalf("expecting %s, got %s", timeTest, a.Time())
+	}
+	b, err := g.NewAt(timeTest.Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(a[:], b[:]) >= 0 {
+		t.Fatal("identifier does not sort after its predecessor")
+	}
+	c, err := g.NewAt(timeTest.Add(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(b[:], c[:]) >= 0 {
+		t.Fatal("identifier does not sort after its predecessor")
+	}
+	if _, err = g.NewAt(timeTest.Add(2 * time.Millisecond)); err == nil {
+		t.Fatal("exhausted random source not reported")
+	}
+
+	var put PutBuffer
+	var kb KeyBuffer
+	put.ID(c)
+	kb.ID(c)
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got ID
+	get := NewGetBuffer(data)
+	get.ID(&got)
+	if err = get.Done(); err != nil || got != c {
+		t.Fatalf("identifier not unpacked: %v", err)
+	}
+	key, err := kb.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = ID{}
+	kg := NewKeyGetBuffer(key)
+	kg.ID(&got)
+	if err = kg.Done(); err != nil || got != c {
+		t.Fatalf("identifier not extracted from key: %v", err)
+	}
+	get = NewGetBuffer(data[:10])
+	get.ID(&got)
+	if get.Error() == nil {
+		t.Fatal("short identifier not reported")
+	}
+}
+
+// Ensure that the text form of identifiers is ordered and restored
+func TestID_String(t *testing.T) {
+	storetest.KeyOrder(t, 2000, func(r *rand.Rand) interface{} {
+		var id ID
+		r.Read(id[:])
+		return id
+	}, func(val interface{}) ([]byte, error) {
+		id := val.(ID)
+		str := id.String()
+		restored, err := ParseID(strings.ToLower(str))
+		if err == nil && restored != id {
+			err = fmt.Errorf("%s restored as %s", str, restored)
+		}
+		return []byte(str), err
+	}, func(a, b interface{}) int {
+		ia, ib := a.(ID), b.(ID)
+		return bytes.Compare(ia[:], ib[:])
+	})
+	var id ID
+	for j := range id {
+		id[j] = 0xff
+	}
+	if str := id.String(); str != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Fatalf("unexpected text %s", str)
+	}
+	for _, str := range []string{"8ZZZZZZZZZZZZZZZZZZZZZZZZZ", "0000000000000000000000000U", "00"} {
+		if _, err := ParseID(str); err == nil {
+			t.Fatalf("invalid text %s not reported", str)
+		}
+	}
+}
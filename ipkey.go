@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+var errKeyIPv4 = errors.New("invalid IPv4 address in key")
+
+// Family tags that lead the key segment of an IP address
+const (
+	ipTagV4 = 4
+	ipTagV6 = 6
+)
+
+// IP stores the specified IP address into the receiving key buffer. It
+// occupies seventeen bytes: a tag identifying the address family followed by
+// the address in sixteen-byte form. IPv4 addresses therefore sort before IPv6
+// addresses and addresses within each family sort numerically, so the
+// addresses in a CIDR block occupy a contiguous range of keys; see IPNetRange.
+// The internal error is set if ip is not a valid address.
+func (kb *KeyBuffer) IP(ip net.IP) {
+	tag := uint8(ipTagV6)
+	if ip.To4() != nil {
+		tag = ipTagV4
+	}
+	if ip16 := ip.To16(); ip16 != nil {
+		kb.Uint8(tag)
+		kb.write(ip16)
+	} else if kb.err == nil {
+		kb.err = fmt.Errorf("invalid IP address %v", []byte(ip))
+	}
+}
+
+// IP extracts an IP address that was stored with KeyBuffer.IP from the
+// receiving key buffer. IPv4 addresses are returned in four-byte form.
+func (kg *KeyGetBuffer) IP(ip *net.IP) {
+	var tag uint8
+	kg.Uint8(&tag)
+	if sl := kg.next(net.IPv6len); sl != nil {
+		val := net.IP(append([]byte(nil), sl...))
+		switch tag {
+		case ipTagV4:
+			if val = val.To4(); val == nil {
+				kg.err = errKeyIPv4
+				return
+			}
+		case ipTagV6:
+		default:
+			kg.err = fmt.Errorf("invalid IP address family %d in key", tag)
+			return
+		}
+		*ip = val
+	}
+}
+
+// IPNetRange returns the inclusive start and exclusive end of the range of
+// keys, as stored by KeyBuffer.IP, that hold addresses within network n. A
+// nil end indicates that the range is unbounded above.
+func IPNetRange(n *net.IPNet) (start, end []byte, err error) {
+	var kb KeyBuffer
+	kb.IP(n.IP.Mask(n.Mask))
+	if start, err = kb.Data(); err == nil {
+		ones, bits := n.Mask.Size()
+		if bits == 0 {
+			return nil, nil, fmt.Errorf("invalid network mask %s", n.Mask)
+		}
+		// Skip the tag byte and, for IPv4, the twelve-byte prefix of its
+		// sixteen-byte form
+		prefix := 1 + 16 - bits/8 + ones/8
+		end = PrefixSuccessor(start[:prefix])
+		if rem := ones % 8; rem != 0 {
+			end = append([]byte(nil), start[:prefix+1]...)
+			end[prefix] |= 0xff >> uint(rem)
+			end = PrefixSuccessor(end)
+		}
+	}
+	return
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// Ensure that IP address keys are restored and that network ranges contain
+// exactly the addresses of the network
+func TestKeyBuffer_IP(t *testing.T) {
+	key := func(str string) []byte {
+		var kb KeyBuffer
+		kb.IP(net.ParseIP(str))
+		sl, err := kb.Data()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sl
+	}
+	for _, str := range []string{"10.1.2.3", "::1", "2001:db8::7"} {
+		var ip net.IP
+		kg := NewKeyGetBuffer(key(str))
+		kg.IP(&ip)
+		if err := kg.Done(); err != nil {
+			t.Fatal(err)
+		}
+		if ip.String() != str {
+			t.Fatalf("expecting %s, got %s", str, ip)
+		}
+	}
+	if bytes.Compare(key("255.255.255.255"), key("::")) >= 0 {
+		t.Fatal("IPv4 address does not sort before IPv6 address")
+	}
+	for _, tc := range []struct {
+		cidr    string
+		in, out []string
+	}{
+		{"172.16.0.0/12", []string{"172.16.0.0", "172.31.255.255"}, []string{"172.15.255.255", "172.32.0.0", "::"}},
+		{"10.0.0.0/8", []string{"10.0.0.0", "10.255.255.255"}, []string{"9.255.255.255", "11.0.0.0"}},
+		{"2001:db8::/32", []string{"2001:db8::", "2001:db8:ffff::1"}, []string{"2001:db9::", "10.0.0.1"}},
+	} {
+		_, n, err := net.ParseCIDR(tc.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start, end, err := IPNetRange(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inRange := func(k []byte) bool {
+			return bytes.Compare(k, start) >= 0 && (end == nil || bytes.Compare(k, end) < 0)
+		}
+		for _, str := range tc.in {
+			if !inRange(key(str)) {
+				t.Fatalf("%s not in range of %s", str, tc.cidr)
+			}
+		}
+		for _, str := range tc.out {
+			if inRange(key(str)) {
+				t.Fatalf("%s in range of %s", str, tc.cidr)
+			}
+		}
+	}
+	var kb KeyBuffer
+	kb.IP(net.IP{1, 2})
+	if _, err := kb.Data(); err == nil {
+		t.Fatal("invalid address not reported")
+	}
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// ToJSON returns a JSON object that holds the values of a record that
+// conforms to schema s. The members appear in schema order. Times are
+// rendered in RFC 3339 form in UTC, byte slices in standard base64 form, and
+// integers as JSON numbers. Note that some JSON consumers cannot represent
+// 64-bit integers exactly. Since a JSON string cannot hold invalid UTF-8
+// without loss, a str field that is not valid UTF-8 results in an error.
+func ToJSON(s *Schema, data []byte) ([]byte, error) {
+	rec, err := s.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for j, f := range s.fields {
+		if j > 0 {
+			b.WriteByte(',')
+		}
+		val := rec[f.Name]
+		switch v := val.(type) {
+		case time.Time:
+			val = v.UTC()
+		case string:
+			if !utf8.ValidString(v) {
+				return nil, fmt.Errorf("field %s: %w", f.Name, errInvalidUTF8)
+			}
+		}
+		var name, sl []byte
+		name, _ = json.Marshal(f.Name)
+		if sl, err = json.Marshal(val); err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(sl)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// FromJSON packs a record that conforms to schema s from a JSON object in the
+// form produced by ToJSON. The object must have a member for each field of s
+// and no others. Numbers that do not fit in the kind of their field result in
+// an error.
+func FromJSON(s *Schema, jsonData []byte) ([]byte, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(jsonData, &members); err != nil {
+		return nil, err
+	}
+	rec := make(map[string]interface{}, len(members))
+	for _, f := range s.fields {
+		raw, ok := members[f.Name]
+		if !ok {
+			return nil, fmt.Errorf("JSON object has no member for field %s", f.Name)
+		}
+		val, err := f.fromJSON(raw)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		rec[f.Name] = val
+	}
+	if len(rec) != len(members) {
+		var extra []string
+		for name := range members {
+			if _, ok := rec[name]; !ok {
+				extra = append(extra, name)
+			}
+		}
+		sort.Strings(extra)
+		return nil, fmt.Errorf("JSON object has members not in schema: %q", extra)
+	}
+	return s.Encode(rec)
+}
+
+// fromJSON returns the Go value of the kind of field f held by raw.
+func (f Field) fromJSON(raw json.RawMessage) (val interface{}, err error) {
+	switch f.Kind {
+	case KindTime:
+		var v time.Time
+		err = json.Unmarshal(raw, &v)
+		val = v
+	case KindUint64:
+		var v uint64
+		err = json.Unmarshal(raw, &v)
+		val = v
+	case KindInt64:
+		var v int64
+		err = json.Unmarshal(raw, &v)
+		val = v
+	case KindUint32:
+		var v uint32
+		err = json.Unmarshal(raw, &v)
+		val = v
+	case KindInt32:
+		var v int32
+		err = json.Unmarshal(raw, &v)
+		val = v
+	case KindUint16:
+		var v uint16
+		err = json.Unmarshal(raw, &v)
+		val = v
+	case KindInt16:
+		var v int16
+		err = json.Unmarshal(raw, &v)
+		val = v
+	case KindUint8:
+		var v uint8
+		err = json.Unmarshal(raw, &v)
+		val = v
+	case KindInt8:
+		var v int8
+		err = json.Unmarshal(raw, &v)
+		val = v
+	case KindStr:
+		var v string
+		err = json.Unmarshal(raw, &v)
+		val = v
+	case KindBytes:
+		var v []byte
+		err = json.Unmarshal(raw, &v)
+		val = v
+	default:
+		err = fmt.Errorf("unsupported kind %s", f.Kind)
+	}
+	return
+}
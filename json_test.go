@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func ExampleToJSON() {
+	s, _ := ParseSchema("id:uint32, name:str, at:time, key:bytes")
+	var put PutBuffer
+	put.Uint32(42)
+	put.Str("gear")
+	put.Time(time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC))
+	put.Bytes([]byte{1, 2, 3})
+	data, _ := put.Data()
+	js, err := ToJSON(s, data)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(string(js))
+	rec, err := FromJSON(s, js)
+	fmt.Println(bytes.Equal(rec, data), err)
+	// Output:
+	// {"id":42,"name":"gear","at":"2016-05-01T12:00:00Z","key":"AQID"}
+	// true <nil>
+}
+
+func TestFromJSON(t *testing.T) {
+	s, _ := ParseSchema("id:uint8, name:str")
+	for _, str := range []string{
+		`{"id":256,"name":"gear"}`,
+		`{"id":-1,"name":"gear"}`,
+		`{"id":1}`,
+		`{"id":1,"name":"gear","extra":true}`,
+		`{"id":1,"name":7}`,
+		`[1,"gear"]`,
+	} {
+		if _, err := FromJSON(s, []byte(str)); err == nil {
+			t.Fatalf("expecting error for %s", str)
+		}
+	}
+	if _, err := ToJSON(s, []byte{1}); err == nil {
+		t.Fatal("expecting error for truncated record")
+	}
+	if _, err := ToJSON(s, []byte{1, 4, 'b', 'a', 'd', 0xff}); !errors.Is(err, errInvalidUTF8) {
+		t.Fatalf("expecting UTF-8 error, got %v", err)
+	}
+}
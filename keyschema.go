@@ -0,0 +1,440 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var errFieldCount = errors.New("number of values does not match number of schema fields")
+
+// Kind identifies the type of a field described by a schema.
+type Kind uint8
+
+// The following constants identify the field types that can be described by
+// a schema. KindStr and KindBytes are fixed-width fields in keys;
+// KindVarStr and KindVarBytes are their variable-length counterparts.
+const (
+	KindTime Kind = iota + 1
+	KindUint64
+	KindInt64
+	KindUint32
+	KindInt32
+	KindUint16
+	KindInt16
+	KindUint8
+	KindInt8
+	KindStr
+	KindBytes
+	KindVarStr
+	KindVarBytes
+)
+
+var kindNames = map[Kind]string{
+	KindTime:     "time",
+	KindUint64:   "uint64",
+	KindInt64:    "int64",
+	KindUint32:   "uint32",
+	KindInt32:    "int32",
+	KindUint16:   "uint16",
+	KindInt16:    "int16",
+	KindUint8:    "uint8",
+	KindInt8:     "int8",
+	KindStr:      "str",
+	KindBytes:    "bytes",
+	KindVarStr:   "varstr",
+	KindVarBytes: "varbytes",
+}
+
+// String implements the fmt.Stringer interface.
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("kind(%d)", uint8(k))
+}
+
+// KeyField describes one segment of a key. Width is used only by fields of
+// kind KindStr and KindBytes. Name is optional and is used when rendering
+// keys and reporting errors.
+type KeyField struct {
+	Name  string
+	Kind  Kind
+	Width uint
+}
+
+func (f KeyField) label(j int) string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return fmt.Sprintf("#%d", j)
+}
+
+// KeySchema describes the ordered sequence of fields that make up a key. Once
+// declared, it can be used to build, decompose and display keys without
+// repeating the sequence of KeyBuffer and KeyGetBuffer calls.
+type KeySchema struct {
+	fields []KeyField
+}
+
+// NewKeySchema returns a key schema made up of the specified fields in order.
+func NewKeySchema(fields ...KeyField) *KeySchema {
+	return &KeySchema{fields: append([]KeyField(nil), fields...)}
+}
+
+// Fields returns a copy of the fields that make up the receiving schema.
+func (ks *KeySchema) Fields() []KeyField {
+	return append([]KeyField(nil), ks.fields...)
+}
+
+func typeError(f KeyField, j int, val interface{}) error {
+	return fmt.Errorf("key field %s: expecting %s value, got %T", f.label(j), f.Kind, val)
+}
+
+// put stores val into kb according to field f.
+func (f KeyField) put(kb *KeyBuffer, j int, val interface{}) {
+	ok := true
+	switch f.Kind {
+	case KindTime:
+		var v time.Time
+		if v, ok = val.(time.Time); ok {
+			kb.Time(v)
+		}
+	case KindUint64:
+		var v uint64
+		if v, ok = val.(uint64); ok {
+			kb.Uint64(v)
+		}
+	case KindInt64:
+		var v int64
+		if v, ok = val.(int64); ok {
+			kb.Int64(v)
+		}
+	case KindUint32:
+		var v uint32
+		if v, ok = val.(uint32); ok {
+			kb.Uint32(v)
+		}
+	case KindInt32:
+		var v int32
+		if v, ok = val.(int32); ok {
+			kb.Int32(v)
+		}
+	case KindUint16:
+		var v uint16
+		if v, ok = val.(uint16); ok {
+			kb.Uint16(v)
+		}
+	case KindInt16:
+		var v int16
+		if v, ok = val.(int16); ok {
+			kb.Int16(v)
+		}
+	case KindUint8:
+		var v uint8
+		if v, ok = val.(uint8); ok {
+			kb.Uint8(v)
+		}
+	case KindInt8:
+		var v int8
+		if v, ok = val.(int8); ok {
+			kb.Int8(v)
+		}
+	case KindStr:
+		var v string
+		if v, ok = val.(string); ok {
+			kb.Str(v, f.Width)
+		}
+	case KindBytes:
+		var v []byte
+		if v, ok = val.([]byte); ok {
+			kb.Bytes(v, f.Width)
+		}
+	case KindVarStr:
+		var v string
+		if v, ok = val.(string); ok {
+			kb.VarStr(v)
+		}
+	case KindVarBytes:
+		var v []byte
+		if v, ok = val.([]byte); ok {
+			kb.VarBytes(v)
+		}
+	default:
+		ok = false
+	}
+	if !ok {
+		kb.SetError(typeError(f, j, val))
+	}
+}
+
+// get extracts a value from kg according to field f.
+func (f KeyField) get(kg *KeyGetBuffer) (val interface{}) {
+	switch f.Kind {
+	case KindTime:
+		var v time.Time
+		kg.Time(&v)
+		val = v
+	case KindUint64:
+		var v uint64
+		kg.Uint64(&v)
+		val = v
+	case KindInt64:
+		var v int64
+		kg.Int64(&v)
+		val = v
+	case KindUint32:
+		var v uint32
+		kg.Uint32(&v)
+		val = v
+	case KindInt32:
+		var v int32
+		kg.Int32(&v)
+		val = v
+	case KindUint16:
+		var v uint16
+		kg.Uint16(&v)
+		val = v
+	case KindInt16:
+		var v int16
+		kg.Int16(&v)
+		val = v
+	case KindUint8:
+		var v uint8
+		kg.Uint8(&v)
+		val = v
+	case KindInt8:
+		var v int8
+		kg.Int8(&v)
+		val = v
+	case KindStr:
+		var v string
+		kg.Str(&v, f.Width)
+		val = v
+	case KindBytes:
+		var v []byte
+		kg.Bytes(&v, f.Width)
+		val = v
+	case KindVarStr:
+		var v string
+		kg.VarStr(&v)
+		val = v
+	case KindVarBytes:
+		var v []byte
+		kg.VarBytes(&v)
+		val = v
+	default:
+		kg.SetError(fmt.Errorf("key field has unsupported kind %s", f.Kind))
+	}
+	return
+}
+
+// Encode builds a key from the specified values. There must be one value for
+// each field in the schema, and each value must have the Go type that
+// corresponds to its field kind, for example uint32 for KindUint32 and
+// time.Time for KindTime.
+func (ks *KeySchema) Encode(vals ...interface{}) ([]byte, error) {
+	var kb KeyBuffer
+	if len(vals) != len(ks.fields) {
+		kb.SetError(errFieldCount)
+	}
+	for j, val := range vals {
+		if kb.err == nil {
+			ks.fields[j].put(&kb, j, val)
+		}
+	}
+	return kb.Data()
+}
+
+// Key builds a key from the specified values in order without requiring a
+// schema. The kind of each field is determined by the Go type of its value:
+// strings and byte slices are stored with VarStr and VarBytes, values of type
+// int and uint are stored as 64-bit integers, and values of type time.Time,
+// ID and *big.Int are stored with the KeyBuffer methods of the same name. Any
+// other integer type is stored with the method of its name. An error is
+// returned if a value has any other type.
+func Key(vals ...interface{}) ([]byte, error) {
+	var kb KeyBuffer
+	for j, val := range vals {
+		switch v := val.(type) {
+		case time.Time:
+			kb.Time(v)
+		case uint64:
+			kb.Uint64(v)
+		case int64:
+			kb.Int64(v)
+		case uint:
+			kb.Uint64(uint64(v))
+		case int:
+			kb.Int64(int64(v))
+		case uint32:
+			kb.Uint32(v)
+		case int32:
+			kb.Int32(v)
+		case uint16:
+			kb.Uint16(v)
+		case int16:
+			kb.Int16(v)
+		case uint8:
+			kb.Uint8(v)
+		case int8:
+			kb.Int8(v)
+		case string:
+			kb.VarStr(v)
+		case []byte:
+			kb.VarBytes(v)
+		case ID:
+			kb.ID(v)
+		case *big.Int:
+			kb.BigInt(v)
+		default:
+			kb.SetError(fmt.Errorf("key value #%d has unsupported type %T", j, val))
+		}
+		if kb.err != nil {
+			break
+		}
+	}
+	return kb.Data()
+}
+
+// Decode extracts the field values from key. The returned values have the Go
+// types documented for Encode.
+func (ks *KeySchema) Decode(key []byte) ([]interface{}, error) {
+	kg := NewKeyGetBuffer(key)
+	vals := make([]interface{}, len(ks.fields))
+	for j, f := range ks.fields {
+		vals[j] = f.get(kg)
+	}
+	if err := kg.Done(); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// Segments returns the portions of key that hold each of its fields. key may
+// be partial, that is, it may contain only a leading sequence of the
+// schema's fields, in which case fewer segments than fields are returned. An
+// error is returned if key ends within a field. The returned segments share
+// memory with key.
+func (ks *KeySchema) Segments(key []byte) (list [][]byte, err error) {
+	kg := NewKeyGetBuffer(key)
+	for j := 0; j < len(ks.fields) && len(kg.buf) > 0; j++ {
+		pos := len(key) - len(kg.buf)
+		ks.fields[j].get(kg)
+		if kg.err != nil {
+			return nil, fmt.Errorf("key field %s: %s", ks.fields[j].label(j), kg.err)
+		}
+		list = append(list, key[pos:len(key)-len(kg.buf)])
+	}
+	if err = kg.Done(); err != nil {
+		list = nil
+	}
+	return
+}
+
+// Compare compares keys a and b field by field. The result is negative, zero
+// or positive when a is less than, equal to or greater than b respectively.
+// Either key may be partial; if all fields present in both keys are equal,
+// the key with fewer fields is the lesser. An error is returned if either key
+// does not conform to the schema.
+func (ks *KeySchema) Compare(a, b []byte) (int, error) {
+	sa, err := ks.Segments(a)
+	if err != nil {
+		return 0, err
+	}
+	sb, err := ks.Segments(b)
+	if err != nil {
+		return 0, err
+	}
+	for j := 0; j < len(sa) && j < len(sb); j++ {
+		if c := bytes.Compare(sa[j], sb[j]); c != 0 {
+			return c, nil
+		}
+	}
+	return len(sa) - len(sb), nil
+}
+
+// Equal reports whether the first n fields of keys a and b are equal. Both
+// keys must contain at least n fields.
+func (ks *KeySchema) Equal(a, b []byte, n int) (bool, error) {
+	sa, err := ks.Segments(a)
+	if err != nil {
+		return false, err
+	}
+	sb, err := ks.Segments(b)
+	if err != nil {
+		return false, err
+	}
+	if len(sa) < n || len(sb) < n {
+		return false, fmt.Errorf("keys do not contain %d fields", n)
+	}
+	for j := 0; j < n; j++ {
+		if !bytes.Equal(sa[j], sb[j]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// HasPrefix reports whether key begins with the complete fields held in the
+// partial key prefix.
+func (ks *KeySchema) HasPrefix(key, prefix []byte) (bool, error) {
+	if _, err := ks.Segments(prefix); err != nil {
+		return false, err
+	}
+	if _, err := ks.Segments(key); err != nil {
+		return false, err
+	}
+	return bytes.HasPrefix(key, prefix), nil
+}
+
+// String returns a human-readable rendering of key, for example when
+// logging or inspecting opaque index entries. If key does not conform to the
+// schema, its hexadecimal representation is returned along with the error
+// text.
+func (ks *KeySchema) String(key []byte) string {
+	vals, err := ks.Decode(key)
+	if err != nil {
+		return fmt.Sprintf("%x (%s)", key, err)
+	}
+	var b bytes.Buffer
+	for j, val := range vals {
+		if j > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s: %s", ks.fields[j].label(j), renderValue(val))
+	}
+	return b.String()
+}
+
+// renderValue returns the human-readable form of a value extracted with a
+// key schema or record schema.
+func renderValue(val interface{}) string {
+	switch v := val.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []byte:
+		return fmt.Sprintf("%x", v)
+	}
+	return fmt.Sprintf("%v", val)
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ExampleKeySchema demonstrates the declaration of a key layout and its use
+// in building, decomposing and displaying keys.
+func ExampleKeySchema() {
+	ks := NewKeySchema(
+		KeyField{Name: "tenant", Kind: KindUint32},
+		KeyField{Name: "name", Kind: KindStr, Width: 8},
+		KeyField{Name: "when", Kind: KindTime},
+		KeyField{Name: "delta", Kind: KindInt16},
+	)
+	key, err := ks.Encode(uint32(7), "example", timeTest, int16(-12))
+	if err == nil {
+		var vals []interface{}
+		vals, err = ks.Decode(key)
+		if err == nil {
+			fmt.Println(len(key), vals[1])
+			fmt.Println(ks.String(key))
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// 22 example
+	// tenant: 7, name: "example", when: 1997-11-28T12:00:00Z, delta: -12
+}
+
+// Ensure that mismatched values are reported when encoding
+func TestKeySchema_Encode(t *testing.T) {
+	ks := NewKeySchema(KeyField{Kind: KindUint16}, KeyField{Kind: KindVarStr})
+	if _, err := ks.Encode(uint16(3)); err == nil {
+		t.Fatal("KeySchema accepted too few values")
+	}
+	if _, err := ks.Encode(3, "abc"); err == nil {
+		t.Fatal("KeySchema accepted value of wrong type")
+	}
+	key, err := ks.Encode(uint16(3), "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ks.Decode(key[:len(key)-1]); err == nil {
+		t.Fatal("KeySchema decoded truncated key")
+	}
+}
+
+// Ensure that partial keys are compared by field
+func TestKeySchema_Compare(t *testing.T) {
+	ks := NewKeySchema(KeyField{Kind: KindUint16}, KeyField{Kind: KindVarStr},
+		KeyField{Kind: KindInt8})
+	full, err := ks.Encode(uint16(3), "abc", int8(-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ks.Encode(uint16(3), "abd", int8(-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := full[:2+4]
+	if c, err := ks.Compare(prefix, full); err != nil || c >= 0 {
+		t.Fatalf("partial key does not sort first: %d, %v", c, err)
+	}
+	if c, err := ks.Compare(other, full); err != nil || c <= 0 {
+		t.Fatalf("unexpected comparison: %d, %v", c, err)
+	}
+	if eq, err := ks.Equal(full, other, 1); err != nil || !eq {
+		t.Fatalf("leading fields not equal: %v", err)
+	}
+	if eq, err := ks.Equal(full, other, 2); err != nil || eq {
+		t.Fatalf("second fields equal: %v", err)
+	}
+	if ok, err := ks.HasPrefix(full, prefix); err != nil || !ok {
+		t.Fatalf("prefix not recognized: %v", err)
+	}
+	if ok, err := ks.HasPrefix(other, prefix); err != nil || ok {
+		t.Fatalf("prefix incorrectly recognized: %v", err)
+	}
+	if _, err = ks.Compare(full[:3], full); err == nil {
+		t.Fatal("key ending within a field not reported")
+	}
+	if _, err = ks.Segments(append(full, 0)); err == nil {
+		t.Fatal("key with excess content not reported")
+	}
+}
+
+// ExampleKey demonstrates building a key from a list of values.
+func ExampleKey() {
+	key, err := Key(uint16(7), "ab", int8(-1), timeTest)
+	if err == nil {
+		fmt.Printf("% x\n", key)
+	} else {
+		fmt.Println(err)
+	}
+	_, err = Key(uint16(7), 1.5)
+	fmt.Println(err)
+	// Output:
+	// 00 07 61 62 00 7f 80 00 00 00 34 7e b2 40
+	// key value #1 has unsupported type float64
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"errors"
+)
+
+var errSharedPrefix = errors.New("key stream shared prefix exceeds length of previous key")
+
+// sharedLen returns the number of leading bytes that a and b have in common.
+func sharedLen(a, b []byte) (n int) {
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return
+}
+
+// KeyStreamWriter packs a sequence of keys, typically sorted, into a compact
+// byte sequence. Each key is stored as the length of the prefix it shares with
+// the preceding key followed by the remaining suffix. Keys built with a
+// KeyBuffer tend to share long leading segments, so this can substantially
+// reduce the size of index blocks and log segments. The zero value for a
+// variable of type KeyStreamWriter is ready to use.
+type KeyStreamWriter struct {
+	put  PutBuffer
+	prev []byte
+}
+
+// Key packs the specified key into the receiving key stream.
+func (ksw *KeyStreamWriter) Key(key []byte) {
+	if ksw.put.err == nil {
+		n := sharedLen(ksw.prev, key)
+		ksw.put.Uint64(uint64(n))
+		ksw.put.Bytes(key[n:])
+		ksw.prev = append(ksw.prev[:0], key...)
+	}
+}
+
+// SetError permits the caller to assign an error value to the key stream
+// writer. This method unconditionally overwrites the current internal error
+// value.
+func (ksw *KeyStreamWriter) SetError(err error) {
+	ksw.put.SetError(err)
+}
+
+// Data returns the packed key stream in the form of a byte slice. The second
+// return value is an error code that will be nil if all keys have been
+// successfully packed.
+func (ksw *KeyStreamWriter) Data() ([]byte, error) {
+	return ksw.put.DataUnsafe()
+}
+
+// KeyStreamReader reconstructs the full keys from a byte sequence that was
+// generated using a KeyStreamWriter.
+type KeyStreamReader struct {
+	get *GetBuffer
+	key []byte
+}
+
+// NewKeyStreamReader returns an initialized reader that can be used to
+// extract keys from data. data specifies a byte slice that was generated using
+// a KeyStreamWriter.
+func NewKeyStreamReader(data []byte) *KeyStreamReader {
+	return &KeyStreamReader{get: NewGetBuffer(data)}
+}
+
+// Next advances the reader to the next key in the stream. It returns false
+// when no keys remain or an error has occurred; call Done to distinguish
+// between these cases.
+func (ksr *KeyStreamReader) Next() bool {
+	get := ksr.get
+	if get.err != nil || !get.more() {
+		return false
+	}
+	var n uint64
+	var suffix []byte
+	get.Uint64(&n)
+	get.Bytes(&suffix)
+	if get.err == nil {
+		if n > uint64(len(ksr.key)) {
+			get.err = errSharedPrefix
+		} else {
+			ksr.key = append(ksr.key[:n:n], suffix...)
+		}
+	}
+	return get.err == nil
+}
+
+// Key returns the key at the current position of the reader. The returned
+// slice is not modified by subsequent calls to Next.
+func (ksr *KeyStreamReader) Key() []byte {
+	return ksr.key
+}
+
+// Done is called to indicate that the key stream has been read. If no error
+// has occurred and no content remains buffered, nil is returned, otherwise an
+// appropriate error value.
+func (ksr *KeyStreamReader) Done() error {
+	return ksr.get.Done()
+}
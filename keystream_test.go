@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ExampleKeyStreamWriter demonstrates the prefix compression of a sorted
+// sequence of keys and their subsequent reconstruction.
+func ExampleKeyStreamWriter() {
+	var ksw KeyStreamWriter
+	var size int
+	for _, name := range []string{"apple", "applesauce", "apricot", "banana"} {
+		var kb KeyBuffer
+		kb.Uint32(7)
+		kb.Str(name, 12)
+		key, _ := kb.Data()
+		size += len(key)
+		ksw.Key(key)
+	}
+	data, err := ksw.Data()
+	if err == nil {
+		fmt.Printf("%d bytes of keys packed into %d bytes\n", size, len(data))
+		ksr := NewKeyStreamReader(data)
+		for ksr.Next() {
+			fmt.Printf("[%s]\n", ksr.Key()[4:])
+		}
+		err = ksr.Done()
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// 64 bytes of keys packed into 53 bytes
+	// [apple       ]
+	// [applesauce  ]
+	// [apricot     ]
+	// [banana      ]
+}
+
+// Ensure that a corrupt shared prefix length is reported
+func TestKeyStreamReader_Corrupt(t *testing.T) {
+	var put PutBuffer
+	put.Uint64(3)
+	put.Bytes([]byte("abc"))
+	data, _ := put.Data()
+	ksr := NewKeyStreamReader(data)
+	if ksr.Next() {
+		t.Fatal("KeyStreamReader accepted shared prefix longer than previous key")
+	}
+	if ksr.Done() == nil {
+		t.Fatal("KeyStreamReader error not reported")
+	}
+}
@@ -0,0 +1,199 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// KV is implemented by adapters for ordered key/value stores, such as bbolt,
+// Badger or LevelDB, so that Table can perform typed operations on any of
+// them. Keys are compared bytewise. The slices passed to fn by Scan, like
+// those of many embedded stores, need only remain valid until fn returns.
+type KV interface {
+	// Get returns the value associated with key. The second return value is
+	// false if there is none.
+	Get(key []byte) (val []byte, ok bool, err error)
+	// Put associates val with key, replacing any existing value.
+	Put(key, val []byte) error
+	// Delete removes key and its value. Deleting a missing key is not an
+	// error.
+	Delete(key []byte) error
+	// Scan calls fn for each pair with a key at or after start and before
+	// end, in key order, until fn returns false. A nil end continues through
+	// the last key.
+	Scan(start, end []byte, fn func(key, val []byte) bool) error
+}
+
+// MemKV is an in-memory implementation of KV, suitable for tests and small
+// caches. The zero value for a variable of type MemKV is ready to use. It is
+// safe for concurrent use, although fn must not modify the store during a
+// call to Scan.
+type MemKV struct {
+	mu   sync.RWMutex
+	keys []string // sorted
+	vals map[string][]byte
+}
+
+// Get implements the KV interface.
+func (m *MemKV) Get(key []byte) (val []byte, ok bool, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	val, ok = m.vals[string(key)]
+	return append([]byte(nil), val...), ok, nil
+}
+
+// Put implements the KV interface.
+func (m *MemKV) Put(key, val []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := string(key)
+	if _, ok := m.vals[k]; !ok {
+		if m.vals == nil {
+			m.vals = make(map[string][]byte)
+		}
+		j := sort.SearchStrings(m.keys, k)
+		m.keys = append(m.keys, "")
+		copy(m.keys[j+1:], m.keys[j:])
+		m.keys[j] = k
+	}
+	m.vals[k] = append([]byte(nil), val...)
+	return nil
+}
+
+// Delete implements the KV interface.
+func (m *MemKV) Delete(key []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := string(key)
+	if _, ok := m.vals[k]; ok {
+		delete(m.vals, k)
+		j := sort.SearchStrings(m.keys, k)
+		m.keys = append(m.keys[:j], m.keys[j+1:]...)
+	}
+	return nil
+}
+
+// Scan implements the KV interface.
+func (m *MemKV) Scan(start, end []byte, fn func(key, val []byte) bool) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for j := sort.SearchStrings(m.keys, string(start)); j < len(m.keys); j++ {
+		key := []byte(m.keys[j])
+		if end != nil && bytes.Compare(key, end) >= 0 {
+			break
+		}
+		if !fn(key, m.vals[m.keys[j]]) {
+			break
+		}
+	}
+	return nil
+}
+
+// Table provides typed access to records of type T held in a KV store. Keys
+// are built from values with a KeySchema and records are packed with a
+// Codec, so that common operations require neither key building nor
+// marshaling at each call site.
+type Table[T any] struct {
+	kv    KV
+	keys  *KeySchema
+	codec Codec[T]
+}
+
+// NewTable returns a table of records of type T in kv, with keys described by
+// keys and records packed by codec. See LookupCodec for obtaining the codec
+// registered for T.
+func NewTable[T any](kv KV, keys *KeySchema, codec Codec[T]) *Table[T] {
+	return &Table[T]{kv: kv, keys: keys, codec: codec}
+}
+
+// Get returns the record with the key built from keyVals, which must hold a
+// value for each field of the table's key schema. The second return value is
+// false if there is no such record.
+func (t *Table[T]) Get(keyVals ...interface{}) (val T, ok bool, err error) {
+	var key, data []byte
+	if key, err = t.keys.Encode(keyVals...); err == nil {
+		if data, ok, err = t.kv.Get(key); ok && err == nil {
+			val, err = t.codec.Get(data)
+		}
+	}
+	return
+}
+
+// Put stores val with the key built from keyVals, replacing any existing
+// record.
+func (t *Table[T]) Put(val T, keyVals ...interface{}) error {
+	key, err := t.keys.Encode(keyVals...)
+	if err != nil {
+		return err
+	}
+	data, err := t.codec.Put(val)
+	if err != nil {
+		return err
+	}
+	return t.kv.Put(key, data)
+}
+
+// Delete removes the record with the key built from keyVals.
+func (t *Table[T]) Delete(keyVals ...interface{}) error {
+	key, err := t.keys.Encode(keyVals...)
+	if err != nil {
+		return err
+	}
+	return t.kv.Delete(key)
+}
+
+// Scan calls fn, in key order, with the key values and record of each entry
+// whose leading key fields equal prefixVals, until fn returns false. An empty
+// prefixVals scans the whole table; a full set of key values visits at most
+// one record. The key values have the Go types documented for
+// KeySchema.Encode. An error unpacking a key or record stops the scan and is
+// returned.
+func (t *Table[T]) Scan(fn func(keyVals []interface{}, val T) bool, prefixVals ...interface{}) error {
+	if len(prefixVals) > len(t.keys.fields) {
+		return fmt.Errorf("%d prefix values exceed %d key fields", len(prefixVals), len(t.keys.fields))
+	}
+	prefix, err := (&KeySchema{fields: t.keys.fields[:len(prefixVals)]}).Encode(prefixVals...)
+	if err != nil {
+		return err
+	}
+	var scanErr error
+	err = t.kv.Scan(prefix, PrefixSuccessor(prefix), func(key, data []byte) bool {
+		var keyVals []interface{}
+		var val T
+		if keyVals, scanErr = t.keys.Decode(key); scanErr != nil {
+			return false
+		}
+		// A variable-length field in the prefix also matches longer values
+		// that begin with an escaped zero byte
+		var match bool
+		if match, scanErr = t.keys.Equal(key, prefix, len(prefixVals)); scanErr != nil || !match {
+			return scanErr == nil
+		}
+		if val, scanErr = t.codec.Get(data); scanErr != nil {
+			return false
+		}
+		return fn(keyVals, val)
+	})
+	if err == nil {
+		err = scanErr
+	}
+	return err
+}
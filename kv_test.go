@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+type kvOrder struct {
+	Item string
+	Qty  uint32
+}
+
+var kvOrderCodec = Codec[kvOrder]{
+	Enc: func(put *PutBuffer, o kvOrder) {
+		put.Str(o.Item)
+		put.Uint32(o.Qty)
+	},
+	Dec: func(get *GetBuffer, o *kvOrder) {
+		get.Str(&o.Item)
+		get.Uint32(&o.Qty)
+	},
+}
+
+func ExampleTable() {
+	keys := NewKeySchema(KeyField{Name: "tenant", Kind: KindUint32}, KeyField{Name: "order", Kind: KindUint64})
+	orders := NewTable[kvOrder](new(MemKV), keys, kvOrderCodec)
+	orders.Put(kvOrder{"gear", 4}, uint32(1), uint64(100))
+	orders.Put(kvOrder{"pinion", 2}, uint32(1), uint64(101))
+	orders.Put(kvOrder{"rack", 1}, uint32(2), uint64(100))
+	o, ok, err := orders.Get(uint32(1), uint64(101))
+	fmt.Println(o, ok, err)
+	orders.Scan(func(keyVals []interface{}, o kvOrder) bool {
+		fmt.Println(keyVals, o)
+		return true
+	}, uint32(1))
+	// Output:
+	// {pinion 2} true <nil>
+	// [1 100] {gear 4}
+	// [1 101] {pinion 2}
+}
+
+func TestTable(t *testing.T) {
+	var kv MemKV
+	keys := NewKeySchema(KeyField{Kind: KindUint8}, KeyField{Kind: KindVarStr})
+	tbl := NewTable[kvOrder](&kv, keys, kvOrderCodec)
+	for j, name := range []string{"b", "a", "c", "a\x00"} {
+		if err := tbl.Put(kvOrder{name, uint32(j)}, uint8(j%2), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tbl.Put(kvOrder{}, uint8(0)); err == nil {
+		t.Fatal("expecting error for missing key value")
+	}
+	var names []string
+	collect := func(keyVals []interface{}, o kvOrder) bool {
+		names = append(names, o.Item)
+		return true
+	}
+	if err := tbl.Scan(collect); err != nil || fmt.Sprint(names) != "[b c a a\x00]" {
+		t.Fatalf("unexpected scan %q: %v", names, err)
+	}
+	names = nil
+	if err := tbl.Scan(collect, uint8(1), "a"); err != nil || fmt.Sprint(names) != "[a]" {
+		t.Fatalf("unexpected scan %q: %v", names, err)
+	}
+	if err := tbl.Scan(collect, uint8(1), "a", 3); err == nil {
+		t.Fatal("expecting error for excess prefix values")
+	}
+	if err := tbl.Delete(uint8(1), "a"); err != nil {
+		t.Fatal(err)
+	}
+	tbl.Delete(uint8(1), "a")
+	if _, ok, err := tbl.Get(uint8(1), "a"); ok || err != nil {
+		t.Fatalf("deleted record found: %v", err)
+	}
+	if len(kv.keys) != 3 || len(kv.vals) != 3 {
+		t.Fatalf("unexpected store size %d", len(kv.keys))
+	}
+	kv.Put([]byte{9}, []byte{1})
+	if err := tbl.Scan(collect); err == nil {
+		t.Fatal("expecting error for malformed key")
+	}
+}
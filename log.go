@@ -0,0 +1,273 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sync"
+	"time"
+)
+
+// logTable is the CRC-32 table, using the Castagnoli polynomial, with which
+// log records are checksummed.
+var logTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errLogChecksum = &categoryError{"log record checksum does not match content", ErrChecksum}
+
+// LogWriter appends records to an append-only log, typically a file opened
+// with os.O_APPEND. Each record is framed by its length as a variable-length
+// integer and a CRC-32 checksum that covers both the length and the content,
+// so that a LogReader can detect a record that was only partially written,
+// for example because of a crash.
+type LogWriter struct {
+	w        io.Writer
+	hold     []byte
+	observe  func(op LogOp, elapsed time.Duration)
+	deadline time.Duration
+	stalled  func(elapsed time.Duration)
+	err      error
+}
+
+// LogOp identifies an operation of a LogWriter on its underlying writer.
+type LogOp int
+
+// Operations reported to the observer of a LogWriter.
+const (
+	LogWrite LogOp = iota // the write of a single record by Append
+	LogSync               // the call to the Sync method of the writer
+)
+
+func (op LogOp) String() string {
+	switch op {
+	case LogWrite:
+		return "write"
+	case LogSync:
+		return "sync"
+	}
+	return "unknown"
+}
+
+// NewLogWriter returns a log writer that appends to w.
+func NewLogWriter(w io.Writer) *LogWriter {
+	return &LogWriter{w: w}
+}
+
+// SetObserver assigns a function that is called with the duration of each
+// write and sync that the log writer performs on its underlying writer, for
+// example to record latency histograms with LatencyHistogram or an external
+// metrics package. It is called after the operation completes, whether or not
+// it succeeded. A nil function, the default, disables the measurement.
+func (lw *LogWriter) SetObserver(fn func(op LogOp, elapsed time.Duration)) {
+	lw.observe = fn
+}
+
+// SetSyncDeadline assigns a function that is called when a call to Sync has
+// not completed within the specified deadline. The function is called from a
+// separate goroutine while the sync is still in progress, so that a stalled
+// device can be reported even if the sync never returns; elapsed is the time
+// since the sync began. A deadline that is not positive or a nil function,
+// the default, disables the watchdog.
+func (lw *LogWriter) SetSyncDeadline(deadline time.Duration, fn func(elapsed time.Duration)) {
+	lw.deadline = deadline
+	lw.stalled = fn
+}
+
+// Append writes rec as the next record of the log. Each record is passed to
+// the underlying writer in a single call; the record is durable once Sync has
+// returned without error. Once an error has occurred, no further records are
+// written and the error is returned by all subsequent calls.
+func (lw *LogWriter) Append(rec []byte) error {
+	if lw.err == nil {
+		var hdr [binary.MaxVarintLen64 + 4]byte
+		n := binary.PutUvarint(hdr[:], uint64(len(rec)))
+		sum := crc32.Update(crc32.Checksum(hdr[:n], logTable), logTable, rec)
+		binary.BigEndian.PutUint32(hdr[n:], sum)
+		lw.hold = append(append(lw.hold[:0], hdr[:n+4]...), rec...)
+		if lw.observe == nil {
+			_, lw.err = lw.w.Write(lw.hold)
+		} else {
+			begin := time.Now()
+			_, lw.err = lw.w.Write(lw.hold)
+			lw.observe(LogWrite, time.Since(begin))
+		}
+	}
+	return lw.err
+}
+
+// Sync commits the records appended so far to stable storage by calling the
+// Sync method of the underlying writer, such as os.File.Sync. If the writer
+// has no such method, Sync does nothing and returns nil. An error is latched
+// in the same way as for Append.
+func (lw *LogWriter) Sync() error {
+	syncer, ok := lw.w.(interface{ Sync() error })
+	if lw.err != nil || !ok {
+		return lw.err
+	}
+	begin := time.Now()
+	if lw.deadline > 0 && lw.stalled != nil {
+		fn := lw.stalled
+		watchdog := time.AfterFunc(lw.deadline, func() { fn(time.Since(begin)) })
+		defer watchdog.Stop()
+	}
+	lw.err = syncer.Sync()
+	if lw.observe != nil {
+		lw.observe(LogSync, time.Since(begin))
+	}
+	return lw.err
+}
+
+// latencyBuckets is the number of buckets of a LatencyHistogram.
+const latencyBuckets = 32
+
+// LatencyHistogram counts durations in buckets whose upper bounds are
+// successive powers of two microseconds, from 1µs to about 36 minutes; the
+// final bucket also counts all longer durations. It is safe for concurrent
+// use, and its Observe method can be called from the observer of a
+// LogWriter. The zero value is an empty histogram.
+type LatencyHistogram struct {
+	mu     sync.Mutex
+	counts [latencyBuckets]uint64
+}
+
+// Observe adds elapsed to the histogram.
+func (h *LatencyHistogram) Observe(elapsed time.Duration) {
+	j := 0
+	for j < latencyBuckets-1 && elapsed > LatencyBound(j) {
+		j++
+	}
+	h.mu.Lock()
+	h.counts[j]++
+	h.mu.Unlock()
+}
+
+// Counts returns a copy of the count of each bucket of the histogram. The
+// upper bound of bucket j is returned by LatencyBound(j).
+func (h *LatencyHistogram) Counts() []uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]uint64(nil), h.counts[:]...)
+}
+
+// LatencyBound returns the inclusive upper bound of bucket j of a
+// LatencyHistogram.
+func LatencyBound(j int) time.Duration {
+	return time.Microsecond << uint(j)
+}
+
+// LogReader replays the records of a log that was written by a LogWriter.
+type LogReader struct {
+	rd    *bufio.Reader
+	rec   []byte
+	limit int
+	off   int64
+	torn  bool
+	err   error
+}
+
+// NewLogReader returns a log reader that reads from r, which should be
+// positioned at the start of the log.
+func NewLogReader(r io.Reader) *LogReader {
+	return &LogReader{rd: bufio.NewReader(r), limit: RecordLimit}
+}
+
+// SetLimit assigns the maximum length of a record. A record with a greater
+// length sets an error that matches ErrLimitExceeded. The default limit is
+// RecordLimit.
+func (lr *LogReader) SetLimit(n int) {
+	lr.limit = n
+}
+
+// Next advances the reader to the next intact record of the log. It returns
+// false when no records remain, when the final record is torn, or when an
+// error has occurred; call Done to distinguish between these cases.
+func (lr *LogReader) Next() bool {
+	if lr.err != nil {
+		return false
+	}
+	var hdr [binary.MaxVarintLen64 + 4]byte
+	var n uint64
+	n, lr.err = binary.ReadUvarint(lr.rd)
+	if lr.err == nil && n > uint64(lr.limit) {
+		lr.err = categoryErrorf(ErrLimitExceeded, "log record of %d bytes exceeds limit of %d bytes", n, lr.limit)
+	}
+	if lr.err == nil {
+		size := binary.PutUvarint(hdr[:], n)
+		if uint64(cap(lr.rec)) < n {
+			lr.rec = make([]byte, n)
+		}
+		lr.rec = lr.rec[:n]
+		if _, lr.err = io.ReadFull(lr.rd, hdr[size:size+4]); lr.err == nil {
+			_, lr.err = io.ReadFull(lr.rd, lr.rec)
+		}
+		if lr.err == io.EOF {
+			lr.err = io.ErrUnexpectedEOF
+		}
+		if lr.err == nil {
+			sum := crc32.Update(crc32.Checksum(hdr[:size], logTable), logTable, lr.rec)
+			if sum == binary.BigEndian.Uint32(hdr[size:]) {
+				lr.off += int64(size) + 4 + int64(n)
+				return true
+			}
+			// A damaged record that ends the log is the remnant of an
+			// interrupted append rather than corruption.
+			lr.err = errLogChecksum
+			if _, err := lr.rd.Peek(1); err == io.EOF {
+				lr.err = io.ErrUnexpectedEOF
+			}
+		}
+	}
+	if lr.err == io.ErrUnexpectedEOF {
+		lr.torn = true
+		lr.err = io.EOF
+	}
+	return false
+}
+
+// Record returns the record at the current position of the reader. The
+// returned slice is overwritten by a subsequent call to Next.
+func (lr *LogReader) Record() []byte {
+	return lr.rec
+}
+
+// Offset returns the length of the log up to and including the most recent
+// record returned by Next. After a torn final record has been encountered,
+// the log can be truncated to this length before further records are
+// appended.
+func (lr *LogReader) Offset() int64 {
+	return lr.off
+}
+
+// Torn returns true if the log ended with a record that was only partially
+// written.
+func (lr *LogReader) Torn() bool {
+	return lr.torn
+}
+
+// Done is called to indicate that the log has been replayed. It returns nil
+// if the log ended after an intact record or with a torn record; use Torn to
+// distinguish between these cases. Otherwise, for example if a record before
+// the last one is damaged, the error that stopped the reader is returned; a
+// checksum mismatch matches ErrChecksum.
+func (lr *LogReader) Done() error {
+	if lr.err == io.EOF {
+		return nil
+	}
+	return lr.err
+}
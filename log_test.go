@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func ExampleLogWriter() {
+	var b bytes.Buffer
+	lw := NewLogWriter(&b)
+	for _, rec := range []string{"set a=1", "set b=2", "del a"} {
+		lw.Append([]byte(rec))
+	}
+	// Simulate a crash during the final append
+	log := b.Bytes()[:b.Len()-2]
+	lr := NewLogReader(bytes.NewReader(log))
+	for lr.Next() {
+		fmt.Println(string(lr.Record()))
+	}
+	fmt.Println(lr.Done(), lr.Torn(), lr.Offset())
+	// Output:
+	// set a=1
+	// set b=2
+	// <nil> true 24
+}
+
+func TestLogReader(t *testing.T) {
+	var b bytes.Buffer
+	lw := NewLogWriter(&b)
+	for _, rec := range [][]byte{{}, bytes.Repeat([]byte{9}, 200), {1, 2, 3}} {
+		if err := lw.Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log := b.Bytes()
+	replay := func(data []byte) (n int, lr *LogReader) {
+		lr = NewLogReader(bytes.NewReader(data))
+		for lr.Next() {
+			n++
+		}
+		return
+	}
+	n, lr := replay(log)
+	if n != 3 || lr.Done() != nil || lr.Torn() || lr.Offset() != int64(len(log)) {
+		t.Fatalf("unexpected replay of intact log: %d %v", n, lr.Done())
+	}
+	for j := 1; j < len(log); j++ {
+		n, lr = replay(log[:j])
+		if lr.Done() != nil || (lr.Torn() != (lr.Offset() < int64(j))) || n > 2 {
+			t.Fatalf("unexpected replay of log truncated at %d: %d %v", j, n, lr.Done())
+		}
+	}
+	damaged := append([]byte(nil), log...)
+	damaged[len(damaged)-1] ^= 1
+	if n, lr = replay(damaged); n != 2 || lr.Done() != nil || !lr.Torn() {
+		t.Fatalf("damaged final record not treated as torn: %d %v", n, lr.Done())
+	}
+	damaged = append([]byte(nil), log...)
+	damaged[10] ^= 1
+	if n, lr = replay(damaged); n != 1 || !errors.Is(lr.Done(), ErrChecksum) {
+		t.Fatalf("expecting checksum error, got %d %v", n, lr.Done())
+	}
+	lr = NewLogReader(bytes.NewReader(log))
+	lr.SetLimit(100)
+	for lr.Next() {
+	}
+	if !errors.Is(lr.Done(), ErrLimitExceeded) {
+		t.Fatalf("expecting limit exceeded, got %v", lr.Done())
+	}
+}
+
+// stallFile is a log destination whose Sync blocks until release is closed.
+type stallFile struct {
+	bytes.Buffer
+	release chan struct{}
+	syncs   int
+}
+
+func (f *stallFile) Sync() error {
+	f.syncs++
+	if f.release != nil {
+		<-f.release
+	}
+	return nil
+}
+
+func TestLogWriter_Sync(t *testing.T) {
+	var writes, syncs LatencyHistogram
+	f := &stallFile{release: make(chan struct{})}
+	lw := NewLogWriter(f)
+	lw.SetObserver(func(op LogOp, elapsed time.Duration) {
+		switch op {
+		case LogWrite:
+			writes.Observe(elapsed)
+		case LogSync:
+			syncs.Observe(elapsed)
+		}
+	})
+	var stall time.Duration
+	lw.SetSyncDeadline(time.Millisecond, func(elapsed time.Duration) {
+		stall = elapsed
+		close(f.release)
+	})
+	for _, rec := range []string{"set a=1", "set b=2"} {
+		if err := lw.Append([]byte(rec)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The sync only completes once the watchdog has reported it as stalled
+	if err := lw.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if f.syncs != 1 || stall < time.Millisecond {
+		t.Fatalf("expecting one stalled sync, got %d syncs, stall of %v", f.syncs, stall)
+	}
+	sum := func(counts []uint64) (n uint64) {
+		for _, c := range counts {
+			n += c
+		}
+		return
+	}
+	if n := sum(writes.Counts()); n != 2 {
+		t.Fatalf("expecting 2 observed writes, got %d", n)
+	}
+	counts := syncs.Counts()
+	if n := sum(counts); n != 1 || counts[0] != 0 {
+		t.Fatalf("expecting 1 observed sync of at least 1ms, got %v", counts)
+	}
+
+	// A sync within the deadline is not reported
+	f.release = nil
+	lw.SetSyncDeadline(time.Hour, func(time.Duration) { t.Fatal("unexpected stall") })
+	if err := lw.Sync(); err != nil || f.syncs != 2 {
+		t.Fatalf("expecting second sync, got %v, %d syncs", err, f.syncs)
+	}
+
+	// A writer without a Sync method is left alone
+	lw = NewLogWriter(new(bytes.Buffer))
+	lw.SetObserver(func(op LogOp, _ time.Duration) {
+		if op == LogSync {
+			t.Fatal("unexpected sync")
+		}
+	})
+	if err := lw.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	var h LatencyHistogram
+	h.Observe(0)
+	h.Observe(time.Microsecond)
+	h.Observe(3 * time.Microsecond)
+	h.Observe(24 * time.Hour)
+	counts = h.Counts()
+	if counts[0] != 2 || counts[2] != 1 || counts[len(counts)-1] != 1 {
+		t.Fatalf("unexpected histogram counts %v", counts)
+	}
+	if LatencyBound(2) != 4*time.Microsecond {
+		t.Fatalf("unexpected bound %v", LatencyBound(2))
+	}
+}
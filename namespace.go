@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+)
+
+// Namespace applies a common prefix, such as a tenant or table identifier, to
+// every key built within it. Because the prefix is applied in one place, keys
+// of different tenants or tables cannot be confused, and the range covered by
+// the namespace is readily available for scans and bulk deletion.
+type Namespace struct {
+	prefix []byte
+}
+
+// NewNamespace returns a namespace whose keys begin with prefix. The prefix
+// is typically built with a KeyBuffer.
+func NewNamespace(prefix []byte) *Namespace {
+	return &Namespace{prefix: append([]byte(nil), prefix...)}
+}
+
+// Prefix returns the prefix of the receiving namespace.
+func (ns *Namespace) Prefix() []byte {
+	return append([]byte(nil), ns.prefix...)
+}
+
+// Key returns a key buffer that already holds the namespace prefix. Fields
+// stored into it follow the prefix.
+func (ns *Namespace) Key() *KeyBuffer {
+	kb := new(KeyBuffer)
+	kb.write(ns.prefix)
+	return kb
+}
+
+// Sub returns a namespace nested within the receiving namespace. Its prefix
+// is the receiving namespace's prefix followed by prefix.
+func (ns *Namespace) Sub(prefix []byte) *Namespace {
+	return &Namespace{prefix: append(ns.Prefix(), prefix...)}
+}
+
+// Range returns the inclusive start and exclusive end of the range of keys
+// within the receiving namespace. See PrefixSuccessor for the meaning of a
+// nil end.
+func (ns *Namespace) Range() (start, end []byte) {
+	return ns.Prefix(), PrefixSuccessor(ns.prefix)
+}
+
+// Contains reports whether key lies within the receiving namespace.
+func (ns *Namespace) Contains(key []byte) bool {
+	return bytes.HasPrefix(key, ns.prefix)
+}
+
+// Strip returns key without the namespace prefix. The second return value is
+// false if key does not lie within the namespace, in which case the returned
+// slice is nil.
+func (ns *Namespace) Strip(key []byte) ([]byte, bool) {
+	if ns.Contains(key) {
+		return key[len(ns.prefix):], true
+	}
+	return nil, false
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+)
+
+// ExampleNamespace demonstrates the construction of keys within a tenant's
+// namespace.
+func ExampleNamespace() {
+	tenant, err := Key(uint32(7))
+	if err == nil {
+		ns := NewNamespace(tenant).Sub([]byte("orders/"))
+		kb := ns.Key()
+		kb.Uint16(12)
+		var key []byte
+		key, err = kb.Data()
+		if err == nil {
+			start, end := ns.Range()
+			rest, ok := ns.Strip(key)
+			fmt.Printf("% x\n% x\n% x\n%v % x\n", key, start, end, ok, rest)
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// 00 00 00 07 6f 72 64 65 72 73 2f 00 0c
+	// 00 00 00 07 6f 72 64 65 72 73 2f
+	// 00 00 00 07 6f 72 64 65 72 73 30
+	// true 00 0c
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"sync"
+)
+
+var (
+	keyPool = sync.Pool{New: func() interface{} { return new(KeyBuffer) }}
+	putPool = sync.Pool{New: func() interface{} { return new(PutBuffer) }}
+	getPool = sync.Pool{New: func() interface{} { return new(GetBuffer) }}
+)
+
+// AcquireKey returns an empty key buffer from a package-level pool. The
+// buffer has default settings and may retain memory from previous use, so
+// building many keys with pooled buffers causes few allocations. Return the
+// buffer with ReleaseKey when it is no longer needed.
+func AcquireKey() *KeyBuffer {
+	return keyPool.Get().(*KeyBuffer)
+}
+
+// ReleaseKey resets kb, including its settings, and returns it to the pool
+// used by AcquireKey. Neither kb nor any slice obtained from its Data method
+// may be used after it is released.
+func ReleaseKey(kb *KeyBuffer) {
+	kb.Reset()
+	kb.collate = nil
+	kb.strict = false
+	kb.pad = 0
+	kb.padSet = false
+	keyPool.Put(kb)
+}
+
+// AcquirePut returns an empty storage buffer from a package-level pool.
+// Return the buffer with ReleasePut when it is no longer needed.
+func AcquirePut() *PutBuffer {
+	return putPool.Get().(*PutBuffer)
+}
+
+// ReleasePut resets put, including its dry run mode and tee writer, and
+// returns it to the pool used by AcquirePut. Neither put nor any slice
+// obtained from its DataUnsafe method may be used after it is released.
+// Slices obtained from Data remain valid.
+func ReleasePut(put *PutBuffer) {
+	put.Reset()
+	put.dry = false
+	put.tee = nil
+	put.dict = nil
+	put.compress = 0
+	put.checksum = false
+	putPool.Put(put)
+}
+
+// AcquireGet returns a get buffer from a package-level pool, loaded with data
+// as though by NewGetBuffer. The buffer has default settings. Return the
+// buffer with ReleaseGet when it is no longer needed.
+func AcquireGet(data []byte) *GetBuffer {
+	get := getPool.Get().(*GetBuffer)
+	get.Reset(data)
+	return get
+}
+
+// ReleaseGet resets get, including its settings and allocator, and returns
+// it to the pool used by AcquireGet. The get buffer may not be used after it
+// is released. Values already unpacked from it remain valid.
+func ReleaseGet(get *GetBuffer) {
+	get.inflate = false
+	get.verify = false
+	get.Reset(nil)
+	get.alloc = nil
+	get.valueMax = 0
+	get.totalMax = 0
+	get.strict = false
+	get.trailing = false
+	get.utf8 = false
+	get.canonical = false
+	get.depthMax = 0
+	get.dict = nil
+	getPool.Put(get)
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"testing"
+)
+
+// Ensure that pooled key buffers are returned in their default state
+func TestAcquireKey(t *testing.T) {
+	kb := AcquireKey()
+	kb.SetStrict(true)
+	kb.SetPad(0)
+	kb.Str("ab", 3)
+	kb.SetError(errTest)
+	ReleaseKey(kb)
+	for j := 0; j < 4; j++ {
+		kb = AcquireKey()
+		kb.Str("abcd", 3)
+		sl, err := kb.Data()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(sl) != "abc" {
+			t.Fatalf("unexpected key %q", sl)
+		}
+		ReleaseKey(kb)
+	}
+}
+
+// Ensure that pooled storage and get buffers are returned in their default
+// state
+func TestAcquirePut(t *testing.T) {
+	put := AcquirePut()
+	put.Str("abc")
+	put.SetError(errTest)
+	ReleasePut(put)
+	for j := 0; j < 4; j++ {
+		put = AcquirePut()
+		put.Str("abcd")
+		data, err := put.Data()
+		if err != nil {
+			t.Fatal(err)
+		}
+		get := AcquireGet(data)
+		var str string
+		get.Str(&str)
+		if err = get.Done(); err != nil || str != "abcd" {
+			t.Fatalf("unexpected value %q (%v)", str, err)
+		}
+		get.SetLimits(1, 1)
+		get.SetStrict(true)
+		ReleasePut(put)
+		ReleaseGet(get)
+	}
+	get := AcquireGet([]byte{3, 'a', 'b', 'c'})
+	var str string
+	get.Str(&str)
+	if err := get.Done(); err != nil || str != "abc" {
+		t.Fatalf("settings not cleared by ReleaseGet: %v", err)
+	}
+	ReleaseGet(get)
+}
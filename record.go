@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// RecordLimit is the default maximum length of a record read by a
+// RecordReader. See RecordReader.SetLimit.
+const RecordLimit = 16 << 20
+
+var errRecordTorn = &categoryError{"record stream ends within a record", ErrShortBuffer}
+
+// RecordWriter writes a sequence of records to an io.Writer, such as a file
+// or pipe. Each record is preceded by its length as a variable-length
+// integer, so the stream can be read back with a RecordReader.
+type RecordWriter struct {
+	w    io.Writer
+	hold []byte
+	err  error
+}
+
+// NewRecordWriter returns a record writer that writes to w.
+func NewRecordWriter(w io.Writer) *RecordWriter {
+	return &RecordWriter{w: w}
+}
+
+// Write writes rec as the next record of the stream. Each record is passed
+// to the underlying writer in a single call. Once an error has occurred,
+// no further records are written and the error is returned by all
+// subsequent calls.
+func (rw *RecordWriter) Write(rec []byte) error {
+	if rw.err == nil {
+		var hdr [binary.MaxVarintLen64]byte
+		rw.hold = append(rw.hold[:0], hdr[:binary.PutUvarint(hdr[:], uint64(len(rec)))]...)
+		rw.hold = append(rw.hold, rec...)
+		_, rw.err = rw.w.Write(rw.hold)
+	}
+	return rw.err
+}
+
+// Put writes the content of put as the next record of the stream. If put
+// holds an error, it becomes the error of the record writer.
+func (rw *RecordWriter) Put(put *PutBuffer) error {
+	if rw.err == nil {
+		var rec []byte
+		if rec, rw.err = put.DataUnsafe(); rw.err == nil {
+			rw.Write(rec)
+		}
+	}
+	return rw.err
+}
+
+// RecordReader reads a sequence of records that was written by a
+// RecordWriter.
+type RecordReader struct {
+	rd    *bufio.Reader
+	rec   []byte
+	limit int
+	err   error
+}
+
+// NewRecordReader returns a record reader that reads from r. Reads from r
+// are buffered, so the reader may consume data beyond the last record it
+// returns.
+func NewRecordReader(r io.Reader) *RecordReader {
+	return &RecordReader{rd: bufio.NewReader(r), limit: RecordLimit}
+}
+
+// SetLimit assigns the maximum length of a record. A record with a greater
+// length, which may indicate a corrupt stream, sets an error that matches
+// ErrLimitExceeded rather than allocating storage for it. The default limit
+// is RecordLimit.
+func (rr *RecordReader) SetLimit(n int) {
+	rr.limit = n
+}
+
+// Next advances the reader to the next record in the stream. It returns false
+// when no records remain or an error has occurred; call Done to distinguish
+// between these cases.
+func (rr *RecordReader) Next() bool {
+	if rr.err != nil {
+		return false
+	}
+	var n uint64
+	n, rr.err = binary.ReadUvarint(rr.rd)
+	switch {
+	case rr.err == io.ErrUnexpectedEOF:
+		rr.err = errRecordTorn
+	case rr.err != nil:
+	case n > uint64(rr.limit):
+		rr.err = categoryErrorf(ErrLimitExceeded, "record of %d bytes exceeds limit of %d bytes", n, rr.limit)
+	default:
+		if uint64(cap(rr.rec)) < n {
+			rr.rec = make([]byte, n)
+		}
+		rr.rec = rr.rec[:n]
+		if _, rr.err = io.ReadFull(rr.rd, rr.rec); rr.err == io.EOF || rr.err == io.ErrUnexpectedEOF {
+			rr.err = errRecordTorn
+		}
+	}
+	return rr.err == nil
+}
+
+// Record returns the record at the current position of the reader. The
+// returned slice is overwritten by a subsequent call to Next.
+func (rr *RecordReader) Record() []byte {
+	return rr.rec
+}
+
+// Done is called to indicate that the record stream has been read. It
+// returns nil if the stream ended cleanly after a complete record, an error
+// that matches ErrShortBuffer if it ended within a record, and otherwise the
+// error that stopped the reader.
+func (rr *RecordReader) Done() error {
+	if rr.err == io.EOF {
+		return nil
+	}
+	return rr.err
+}
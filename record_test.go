@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"testing/iotest"
+)
+
+func ExampleRecordWriter() {
+	var b bytes.Buffer
+	rw := NewRecordWriter(&b)
+	for _, name := range []string{"gear", "pinion", "rack"} {
+		var put PutBuffer
+		put.Str(name)
+		rw.Put(&put)
+	}
+	rr := NewRecordReader(&b)
+	for rr.Next() {
+		var name string
+		get := NewGetBuffer(rr.Record())
+		get.Str(&name)
+		fmt.Println(name, get.Done())
+	}
+	fmt.Println(rr.Done())
+	// Output:
+	// gear <nil>
+	// pinion <nil>
+	// rack <nil>
+	// <nil>
+}
+
+func TestRecordReader(t *testing.T) {
+	var b bytes.Buffer
+	rw := NewRecordWriter(&b)
+	big := bytes.Repeat([]byte{7}, 300)
+	for _, rec := range [][]byte{{}, big, {1, 2, 3}} {
+		if err := rw.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var put PutBuffer
+	put.SetError(errTest)
+	if err := rw.Put(&put); err != errTest || rw.Write(nil) != errTest {
+		t.Fatal("put buffer error not latched")
+	}
+	data := b.Bytes()
+	rr := NewRecordReader(iotest.OneByteReader(bytes.NewReader(data)))
+	var list [][]byte
+	for rr.Next() {
+		list = append(list, append([]byte(nil), rr.Record()...))
+	}
+	if err := rr.Done(); err != nil || len(list) != 3 || !bytes.Equal(list[1], big) {
+		t.Fatalf("unexpected records %d %v", len(list), err)
+	}
+	for _, n := range []int{len(data) - 1, 2} {
+		rr = NewRecordReader(bytes.NewReader(data[:n]))
+		for rr.Next() {
+		}
+		if err := rr.Done(); !errors.Is(err, ErrShortBuffer) {
+			t.Fatalf("expecting short buffer at %d, got %v", n, err)
+		}
+	}
+	rr = NewRecordReader(bytes.NewReader(data))
+	rr.SetLimit(100)
+	for rr.Next() {
+	}
+	if err := rr.Done(); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expecting limit exceeded, got %v", err)
+	}
+}
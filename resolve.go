@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// SchemaResolver decodes records packed with one version of a schema, the
+// writer's, into records that conform to another, the reader's. Unlike the
+// positional comparison of CheckCompatibility, fields are matched by name,
+// so a resolver permits readers and writers with different schema versions
+// to coexist, for example during a rolling deployment.
+type SchemaResolver struct {
+	steps []resolveStep
+	fill  map[string]interface{}
+}
+
+// resolveStep describes how one field of a writer's record is unpacked.
+type resolveStep struct {
+	field Field
+	keep  bool
+}
+
+// NewSchemaResolver returns a resolver that decodes records packed with
+// schema writer into records of schema reader. A field of writer that is not
+// in reader is unpacked and discarded. A field of reader that is not in
+// writer is assigned the value in defaults with its name or, if there is
+// none, the zero value of its kind. A field in both schemas must have a kind
+// in reader that correctly reads the kind in writer, such as uint64 for a
+// uint32 field. Default values are not copied, so a []byte default is shared
+// by all decoded records.
+func NewSchemaResolver(writer, reader *Schema, defaults map[string]interface{}) (*SchemaResolver, error) {
+	kinds := make(map[string]Kind, len(reader.fields))
+	for _, f := range reader.fields {
+		kinds[f.Name] = f.Kind
+	}
+	r := &SchemaResolver{fill: make(map[string]interface{})}
+	for _, f := range writer.fields {
+		kind, ok := kinds[f.Name]
+		if ok {
+			if !kindReads(f.Kind, kind) {
+				return nil, fmt.Errorf("field %s of kind %s cannot be read as %s", f.Name, f.Kind, kind)
+			}
+			delete(kinds, f.Name)
+			f.Kind = kind
+		}
+		r.steps = append(r.steps, resolveStep{field: f, keep: ok})
+	}
+	for _, f := range reader.fields {
+		if _, ok := kinds[f.Name]; !ok {
+			continue
+		}
+		val, ok := defaults[f.Name]
+		if ok {
+			var put PutBuffer
+			put.SetDryRun(true)
+			f.put(&put, val)
+			if put.err != nil {
+				return nil, fmt.Errorf("default for %w", put.err)
+			}
+		} else {
+			val = f.Kind.zero()
+		}
+		r.fill[f.Name] = val
+	}
+	for name := range defaults {
+		if _, ok := r.fill[name]; !ok {
+			return nil, fmt.Errorf("default for field %s, which is not added by reader schema", name)
+		}
+	}
+	return r, nil
+}
+
+// Decode unpacks a record packed with the writer's schema and returns its
+// values, keyed by field name, in the form of the reader's schema.
+func (r *SchemaResolver) Decode(data []byte) (map[string]interface{}, error) {
+	get := NewGetBuffer(data)
+	rec := make(map[string]interface{}, len(r.steps)+len(r.fill))
+	for _, st := range r.steps {
+		val := st.field.get(get)
+		if st.keep {
+			rec[st.field.Name] = val
+		}
+	}
+	if err := get.Done(); err != nil {
+		return nil, err
+	}
+	for name, val := range r.fill {
+		rec[name] = val
+	}
+	return rec, nil
+}
+
+// zero returns the zero value of the Go type that corresponds to kind k in a
+// decoded record.
+func (k Kind) zero() interface{} {
+	switch k {
+	case KindTime:
+		return time.Time{}
+	case KindUint64:
+		return uint64(0)
+	case KindInt64:
+		return int64(0)
+	case KindUint32:
+		return uint32(0)
+	case KindInt32:
+		return int32(0)
+	case KindUint16:
+		return uint16(0)
+	case KindInt16:
+		return int16(0)
+	case KindUint8:
+		return uint8(0)
+	case KindInt8:
+		return int8(0)
+	case KindStr:
+		return ""
+	}
+	return []byte(nil)
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleSchemaResolver() {
+	writer, _ := ParseSchema("id:uint32, legacy:str, name:str")
+	reader, _ := ParseSchema("id:uint64, name:str, region:str, score:int32")
+	r, err := NewSchemaResolver(writer, reader, map[string]interface{}{"region": "unknown"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var put PutBuffer
+	put.Uint32(42)
+	put.Str("obsolete")
+	put.Str("gear")
+	data, _ := put.Data()
+	rec, err := r.Decode(data)
+	fmt.Println(rec, err)
+	// Output:
+	// map[id:42 name:gear region:unknown score:0] <nil>
+}
+
+func TestNewSchemaResolver(t *testing.T) {
+	writer, _ := ParseSchema("id:uint32, name:str")
+	for _, c := range []struct {
+		reader   string
+		defaults map[string]interface{}
+	}{
+		{"id:uint16, name:str", nil},
+		{"id:uint32, name:int64", nil},
+		{"id:uint32, name:str, n:uint8", map[string]interface{}{"n": "x"}},
+		{"id:uint32, name:str", map[string]interface{}{"name": "x"}},
+		{"id:uint32, name:str", map[string]interface{}{"other": "x"}},
+	} {
+		reader, err := ParseSchema(c.reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewSchemaResolver(writer, reader, c.defaults); err == nil {
+			t.Fatalf("expecting error for %s", c.reader)
+		}
+	}
+	reader, _ := ParseSchema("id:uint64, name:bytes")
+	r, err := NewSchemaResolver(writer, reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := r.Decode([]byte{7, 1, 'a'})
+	if err != nil || rec["id"] != uint64(7) || string(rec["name"].([]byte)) != "a" {
+		t.Fatalf("unexpected result %v %v", rec, err)
+	}
+	if _, err = r.Decode([]byte{7, 1}); err == nil {
+		t.Fatal("expecting error for truncated record")
+	}
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+	"io"
+	"net/rpc"
+)
+
+// rpcCodec implements rpc.ClientCodec and rpc.ServerCodec. Each message is
+// one record of a record stream that holds the header fields followed by the
+// packed body.
+type rpcCodec struct {
+	c    io.Closer
+	rw   *RecordWriter
+	rr   *RecordReader
+	body []byte
+}
+
+// NewClientCodec returns an rpc.ClientCodec that uses the store format on
+// conn, for use with rpc.NewClientWithCodec. Request arguments must implement
+// Putter and replies must implement Getter, as do types processed by
+// storegen. The server must use NewServerCodec.
+func NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return newRPCCodec(conn)
+}
+
+// NewServerCodec returns an rpc.ServerCodec that uses the store format on
+// conn, for use with rpc.ServeCodec. Request arguments must implement Getter
+// and replies must implement Putter.
+func NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return newRPCCodec(conn)
+}
+
+func newRPCCodec(conn io.ReadWriteCloser) *rpcCodec {
+	return &rpcCodec{c: conn, rw: NewRecordWriter(conn), rr: NewRecordReader(conn)}
+}
+
+// write sends a message with the header fields packed by hdr and body.
+func (c *rpcCodec) write(hdr func(put *PutBuffer), body interface{}) error {
+	put := AcquirePut()
+	defer ReleasePut(put)
+	hdr(put)
+	var sl []byte
+	if body != nil {
+		p, ok := body.(Putter)
+		if !ok {
+			return fmt.Errorf("type %T does not implement store.Putter", body)
+		}
+		var err error
+		if sl, err = Marshal(p); err != nil {
+			return err
+		}
+	}
+	put.Bytes(sl)
+	return c.rw.Put(put)
+}
+
+// read receives a message, unpacking its header fields with hdr and
+// retaining its body for readBody.
+func (c *rpcCodec) read(hdr func(get *GetBuffer)) error {
+	if !c.rr.Next() {
+		if err := c.rr.Done(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	get := &GetBuffer{data: c.rr.Record()}
+	hdr(get)
+	get.Bytes(&c.body)
+	return get.Done()
+}
+
+// readBody unpacks the body of the message most recently received into
+// body. A nil body discards it.
+func (c *rpcCodec) readBody(body interface{}) error {
+	if body == nil {
+		return nil
+	}
+	g, ok := body.(Getter)
+	if !ok {
+		return fmt.Errorf("type %T does not implement store.Getter", body)
+	}
+	return Unmarshal(c.body, g)
+}
+
+func (c *rpcCodec) WriteRequest(req *rpc.Request, body interface{}) error {
+	return c.write(func(put *PutBuffer) {
+		put.Str(req.ServiceMethod)
+		put.Uint64(req.Seq)
+	}, body)
+}
+
+func (c *rpcCodec) ReadResponseHeader(resp *rpc.Response) error {
+	return c.read(func(get *GetBuffer) {
+		get.Str(&resp.ServiceMethod)
+		get.Uint64(&resp.Seq)
+		get.Str(&resp.Error)
+	})
+}
+
+func (c *rpcCodec) ReadResponseBody(body interface{}) error {
+	return c.readBody(body)
+}
+
+func (c *rpcCodec) ReadRequestHeader(req *rpc.Request) error {
+	return c.read(func(get *GetBuffer) {
+		get.Str(&req.ServiceMethod)
+		get.Uint64(&req.Seq)
+	})
+}
+
+func (c *rpcCodec) ReadRequestBody(body interface{}) error {
+	return c.readBody(body)
+}
+
+func (c *rpcCodec) WriteResponse(resp *rpc.Response, body interface{}) error {
+	if resp.Error != "" {
+		// The body of an error response is a placeholder
+		body = nil
+	}
+	return c.write(func(put *PutBuffer) {
+		put.Str(resp.ServiceMethod)
+		put.Uint64(resp.Seq)
+		put.Str(resp.Error)
+	}, body)
+}
+
+func (c *rpcCodec) Close() error {
+	return c.c.Close()
+}
+
+// GRPCCodec packs gRPC messages in the store format. It implements the
+// encoding.Codec interface of google.golang.org/grpc without depending on
+// that module, so it can be installed with encoding.RegisterCodec and
+// selected with the content subtype "store". Messages must implement Putter
+// and Getter.
+type GRPCCodec struct{}
+
+// Marshal returns the packed form of v, which must implement Putter.
+func (GRPCCodec) Marshal(v interface{}) ([]byte, error) {
+	p, ok := v.(Putter)
+	if !ok {
+		return nil, fmt.Errorf("type %T does not implement store.Putter", v)
+	}
+	return Marshal(p)
+}
+
+// Unmarshal unpacks data into v, which must implement Getter.
+func (GRPCCodec) Unmarshal(data []byte, v interface{}) error {
+	g, ok := v.(Getter)
+	if !ok {
+		return fmt.Errorf("type %T does not implement store.Getter", v)
+	}
+	return Unmarshal(data, g)
+}
+
+// Name returns the name of the codec, "store".
+func (GRPCCodec) Name() string {
+	return "store"
+}
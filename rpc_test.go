@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"testing"
+)
+
+// RPCPair is the argument of the RPCArith service.
+type RPCPair struct {
+	A, B int64
+}
+
+func (p *RPCPair) StorePut(put *PutBuffer) {
+	put.Int64(p.A)
+	put.Int64(p.B)
+}
+
+func (p *RPCPair) StoreGet(get *GetBuffer) {
+	get.Int64(&p.A)
+	get.Int64(&p.B)
+}
+
+// RPCResult is the reply of the RPCArith service.
+type RPCResult struct {
+	N int64
+}
+
+func (r *RPCResult) StorePut(put *PutBuffer) {
+	put.Int64(r.N)
+}
+
+func (r *RPCResult) StoreGet(get *GetBuffer) {
+	get.Int64(&r.N)
+}
+
+// RPCArith is a net/rpc service used to test the store codecs.
+type RPCArith struct{}
+
+func (RPCArith) Add(p *RPCPair, r *RPCResult) error {
+	r.N = p.A + p.B
+	return nil
+}
+
+func (RPCArith) Div(p *RPCPair, r *RPCResult) error {
+	if p.B == 0 {
+		return errors.New("divide by zero")
+	}
+	r.N = p.A / p.B
+	return nil
+}
+
+func TestNewClientCodec(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := srv.Register(RPCArith{}); err != nil {
+		t.Fatal(err)
+	}
+	cc, sc := net.Pipe()
+	go srv.ServeCodec(NewServerCodec(sc))
+	client := rpc.NewClientWithCodec(NewClientCodec(cc))
+	defer client.Close()
+	var r RPCResult
+	if err := client.Call("RPCArith.Add", &RPCPair{-7, 12}, &r); err != nil || r.N != 5 {
+		t.Fatalf("unexpected result %d: %v", r.N, err)
+	}
+	err := client.Call("RPCArith.Div", &RPCPair{1, 0}, &r)
+	if err == nil || err.Error() != "divide by zero" {
+		t.Fatalf("expecting server error, got %v", err)
+	}
+	if err = client.Call("RPCArith.Nope", &RPCPair{}, &r); err == nil {
+		t.Fatal("expecting error for unknown method")
+	}
+	if err = client.Call("RPCArith.Add", RPCPair{}, &r); err == nil {
+		t.Fatal("expecting error for argument that is not a Putter")
+	}
+	if err = client.Call("RPCArith.Div", &RPCPair{100, 7}, &r); err != nil || r.N != 14 {
+		t.Fatalf("unexpected result %d: %v", r.N, err)
+	}
+}
+
+func TestGRPCCodec(t *testing.T) {
+	var c GRPCCodec
+	data, err := c.Marshal(&RPCPair{3, -4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p RPCPair
+	if err = c.Unmarshal(data, &p); err != nil || p != (RPCPair{3, -4}) || c.Name() != "store" {
+		t.Fatalf("unexpected result %v: %v", p, err)
+	}
+	if _, err = c.Marshal(p); err == nil {
+		t.Fatal("expecting error for value that is not a Putter")
+	}
+	if err = c.Unmarshal(data, p); err == nil {
+		t.Fatal("expecting error for value that is not a Getter")
+	}
+}
@@ -0,0 +1,434 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// Field describes one value of a record described by a Schema. Records use
+// the variable-length forms packed by PutBuffer, so KindStr and KindBytes
+// denote length-prefixed values and no width applies.
+type Field struct {
+	Name string
+	Kind Kind
+}
+
+// Schema describes the ordered sequence of values packed into a record. It
+// permits records to be decoded, inspected and rebuilt without the Go types
+// and put and get calls that produced them, for example by operations tools
+// that examine or repair stored data.
+type Schema struct {
+	fields []Field
+}
+
+// NewSchema returns a schema made up of the specified fields in order. Field
+// names should be unique, since decoded records are keyed by name.
+func NewSchema(fields ...Field) *Schema {
+	return &Schema{fields: append([]Field(nil), fields...)}
+}
+
+// ParseSchema returns the schema described by text, which holds a sequence of
+// fields in the form name:kind separated by white space or commas, as in
+// "id:uint64, name:str, created:time". Kinds are named as by Kind.String.
+// This is the form produced by Schema.String.
+func ParseSchema(text string) (*Schema, error) {
+	var fields []Field
+	seen := map[string]bool{}
+	for _, item := range strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	}) {
+		pos := strings.IndexByte(item, ':')
+		if pos < 1 {
+			return nil, fmt.Errorf("schema field %q is not in the form name:kind", item)
+		}
+		f := Field{Name: item[:pos], Kind: kindByName(item[pos+1:])}
+		if !f.Kind.record() {
+			return nil, fmt.Errorf("schema field %s has unsupported kind %q", f.Name, item[pos+1:])
+		}
+		if seen[f.Name] {
+			return nil, fmt.Errorf("schema field %s is declared more than once", f.Name)
+		}
+		seen[f.Name] = true
+		fields = append(fields, f)
+	}
+	return &Schema{fields: fields}, nil
+}
+
+// kindByName returns the kind with the specified name, or zero if there is
+// none.
+func kindByName(name string) Kind {
+	for k, str := range kindNames {
+		if str == name {
+			return k
+		}
+	}
+	return 0
+}
+
+// record reports whether k can describe a value in a record.
+func (k Kind) record() bool {
+	return k >= KindTime && k <= KindBytes
+}
+
+// Fields returns a copy of the fields that make up the receiving schema.
+func (s *Schema) Fields() []Field {
+	return append([]Field(nil), s.fields...)
+}
+
+// String returns the text form of the receiving schema, which can be parsed
+// by ParseSchema.
+func (s *Schema) String() string {
+	list := make([]string, len(s.fields))
+	for j, f := range s.fields {
+		list[j] = f.Name + ":" + f.Kind.String()
+	}
+	return strings.Join(list, ", ")
+}
+
+// put packs val into put according to field f.
+func (f Field) put(put *PutBuffer, val interface{}) {
+	ok := true
+	switch f.Kind {
+	case KindTime:
+		var v time.Time
+		if v, ok = val.(time.Time); ok {
+			put.Time(v)
+		}
+	case KindUint64:
+		var v uint64
+		if v, ok = val.(uint64); ok {
+			put.Uint64(v)
+		}
+	case KindInt64:
+		var v int64
+		if v, ok = val.(int64); ok {
+			put.Int64(v)
+		}
+	case KindUint32:
+		var v uint32
+		if v, ok = val.(uint32); ok {
+			put.Uint32(v)
+		}
+	case KindInt32:
+		var v int32
+		if v, ok = val.(int32); ok {
+			put.Int32(v)
+		}
+	case KindUint16:
+		var v uint16
+		if v, ok = val.(uint16); ok {
+			put.Uint16(v)
+		}
+	case KindInt16:
+		var v int16
+		if v, ok = val.(int16); ok {
+			put.Int16(v)
+		}
+	case KindUint8:
+		var v uint8
+		if v, ok = val.(uint8); ok {
+			put.Uint8(v)
+		}
+	case KindInt8:
+		var v int8
+		if v, ok = val.(int8); ok {
+			put.Int8(v)
+		}
+	case KindStr:
+		var v string
+		if v, ok = val.(string); ok {
+			put.Str(v)
+		}
+	case KindBytes:
+		var v []byte
+		if v, ok = val.([]byte); ok {
+			put.Bytes(v)
+		}
+	default:
+		put.SetError(fmt.Errorf("field %s has unsupported kind %s", f.Name, f.Kind))
+		return
+	}
+	if !ok {
+		put.SetError(fmt.Errorf("field %s: expecting %s value, got %T", f.Name, f.Kind, val))
+	}
+}
+
+// get unpacks a value from get according to field f.
+func (f Field) get(get *GetBuffer) (val interface{}) {
+	get.Field(f.Name)
+	switch f.Kind {
+	case KindTime:
+		var v time.Time
+		get.Time(&v)
+		val = v
+	case KindUint64:
+		var v uint64
+		get.Uint64(&v)
+		val = v
+	case KindInt64:
+		var v int64
+		get.Int64(&v)
+		val = v
+	case KindUint32:
+		var v uint32
+		get.Uint32(&v)
+		val = v
+	case KindInt32:
+		var v int32
+		get.Int32(&v)
+		val = v
+	case KindUint16:
+		var v uint16
+		get.Uint16(&v)
+		val = v
+	case KindInt16:
+		var v int16
+		get.Int16(&v)
+		val = v
+	case KindUint8:
+		var v uint8
+		get.Uint8(&v)
+		val = v
+	case KindInt8:
+		var v int8
+		get.Int8(&v)
+		val = v
+	case KindStr:
+		var v string
+		get.Str(&v)
+		val = v
+	case KindBytes:
+		var v []byte
+		get.Bytes(&v)
+		val = v
+	default:
+		if get.err == nil {
+			get.err = fmt.Errorf("field %s has unsupported kind %s", f.Name, f.Kind)
+		}
+	}
+	return
+}
+
+// Decode unpacks a record that conforms to the receiving schema and returns
+// its values keyed by field name. The values have the Go types that
+// correspond to their field kinds, as documented for KeySchema.Encode.
+func (s *Schema) Decode(data []byte) (map[string]interface{}, error) {
+	get := NewGetBuffer(data)
+	rec := make(map[string]interface{}, len(s.fields))
+	for _, f := range s.fields {
+		rec[f.Name] = f.get(get)
+	}
+	if err := get.Done(); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Encode packs the values of rec, keyed by field name, in the order of the
+// receiving schema. rec must hold a value of the appropriate Go type for each
+// field and no other entries.
+func (s *Schema) Encode(rec map[string]interface{}) ([]byte, error) {
+	var put PutBuffer
+	if len(rec) != len(s.fields) {
+		put.SetError(errFieldCount)
+	}
+	for _, f := range s.fields {
+		if put.err != nil {
+			break
+		}
+		val, ok := rec[f.Name]
+		if !ok {
+			put.SetError(fmt.Errorf("record has no value for field %s", f.Name))
+			break
+		}
+		f.put(&put, val)
+	}
+	return put.Data()
+}
+
+// DumpString returns a human-readable rendering of a record that conforms to
+// the receiving schema. Each field occupies one line that shows its byte
+// offset within data, name, kind and value. If the record does not conform,
+// the fields that could be decoded are followed by the error and the
+// remaining bytes in hexadecimal form.
+func (s *Schema) DumpString(data []byte) string {
+	var b bytes.Buffer
+	get := NewGetBuffer(data)
+	width := 0
+	for _, f := range s.fields {
+		if len(f.Name) > width {
+			width = len(f.Name)
+		}
+	}
+	for _, f := range s.fields {
+		pos := get.Pos()
+		val := f.get(get)
+		if get.err != nil {
+			break
+		}
+		fmt.Fprintf(&b, "%6d  %-*s  %-6s  %s\n", pos, width, f.Name, f.Kind, renderValue(val))
+	}
+	if err := get.Done(); err != nil {
+		pos := get.Pos()
+		var de *DecodeError
+		if errors.As(err, &de) {
+			pos = de.Offset
+		}
+		fmt.Fprintf(&b, "%6d  error: %s\n", pos, err)
+		if pos < int64(len(data)) {
+			fmt.Fprintf(&b, "%6d  % x\n", pos, data[pos:])
+		}
+	}
+	return b.String()
+}
+
+// Compatibility classifies the change from one version of a schema to the
+// next. See CheckCompatibility.
+type Compatibility uint8
+
+// The following constants are returned by CheckCompatibility, ordered from
+// the safest change to the least safe.
+const (
+	// CompatIdentical indicates that both schemas have the same kinds in the
+	// same order. Field names, which are not stored, may differ.
+	CompatIdentical Compatibility = iota
+	// CompatRead indicates that records packed with the old schema are read
+	// correctly with the new one because every change only widens a field
+	// to a kind with the same encoding, for example uint16 to uint32 or str
+	// to bytes. Readers that still use the old schema may not be able to
+	// read new records.
+	CompatRead
+	// CompatAppend indicates that the new schema adds fields after the
+	// fields of the old schema, which are otherwise read-compatible. Readers
+	// with the old schema need GetBuffer.SetAllowTrailing to read new
+	// records, and readers with the new schema must treat the added fields
+	// as optional when reading old records.
+	CompatAppend
+	// CompatBreaking indicates that records packed with one schema cannot be
+	// read with the other, for example because a field was removed,
+	// reordered, narrowed or changed to a different encoding.
+	CompatBreaking
+)
+
+var compatNames = [...]string{"identical", "read-compatible", "append-only", "breaking"}
+
+// String implements the fmt.Stringer interface.
+func (c Compatibility) String() string {
+	if int(c) < len(compatNames) {
+		return compatNames[c]
+	}
+	return fmt.Sprintf("compatibility(%d)", uint8(c))
+}
+
+// kindWidens maps each kind to the kinds that read its encoding correctly.
+var kindWidens = map[Kind][]Kind{
+	KindUint16: {KindUint32, KindUint64},
+	KindUint32: {KindUint64},
+	KindInt16:  {KindInt32, KindInt64},
+	KindInt32:  {KindInt64},
+	KindInt64:  {KindTime},
+	KindTime:   {KindInt64},
+	KindStr:    {KindBytes},
+	KindBytes:  {KindStr},
+}
+
+// kindReads returns true if a field of kind to correctly reads a value packed
+// as kind from.
+func kindReads(from, to Kind) bool {
+	if from == to {
+		return true
+	}
+	for _, k := range kindWidens[from] {
+		if k == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckCompatibility compares the schema of existing records, old, with a
+// proposed replacement, new, and returns the classification of the change
+// along with a description of each difference. Since records are packed
+// positionally, fields are compared by position; a renamed field is noted
+// but does not affect the classification. This permits a schema change to be
+// checked, for example in a test, before it silently alters the wire format.
+func CheckCompatibility(old, new *Schema) (c Compatibility, notes []string) {
+	raise := func(to Compatibility, format string, args ...interface{}) {
+		if to > c {
+			c = to
+		}
+		notes = append(notes, fmt.Sprintf(format, args...))
+	}
+	for j, f := range old.fields {
+		if j >= len(new.fields) {
+			raise(CompatBreaking, "field %d (%s) removed", j+1, f.Name)
+			continue
+		}
+		nf := new.fields[j]
+		if nf.Name != f.Name {
+			raise(CompatIdentical, "field %d renamed from %s to %s", j+1, f.Name, nf.Name)
+		}
+		if nf.Kind != f.Kind {
+			to := CompatBreaking
+			if kindReads(f.Kind, nf.Kind) {
+				to = CompatRead
+			}
+			raise(to, "field %d (%s) changed from %s to %s", j+1, nf.Name, f.Kind, nf.Kind)
+		}
+	}
+	for j := len(old.fields); j < len(new.fields); j++ {
+		raise(CompatAppend, "field %d (%s) appended", j+1, new.fields[j].Name)
+	}
+	return
+}
+
+// Fingerprint returns a short hash of the sequence of kinds in the receiving
+// schema. Field names do not contribute, since they do not affect the packed
+// form of a record.
+func (s *Schema) Fingerprint() uint32 {
+	h := fnv.New32a()
+	for _, f := range s.fields {
+		h.Write([]byte{byte(f.Kind)})
+	}
+	return h.Sum32()
+}
+
+// Fingerprint packs the fingerprint of schema s into the receiving storage
+// buffer. Placed ahead of the fields of a record, it permits a reader to
+// detect a record written with a different schema before any fields are
+// unpacked.
+func (put *PutBuffer) Fingerprint(s *Schema) {
+	put.Uint32Fixed(s.Fingerprint())
+}
+
+// Fingerprint unpacks a schema fingerprint that was packed with
+// PutBuffer.Fingerprint and compares it with that of s. If they differ, the
+// internal error is set to one that matches ErrSchemaMismatch.
+func (get *GetBuffer) Fingerprint(s *Schema) {
+	var val uint32
+	get.Uint32Fixed(&val)
+	if get.err == nil && val != s.Fingerprint() {
+		get.err = categoryErrorf(ErrSchemaMismatch, "record fingerprint %08x does not match schema fingerprint %08x",
+			val, s.Fingerprint())
+	}
+}
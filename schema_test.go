@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// ExampleSchema demonstrates the inspection and repair of a record without
+// the Go type that produced it.
+func ExampleSchema() {
+	var put PutBuffer
+	put.Uint32(42)
+	put.Str("widget")
+	put.Time(time.Unix(1500000000, 0))
+	data, err := put.Data()
+	if err == nil {
+		var s *Schema
+		s, err = ParseSchema("id:uint32, name:str, updated:time")
+		if err == nil {
+			var rec map[string]interface{}
+			rec, err = s.Decode(data)
+			if err == nil {
+				fmt.Println(s, "|", rec["id"], rec["name"])
+				rec["name"] = "gadget"
+				data, err = s.Encode(rec)
+				if err == nil {
+					fmt.Printf("% x\n", data)
+				}
+			}
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// id:uint32, name:str, updated:time | 42 widget
+	// 2a 06 67 61 64 67 65 74 80 bc c1 96 0b
+}
+
+// Ensure that schema errors are reported
+func TestSchema_Decode(t *testing.T) {
+	for _, text := range []string{"a", "a:float", "a:uint8 a:int8", ":str"} {
+		if _, err := ParseSchema(text); err == nil {
+			t.Fatalf("invalid schema %q not reported", text)
+		}
+	}
+	s := NewSchema(Field{"a", KindUint8}, Field{"b", KindBytes})
+	if _, err := s.Encode(map[string]interface{}{"a": uint8(1)}); err != errFieldCount {
+		t.Fatalf("missing field not reported: %v", err)
+	}
+	if _, err := s.Encode(map[string]interface{}{"a": 1, "b": []byte{}}); err == nil {
+		t.Fatal("mismatched type not reported")
+	}
+	if _, err := s.Encode(map[string]interface{}{"a": uint8(1), "c": []byte{}}); err == nil {
+		t.Fatal("unknown field not reported")
+	}
+	_, err := s.Decode([]byte{1, 5, 0})
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Field != "b" {
+		t.Fatalf("expecting decode error for field b, got %v", err)
+	}
+}
+
+// ExampleSchema_DumpString demonstrates the rendering of a record, including
+// one that does not conform to the schema.
+func ExampleSchema_DumpString() {
+	s := NewSchema(Field{"id", KindUint32}, Field{"name", KindStr}, Field{"blob", KindBytes})
+	var put PutBuffer
+	put.Uint32(300)
+	put.Str("widget")
+	put.Bytes([]byte{1, 2})
+	data, err := put.Data()
+	if err == nil {
+		fmt.Print(s.DumpString(data))
+		fmt.Print(s.DumpString(data[:9]))
+		fmt.Print(s.DumpString(append(data, 7)))
+	} else {
+		fmt.Println(err)
+	}
+	// Output:
+	//      0  id    uint32  300
+	//      2  name  str     "widget"
+	//      9  blob  bytes   0102
+	//      0  id    uint32  300
+	//      2  name  str     "widget"
+	//      9  error: unpacking value 2 (blob) at offset 9: EOF
+	//      0  id    uint32  300
+	//      2  name  str     "widget"
+	//      9  blob  bytes   0102
+	//     12  error: the get buffer has not been completely emptied
+	//     12  07
+}
+
+// Ensure that schema changes are classified
+func TestCheckCompatibility(t *testing.T) {
+	base := "id:uint32, name:str, at:time"
+	for _, c := range []struct {
+		text  string
+		want  Compatibility
+		notes int
+	}{
+		{"id:uint32, name:str, at:time", CompatIdentical, 0},
+		{"key:uint32, name:str, at:time", CompatIdentical, 1},
+		{"id:uint64, name:bytes, at:int64", CompatRead, 3},
+		{"id:uint32, name:str, at:time, n:uint8", CompatAppend, 1},
+		{"id:uint64, name:str, at:time, n:uint8", CompatAppend, 2},
+		{"id:uint16, name:str, at:time", CompatBreaking, 1},
+		{"id:uint32, name:str", CompatBreaking, 1},
+		{"id:int32, name:str, at:time", CompatBreaking, 1},
+		{"name:str, id:uint32, at:time", CompatBreaking, 4},
+	} {
+		old, err := ParseSchema(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := ParseSchema(c.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, notes := CheckCompatibility(old, s)
+		if got != c.want || len(notes) != c.notes {
+			t.Fatalf("%s: expecting %s with %d notes, got %s %q", c.text, c.want, c.notes, got, notes)
+		}
+	}
+	if CompatAppend.String() != "append-only" || Compatibility(9).String() != "compatibility(9)" {
+		t.Fatal("unexpected compatibility names")
+	}
+}
+
+func TestGetBuffer_Fingerprint(t *testing.T) {
+	writer, _ := ParseSchema("id:uint32, name:str")
+	renamed, _ := ParseSchema("key:uint32, label:str")
+	reader, _ := ParseSchema("id:uint64, name:str")
+	if writer.Fingerprint() != renamed.Fingerprint() {
+		t.Fatal("field names should not affect fingerprint")
+	}
+	var put PutBuffer
+	put.Fingerprint(writer)
+	put.Uint32(42)
+	put.Str("pinion")
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id uint32
+	var name string
+	get := NewGetBuffer(data)
+	get.Fingerprint(renamed)
+	get.Uint32(&id)
+	get.Str(&name)
+	err = get.Done()
+	if err != nil || id != 42 || name != "pinion" {
+		t.Fatalf("unexpected result %d %q %v", id, name, err)
+	}
+	get = NewGetBuffer(data)
+	get.Fingerprint(reader)
+	err = get.Done()
+	if !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expecting schema mismatch, got %v", err)
+	}
+	get = NewGetBuffer(data[:len(data)-1])
+	get.Fingerprint(writer)
+	get.Uint32(&id)
+	get.Str(&name)
+	var de *DecodeError
+	if err = get.Done(); !errors.As(err, &de) || de.Index != 2 {
+		t.Fatalf("expecting failure at value 2, got %v", err)
+	}
+}
@@ -0,0 +1,396 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+// segmentMagic ends every segment file.
+const segmentMagic = 0x70736731 // "psg1"
+
+// segmentFooterLen is the length of the fixed-size footer of a segment file:
+// the offset and length of the block index and the magic number.
+const segmentFooterLen = 8 + 8 + 4
+
+// SegmentBlockSize is the default target length of a segment data block.
+const SegmentBlockSize = 4096
+
+// segmentRestartInterval is the number of pairs in a data block between
+// restart points, at which keys are stored in full.
+const segmentRestartInterval = 16
+
+var (
+	errSegmentOrder    = errors.New("segment keys must be added in strictly ascending order")
+	errSegmentClosed   = errors.New("segment writer is closed")
+	errSegmentMagic    = errors.New("not a segment file")
+	errSegmentChecksum = &categoryError{"segment block checksum does not match content", ErrChecksum}
+	errSegmentRestart  = &categoryError{"segment block has invalid restart points", ErrValueRange}
+)
+
+// segmentBlock is an entry of a segment's block index.
+type segmentBlock struct {
+	last []byte // last key of block
+	off  uint64
+	size uint64
+	sum  uint32
+}
+
+// SegmentWriter writes an immutable segment file that holds key/value pairs
+// sorted by key, for example a snapshot of a table whose keys were built with
+// a KeyBuffer. Pairs are grouped into data blocks, and a block index that
+// holds the last key of each block is written at the end of the file,
+// followed by a fixed-size footer. Use OpenSegment to read the file.
+//
+// Within a block, each key is stored as the length of the prefix it shares
+// with the preceding key followed by the remaining suffix, in the manner of
+// KeyStreamWriter. Keys built with a KeyBuffer tend to share long leading
+// segments, so this substantially reduces the size of the file. Every 16th
+// key is stored in full; the offsets of these restart points, which follow
+// the pairs of the block, permit a lookup to locate a key with a binary
+// search rather than decoding the block from its start.
+type SegmentWriter struct {
+	w         io.Writer
+	blockSize int
+	off       uint64
+	put       PutBuffer
+	prev      []byte
+	hasPrev   bool
+	restarts  []uint32
+	count     int
+	index     []segmentBlock
+	closed    bool
+	err       error
+}
+
+// NewSegmentWriter returns a segment writer that writes to w. Data blocks are
+// completed once they reach blockSize bytes; if blockSize is not positive,
+// SegmentBlockSize is used.
+func NewSegmentWriter(w io.Writer, blockSize int) *SegmentWriter {
+	if blockSize <= 0 {
+		blockSize = SegmentBlockSize
+	}
+	return &SegmentWriter{w: w, blockSize: blockSize}
+}
+
+// Add appends a key/value pair to the segment. Each key must sort after the
+// key of the preceding pair. Once an error has occurred, no further pairs are
+// added and the error is returned by all subsequent calls.
+func (sw *SegmentWriter) Add(key, val []byte) error {
+	switch {
+	case sw.err != nil:
+	case sw.closed:
+		sw.err = errSegmentClosed
+	case sw.hasPrev && bytes.Compare(key, sw.prev) <= 0:
+		sw.err = errSegmentOrder
+	default:
+		n := 0
+		if sw.count%segmentRestartInterval == 0 {
+			sw.restarts = append(sw.restarts, uint32(sw.put.Len()))
+		} else {
+			n = sharedLen(sw.prev, key)
+		}
+		sw.put.Uint64(uint64(n))
+		sw.put.Bytes(key[n:])
+		sw.put.Bytes(val)
+		sw.prev = append(sw.prev[:0], key...)
+		sw.hasPrev = true
+		sw.count++
+		if sw.put.Len()+4*len(sw.restarts)+4 >= sw.blockSize {
+			sw.flush()
+		}
+	}
+	return sw.err
+}
+
+// flush writes the current data block, if it is not empty, and records it
+// in the block index.
+func (sw *SegmentWriter) flush() {
+	if sw.err == nil && sw.count > 0 {
+		for _, off := range sw.restarts {
+			sw.put.Uint32Fixed(off)
+		}
+		sw.put.Uint32Fixed(uint32(len(sw.restarts)))
+		var block []byte
+		if block, sw.err = sw.put.DataUnsafe(); sw.err == nil {
+			if _, sw.err = sw.w.Write(block); sw.err == nil {
+				sw.index = append(sw.index, segmentBlock{
+					last: append([]byte(nil), sw.prev...),
+					off:  sw.off,
+					size: uint64(len(block)),
+					sum:  crc32.Checksum(block, logTable),
+				})
+				sw.off += uint64(len(block))
+				sw.put.Reset()
+				sw.restarts = sw.restarts[:0]
+				sw.count = 0
+			}
+		}
+	}
+}
+
+// Close completes the segment by writing the final data block, the block
+// index and the footer. It does not close the underlying writer. The error
+// that has occurred while writing the segment, if any, is returned.
+func (sw *SegmentWriter) Close() error {
+	if sw.err != nil || sw.closed {
+		return sw.err
+	}
+	sw.flush()
+	sw.closed = true
+	if sw.err == nil {
+		var put PutBuffer
+		put.Uint64(uint64(len(sw.index)))
+		for _, blk := range sw.index {
+			put.Bytes(blk.last)
+			put.Uint64(blk.off)
+			put.Uint64(blk.size)
+			put.Uint32Fixed(blk.sum)
+		}
+		size := put.Len()
+		put.Uint64Fixed(sw.off)
+		put.Uint64Fixed(uint64(size))
+		put.Uint32Fixed(segmentMagic)
+		var data []byte
+		if data, sw.err = put.DataUnsafe(); sw.err == nil {
+			_, sw.err = sw.w.Write(data)
+		}
+	}
+	return sw.err
+}
+
+// Segment provides point lookups and range scans over a segment file that
+// was written by a SegmentWriter. The block index is held in memory; data
+// blocks are read as needed. A Segment may be used concurrently if its
+// underlying io.ReaderAt permits it, as os.File does.
+type Segment struct {
+	r     io.ReaderAt
+	index []segmentBlock
+}
+
+// OpenSegment reads the block index of the segment file of the specified
+// size that is accessed through r.
+func OpenSegment(r io.ReaderAt, size int64) (*Segment, error) {
+	if size < segmentFooterLen {
+		return nil, errSegmentMagic
+	}
+	var footer [segmentFooterLen]byte
+	if _, err := r.ReadAt(footer[:], size-segmentFooterLen); err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint32(footer[16:]) != segmentMagic {
+		return nil, errSegmentMagic
+	}
+	off := binary.BigEndian.Uint64(footer[:])
+	n := binary.BigEndian.Uint64(footer[8:])
+	if off > uint64(size)-segmentFooterLen || n != uint64(size)-segmentFooterLen-off {
+		return nil, categoryErrorf(ErrValueRange, "segment footer is inconsistent with file size")
+	}
+	buf := make([]byte, n)
+	if _, err := r.ReadAt(buf, int64(off)); err != nil {
+		return nil, err
+	}
+	get := &GetBuffer{data: buf}
+	var count uint64
+	get.Uint64(&count)
+	if get.err == nil && count > n {
+		get.err = categoryErrorf(ErrValueRange, "segment index holds %d blocks in %d bytes", count, n)
+	}
+	seg := &Segment{r: r}
+	var end uint64
+	for j := uint64(0); j < count && get.err == nil; j++ {
+		var blk segmentBlock
+		get.Bytes(&blk.last)
+		get.Uint64(&blk.off)
+		get.Uint64(&blk.size)
+		get.Uint32Fixed(&blk.sum)
+		if get.err == nil && (blk.off != end || blk.size > off-end) {
+			get.err = categoryErrorf(ErrValueRange, "segment block %d has invalid extent", j)
+		}
+		end += blk.size
+		seg.index = append(seg.index, blk)
+	}
+	if err := get.Done(); err != nil {
+		return nil, err
+	}
+	if end != off {
+		return nil, categoryErrorf(ErrValueRange, "segment blocks end at offset %d rather than at index offset %d", end, off)
+	}
+	return seg, nil
+}
+
+// block returns the pairs of data block j and the offsets of its restart
+// points.
+func (seg *Segment) block(j int) (data []byte, restarts []uint32, err error) {
+	blk := seg.index[j]
+	data = make([]byte, blk.size)
+	if _, err = seg.r.ReadAt(data, int64(blk.off)); err != nil {
+		return nil, nil, err
+	}
+	if crc32.Checksum(data, logTable) != blk.sum {
+		return nil, nil, errSegmentChecksum
+	}
+	if len(data) < 4 {
+		return nil, nil, errSegmentRestart
+	}
+	n := binary.BigEndian.Uint32(data[len(data)-4:])
+	if n == 0 || uint64(n) > uint64(len(data)-4)/4 {
+		return nil, nil, errSegmentRestart
+	}
+	end := len(data) - 4 - 4*int(n)
+	restarts = make([]uint32, n)
+	for k := range restarts {
+		restarts[k] = binary.BigEndian.Uint32(data[end+4*k:])
+		if restarts[k] >= uint32(end) || k > 0 && restarts[k] <= restarts[k-1] {
+			return nil, nil, errSegmentRestart
+		}
+	}
+	return data[:end], restarts, nil
+}
+
+// search returns the index of the first block that may hold key.
+func (seg *Segment) search(key []byte) int {
+	return sort.Search(len(seg.index), func(j int) bool {
+		return bytes.Compare(seg.index[j].last, key) >= 0
+	})
+}
+
+// Get returns the value associated with key. The second return value is
+// false if the segment holds no such key.
+func (seg *Segment) Get(key []byte) (val []byte, ok bool, err error) {
+	it := seg.Range(key, nil)
+	if it.Next() && bytes.Equal(it.Key(), key) {
+		return it.Value(), true, nil
+	}
+	return nil, false, it.Done()
+}
+
+// Range returns an iterator over the pairs of the segment with keys that sort
+// at or after start and before end. A nil start begins with the first pair
+// and a nil end continues through the last.
+func (seg *Segment) Range(start, end []byte) *SegmentIterator {
+	return &SegmentIterator{seg: seg, blk: seg.search(start), start: start, end: end, seek: start != nil}
+}
+
+// SegmentIterator iterates over a range of the pairs held by a segment.
+type SegmentIterator struct {
+	seg        *Segment
+	blk        int
+	get        *GetBuffer
+	start, end []byte
+	seek       bool
+	key, val   []byte
+	err        error
+}
+
+// restartKey returns the key stored in full at offset off of data.
+func restartKey(data []byte, off uint32) (key []byte, err error) {
+	var n uint64
+	get := &GetBuffer{data: data, pos: int(off)}
+	get.Uint64(&n)
+	get.Bytes(&key)
+	if err = get.context(); err == nil && n != 0 {
+		err = errSegmentRestart
+	}
+	return
+}
+
+// seekBlock positions the iterator at the last restart point of data with a
+// key that sorts before the start of the iterator's range.
+func (it *SegmentIterator) seekBlock(data []byte, restarts []uint32) {
+	k := sort.Search(len(restarts), func(k int) bool {
+		key, err := restartKey(data, restarts[k])
+		if err != nil && it.err == nil {
+			it.err = err
+		}
+		return err != nil || bytes.Compare(key, it.start) >= 0
+	})
+	if k > 0 {
+		it.get.pos = int(restarts[k-1])
+	}
+}
+
+// Next advances the iterator to the next pair in its range. It returns false
+// when no pairs remain or an error has occurred; call Done to distinguish
+// between these cases.
+func (it *SegmentIterator) Next() bool {
+	for it.err == nil {
+		if it.get == nil || !it.get.more() {
+			if it.blk >= len(it.seg.index) {
+				return false
+			}
+			data, restarts, err := it.seg.block(it.blk)
+			if err != nil {
+				it.err = err
+				return false
+			}
+			it.get = &GetBuffer{data: data}
+			it.key = nil
+			if it.seek {
+				it.seek = false
+				if it.seekBlock(data, restarts); it.err != nil {
+					return false
+				}
+			}
+			it.blk++
+		}
+		var n uint64
+		var suffix []byte
+		it.get.Uint64(&n)
+		it.get.Bytes(&suffix)
+		it.get.Bytes(&it.val)
+		if it.err = it.get.context(); it.err != nil {
+			return false
+		}
+		if n > uint64(len(it.key)) {
+			it.err = errSharedPrefix
+			return false
+		}
+		it.key = append(it.key[:n:n], suffix...)
+		if it.end != nil && bytes.Compare(it.key, it.end) >= 0 {
+			it.blk = len(it.seg.index)
+			it.get = nil
+			return false
+		}
+		if bytes.Compare(it.key, it.start) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Key returns the key of the pair at the current position of the iterator.
+func (it *SegmentIterator) Key() []byte {
+	return it.key
+}
+
+// Value returns the value of the pair at the current position of the
+// iterator.
+func (it *SegmentIterator) Value() []byte {
+	return it.val
+}
+
+// Done returns the error that stopped the iterator, or nil if the range was
+// completely traversed.
+func (it *SegmentIterator) Done() error {
+	return it.err
+}
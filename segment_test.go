@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func ExampleSegment() {
+	var b bytes.Buffer
+	sw := NewSegmentWriter(&b, 32)
+	for j := uint32(1); j <= 20; j++ {
+		var kb KeyBuffer
+		kb.Uint32(j * 10)
+		key, _ := kb.Data()
+		sw.Add(key, []byte(fmt.Sprintf("value %d", j*10)))
+	}
+	if err := sw.Close(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	seg, err := OpenSegment(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var start, end KeyBuffer
+	start.Uint32(45)
+	end.Uint32(80)
+	startKey, _ := start.Data()
+	endKey, _ := end.Data()
+	it := seg.Range(startKey, endKey)
+	for it.Next() {
+		fmt.Println(string(it.Value()))
+	}
+	fmt.Println(it.Done())
+	// Output:
+	// value 50
+	// value 60
+	// value 70
+	// <nil>
+}
+
+func TestSegment(t *testing.T) {
+	var b bytes.Buffer
+	sw := NewSegmentWriter(&b, 64)
+	var keys [][]byte
+	for j := 0; j < 200; j++ {
+		key := []byte(fmt.Sprintf("key%04d", j*2))
+		keys = append(keys, key)
+		if err := sw.Add(key, bytes.Repeat([]byte{byte(j)}, j%7)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sw.Add([]byte("key0000"), nil) != errSegmentOrder {
+		t.Fatal("out of order key not reported")
+	}
+	if sw.Close() != errSegmentOrder {
+		t.Fatal("writer error not latched")
+	}
+	b.Reset()
+	sw = NewSegmentWriter(&b, 64)
+	for j, key := range keys {
+		sw.Add(key, bytes.Repeat([]byte{byte(j)}, j%7))
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if sw.Add([]byte("zzz"), nil) != errSegmentClosed {
+		t.Fatal("closed writer accepted pair")
+	}
+	data := b.Bytes()
+	seg, err := OpenSegment(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seg.index) < 10 {
+		t.Fatalf("expecting multiple blocks, got %d", len(seg.index))
+	}
+	for j, key := range keys {
+		val, ok, err := seg.Get(key)
+		if err != nil || !ok || !bytes.Equal(val, bytes.Repeat([]byte{byte(j)}, j%7)) {
+			t.Fatalf("unexpected lookup of %s: %v %v %v", key, val, ok, err)
+		}
+		if _, ok, err = seg.Get(append(key, '+')); ok || err != nil {
+			t.Fatalf("unexpected lookup of missing key: %v %v", ok, err)
+		}
+	}
+	count := 0
+	it := seg.Range(nil, nil)
+	for it.Next() {
+		count++
+	}
+	if count != len(keys) || it.Done() != nil {
+		t.Fatalf("full scan returned %d pairs: %v", count, it.Done())
+	}
+	for _, n := range []int{0, len(data) - 1, len(data) - 12} {
+		if _, err = OpenSegment(bytes.NewReader(data[:n]), int64(n)); err == nil {
+			t.Fatalf("expecting error for segment truncated to %d bytes", n)
+		}
+	}
+	damaged := append([]byte(nil), data...)
+	damaged[5] ^= 1
+	seg, _ = OpenSegment(bytes.NewReader(damaged), int64(len(damaged)))
+	if _, _, err = seg.Get(keys[0]); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("expecting checksum error, got %v", err)
+	}
+	// Insert a byte ahead of the index and adjust the footer to match
+	off := binary.BigEndian.Uint64(data[len(data)-segmentFooterLen:])
+	gap := append(append([]byte(nil), data[:off]...), 0)
+	gap = append(gap, data[off:]...)
+	binary.BigEndian.PutUint64(gap[len(gap)-segmentFooterLen:], off+1)
+	if _, err = OpenSegment(bytes.NewReader(gap), int64(len(gap))); !errors.Is(err, ErrValueRange) {
+		t.Fatalf("expecting error for gap before index, got %v", err)
+	}
+	sw = NewSegmentWriter(new(bytes.Buffer), 0)
+	sw.Add(nil, []byte("a"))
+	if sw.Add([]byte{}, []byte("b")) != errSegmentOrder {
+		t.Fatal("duplicate empty key not reported")
+	}
+}
+
+func TestSegment_Restarts(t *testing.T) {
+	var b bytes.Buffer
+	sw := NewSegmentWriter(&b, 0)
+	var raw int
+	for j := uint32(0); j < 1000; j++ {
+		var kb KeyBuffer
+		kb.Str("inventory", 16)
+		kb.Uint32(7)
+		kb.Uint32(j * 3)
+		key, _ := kb.Data()
+		raw += len(key)
+		if err := sw.Add(key, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	if len(data) > raw/2 {
+		t.Fatalf("expecting prefix compression, got %d bytes for %d bytes of keys", len(data), raw)
+	}
+	seg, err := OpenSegment(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := uint32(0); j < 3000; j++ {
+		var kb KeyBuffer
+		kb.Str("inventory", 16)
+		kb.Uint32(7)
+		kb.Uint32(j)
+		key, _ := kb.Data()
+		it := seg.Range(key, nil)
+		if !it.Next() {
+			if j <= 2997 || it.Done() != nil {
+				t.Fatalf("range from %d returned no pairs: %v", j, it.Done())
+			}
+			continue
+		}
+		var val uint32
+		get := NewGetBuffer(it.Key()[16:])
+		get.Uint32Fixed(&val)
+		get.Uint32Fixed(&val)
+		if want := (j + 2) / 3 * 3; val != want {
+			t.Fatalf("range from %d begins at %d, expecting %d", j, val, want)
+		}
+	}
+}
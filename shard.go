@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"errors"
+	"hash/fnv"
+)
+
+var errShardCount = errors.New("shard count must be greater than zero")
+
+// ShardOf returns a shard number in the range [0, n) that is derived from a
+// hash of the specified fields. The same fields always produce the same
+// shard number. Each field is hashed along with its length, so the boundaries
+// between fields are significant. n must be greater than zero.
+func ShardOf(n uint16, fields ...[]byte) uint16 {
+	h := fnv.New32a()
+	for _, f := range fields {
+		h.Write(KeyUint32(uint32(len(f))))
+		h.Write(f)
+	}
+	return uint16(h.Sum32() % uint32(n))
+}
+
+// Shard stores a two byte shard number, as returned by ShardOf, into the
+// receiving key buffer. It is normally the first segment of a key, followed
+// by the fields that were hashed. Keys with sequential values, such as
+// timestamps, are then spread over n partitions while remaining ordered
+// within each partition; a range scan over all keys visits each of the n
+// shard prefixes in turn. The internal error is set if n is zero.
+func (kb *KeyBuffer) Shard(n uint16, fields ...[]byte) {
+	if n == 0 {
+		if kb.err == nil {
+			kb.err = errShardCount
+		}
+		return
+	}
+	kb.Uint16(ShardOf(n, fields...))
+}
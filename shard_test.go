@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Ensure that shard prefixes are deterministic and within range
+func TestKeyBuffer_Shard(t *testing.T) {
+	counts := make([]int, 8)
+	for j := uint64(0); j < 800; j++ {
+		var a, b KeyBuffer
+		a.Shard(8, KeyUint64(j), []byte("user"))
+		a.Uint64(j)
+		b.Shard(8, KeyUint64(j), []byte("user"))
+		b.Uint64(j)
+		ka, err := a.Data()
+		if err != nil {
+			t.Fatal(err)
+		}
+		kb, _ := b.Data()
+		if !bytes.Equal(ka, kb) {
+			t.Fatal("shard prefix is not deterministic")
+		}
+		var shard uint16
+		NewKeyGetBuffer(ka).Uint16(&shard)
+		if shard >= 8 {
+			t.Fatalf("shard %d out of range", shard)
+		}
+		counts[shard]++
+	}
+	for shard, count := range counts {
+		if count == 0 {
+			t.Fatalf("no keys assigned to shard %d", shard)
+		}
+	}
+	if ShardOf(100, []byte("ab"), []byte("c")) == ShardOf(100, []byte("a"), []byte("bc")) &&
+		ShardOf(1000, []byte("ab"), []byte("c")) == ShardOf(1000, []byte("a"), []byte("bc")) {
+		t.Fatal("field boundaries are not significant")
+	}
+	var kb KeyBuffer
+	kb.Shard(0)
+	if _, err := kb.Data(); err == nil {
+		t.Fatal("zero shard count not reported")
+	}
+}
@@ -0,0 +1,294 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shellScanLimit is the default maximum number of records listed by the scan
+// command of a Shell.
+const shellScanLimit = 100
+
+// Shell interprets commands that inspect and repair the records of a table
+// held in a KV store, for example to give an operator access to
+// store-encoded data from an administrative endpoint or console. Keys are
+// described by a KeySchema and records by a Schema. A command is a name
+// followed by arguments separated by white space; an argument that contains
+// white space can be written as a double-quoted Go string literal. Key and
+// field values are written in the form in which they are displayed: integers
+// in decimal, times in RFC 3339 form, and byte sequences in hexadecimal. The
+// commands are:
+//
+//	get key...               show the record as a JSON object
+//	scan [key...]            list records whose keys begin with the values
+//	decode key...            show each field of the record with its offset
+//	patch key... name value  replace the value of one field of the record
+//
+// Every command other than scan takes a value for each key field. decode
+// renders as much of a damaged record as can be unpacked, followed by the
+// error and the remaining bytes.
+type Shell struct {
+	kv    KV
+	keys  *KeySchema
+	s     *Schema
+	limit int
+}
+
+// NewShell returns a shell for the records of kv whose keys conform to keys
+// and whose values conform to s.
+func NewShell(kv KV, keys *KeySchema, s *Schema) *Shell {
+	return &Shell{kv: kv, keys: keys, s: s, limit: shellScanLimit}
+}
+
+// SetScanLimit assigns the maximum number of records listed by the scan
+// command. A limit that is not positive lists every matching record. The
+// default limit is 100.
+func (sh *Shell) SetScanLimit(n int) {
+	sh.limit = n
+}
+
+// Run executes each line read from r as a command, writing its output to w.
+// Blank lines and lines that begin with # are ignored. An error in a command
+// is written to w as a line that begins with "error: " and does not stop the
+// shell. Run returns when r is exhausted, returning nil, or when reading from
+// r or writing to w fails.
+func (sh *Shell) Run(r io.Reader, w io.Writer) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if err := sh.Exec(w, sc.Text()); err != nil {
+			if _, err = fmt.Fprintf(w, "error: %s\n", err); err != nil {
+				return err
+			}
+		}
+	}
+	return sc.Err()
+}
+
+// Exec executes the command held by line, writing its output to w. A blank
+// line or one that begins with # is ignored.
+func (sh *Shell) Exec(w io.Writer, line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return nil
+	}
+	args, err := shellArgs(line)
+	if err != nil {
+		return err
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "get", "decode":
+		var rec []byte
+		if _, rec, err = sh.lookup(args); err == nil && cmd == "get" {
+			if rec, err = ToJSON(sh.s, rec); err == nil {
+				_, err = fmt.Fprintf(w, "%s\n", rec)
+			}
+		} else if err == nil {
+			_, err = io.WriteString(w, sh.s.DumpString(rec))
+		}
+	case "scan":
+		err = sh.scan(w, args)
+	case "patch":
+		err = sh.patch(w, args)
+	default:
+		err = fmt.Errorf("unknown command %q", cmd)
+	}
+	return err
+}
+
+// key returns the key, possibly partial, built from the values of args.
+func (sh *Shell) key(args []string) ([]byte, error) {
+	fields := sh.keys.fields
+	if len(args) > len(fields) {
+		return nil, fmt.Errorf("expecting at most %d key values, got %d", len(fields), len(args))
+	}
+	vals := make([]interface{}, len(args))
+	for j := range vals {
+		val, err := parseValue(fields[j].Kind, args[j])
+		if err != nil {
+			return nil, fmt.Errorf("key field %s: %w", fields[j].label(j), err)
+		}
+		vals[j] = val
+	}
+	return NewKeySchema(fields[:len(args)]...).Encode(vals...)
+}
+
+// lookup returns the key built from args, which must hold a value for each
+// field of the key schema, and the record stored with it.
+func (sh *Shell) lookup(args []string) (key, rec []byte, err error) {
+	if len(args) != len(sh.keys.fields) {
+		return nil, nil, fmt.Errorf("expecting %d key values, got %d", len(sh.keys.fields), len(args))
+	}
+	var ok bool
+	if key, err = sh.key(args); err == nil {
+		rec, ok, err = sh.kv.Get(key)
+	}
+	if err == nil && !ok {
+		err = fmt.Errorf("no record with key %s", sh.keys.String(key))
+	}
+	return
+}
+
+// scan lists the records whose keys begin with the key values of args.
+func (sh *Shell) scan(w io.Writer, args []string) error {
+	prefix, err := sh.key(args)
+	if err != nil {
+		return err
+	}
+	var count int
+	scanErr := sh.kv.Scan(prefix, PrefixSuccessor(prefix), func(key, val []byte) bool {
+		if sh.limit > 0 && count == sh.limit {
+			_, err = fmt.Fprintf(w, "(stopped after %d records)\n", count)
+			return false
+		}
+		count++
+		text, jsonErr := ToJSON(sh.s, val)
+		if jsonErr != nil {
+			text = []byte("error: " + jsonErr.Error())
+		}
+		_, err = fmt.Fprintf(w, "%s\t%s\n", sh.keys.String(key), text)
+		return err == nil
+	})
+	if err == nil {
+		err = scanErr
+	}
+	return err
+}
+
+// patch replaces the value of one field of a record. args holds the key
+// values followed by the field name and its new value.
+func (sh *Shell) patch(w io.Writer, args []string) error {
+	n := len(sh.keys.fields)
+	if len(args) != n+2 {
+		return fmt.Errorf("expecting %d key values, a field name and a value", n)
+	}
+	key, rec, err := sh.lookup(args[:n])
+	var vals map[string]interface{}
+	if err == nil {
+		vals, err = sh.s.Decode(rec)
+	}
+	if err != nil {
+		return err
+	}
+	name, text := args[n], args[n+1]
+	for _, f := range sh.s.fields {
+		if f.Name == name {
+			if vals[name], err = parseValue(f.Kind, text); err != nil {
+				return fmt.Errorf("field %s: %w", name, err)
+			}
+			if rec, err = sh.s.Encode(vals); err == nil {
+				err = sh.kv.Put(key, rec)
+			}
+			if err == nil {
+				rec, err = ToJSON(sh.s, rec)
+			}
+			if err == nil {
+				_, err = fmt.Fprintf(w, "%s\n", rec)
+			}
+			return err
+		}
+	}
+	return fmt.Errorf("schema has no field %s", name)
+}
+
+var errShellQuote = errors.New("quoted argument is not terminated")
+
+// shellArgs splits line into arguments separated by white space. An argument
+// that begins with a double quote is a Go string literal.
+func shellArgs(line string) (args []string, err error) {
+	for {
+		line = strings.TrimLeft(line, " \t\r\n")
+		if line == "" {
+			return
+		}
+		pos := strings.IndexAny(line, " \t\r\n")
+		if line[0] == '"' {
+			pos = -1
+			for j := 1; j < len(line) && pos < 0; j++ {
+				switch line[j] {
+				case '\\':
+					j++
+				case '"':
+					pos = j + 1
+				}
+			}
+			if pos < 0 {
+				return nil, errShellQuote
+			}
+		} else if pos < 0 {
+			pos = len(line)
+		}
+		arg := line[:pos]
+		if arg[0] == '"' {
+			if arg, err = strconv.Unquote(arg); err != nil {
+				return nil, fmt.Errorf("argument %s: %w", line[:pos], err)
+			}
+		}
+		args = append(args, arg)
+		line = line[pos:]
+	}
+}
+
+// parseValue returns the value of kind k held by text, which is in the form
+// produced by renderValue without the quotes of a string.
+func parseValue(k Kind, text string) (val interface{}, err error) {
+	var u uint64
+	var i int64
+	switch k {
+	case KindTime:
+		val, err = time.Parse(time.RFC3339Nano, text)
+	case KindUint64:
+		u, err = strconv.ParseUint(text, 10, 64)
+		val = u
+	case KindInt64:
+		i, err = strconv.ParseInt(text, 10, 64)
+		val = i
+	case KindUint32:
+		u, err = strconv.ParseUint(text, 10, 32)
+		val = uint32(u)
+	case KindInt32:
+		i, err = strconv.ParseInt(text, 10, 32)
+		val = int32(i)
+	case KindUint16:
+		u, err = strconv.ParseUint(text, 10, 16)
+		val = uint16(u)
+	case KindInt16:
+		i, err = strconv.ParseInt(text, 10, 16)
+		val = int16(i)
+	case KindUint8:
+		u, err = strconv.ParseUint(text, 10, 8)
+		val = uint8(u)
+	case KindInt8:
+		i, err = strconv.ParseInt(text, 10, 8)
+		val = int8(i)
+	case KindStr, KindVarStr:
+		val = text
+	case KindBytes, KindVarBytes:
+		val, err = hex.DecodeString(text)
+	default:
+		err = fmt.Errorf("unsupported kind %s", k)
+	}
+	return
+}
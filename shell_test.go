@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ExampleShell() {
+	var kv MemKV
+	keys := NewKeySchema(KeyField{Name: "region", Kind: KindVarStr}, KeyField{Name: "id", Kind: KindUint32})
+	s, _ := ParseSchema("name:str, qty:int32")
+	for j, name := range []string{"bolt", "nut", "gear"} {
+		key, _ := keys.Encode("eu", uint32(j+1))
+		rec, _ := s.Encode(map[string]interface{}{"name": name, "qty": int32(10 * j)})
+		kv.Put(key, rec)
+	}
+	sh := NewShell(&kv, keys, s)
+	sh.Run(strings.NewReader(`
+# inspect and repair
+get eu 2
+patch eu 2 qty -5
+decode eu 2
+scan eu
+get us 1
+`), os.Stdout)
+	// Output:
+	// {"name":"nut","qty":10}
+	// {"name":"nut","qty":-5}
+	//      0  name  str     "nut"
+	//      4  qty   int32   -5
+	// region: "eu", id: 1	{"name":"bolt","qty":0}
+	// region: "eu", id: 2	{"name":"nut","qty":-5}
+	// region: "eu", id: 3	{"name":"gear","qty":20}
+	// error: no record with key region: "us", id: 1
+}
+
+func TestShell(t *testing.T) {
+	var kv MemKV
+	keys := NewKeySchema(KeyField{Name: "at", Kind: KindTime}, KeyField{Kind: KindVarBytes})
+	s, _ := ParseSchema("note:str, raw:bytes, n:uint8")
+	at := time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC)
+	for j := 0; j < 3; j++ {
+		key, _ := keys.Encode(at, []byte{byte(j)})
+		rec, _ := s.Encode(map[string]interface{}{"note": "a b", "raw": []byte{0xca, 0xfe}, "n": uint8(j)})
+		kv.Put(key, rec)
+	}
+	key, _ := keys.Encode(at.Add(time.Hour), []byte{9})
+	kv.Put(key, []byte{0x01, 'x'})
+	sh := NewShell(&kv, keys, s)
+	exec := func(line string) (string, error) {
+		var b bytes.Buffer
+		err := sh.Exec(&b, line)
+		return b.String(), err
+	}
+	for _, c := range []struct {
+		line, out string
+	}{
+		{"", ""},
+		{"  # comment with \"", ""},
+		{"get 2016-05-01T12:00:00Z 01", `{"note":"a b","raw":"yv4=","n":1}` + "\n"},
+		{`patch 2016-05-01T12:00:00Z 01 note "tab\there"`, `{"note":"tab\there","raw":"yv4=","n":1}` + "\n"},
+		{"patch 2016-05-01T12:00:00Z 01 raw 00ff", `{"note":"tab\there","raw":"AP8=","n":1}` + "\n"},
+		{"decode 2016-05-01T13:00:00Z 09", "     0  note  str     \"x\"\n     2  error: unpacking value 1 (raw) at offset 2: EOF\n"},
+	} {
+		out, err := exec(c.line)
+		if err != nil || out != c.out {
+			t.Fatalf("%q: expecting %q, got %q, %v", c.line, c.out, out, err)
+		}
+	}
+	sh.SetScanLimit(2)
+	out, err := exec("scan 2016-05-01T12:00:00Z")
+	if err != nil || strings.Count(out, "\n") != 3 || !strings.HasSuffix(out, "(stopped after 2 records)\n") {
+		t.Fatalf("unexpected limited scan %q, %v", out, err)
+	}
+	sh.SetScanLimit(0)
+	out, err = exec("scan")
+	if err != nil || strings.Count(out, "\n") != 4 || !strings.Contains(out, "#1: 09\terror: ") {
+		t.Fatalf("unexpected scan %q, %v", out, err)
+	}
+	for _, c := range []struct {
+		line, err string
+	}{
+		{"drop", `unknown command "drop"`},
+		{`get "2016`, "quoted argument is not terminated"},
+		{"get 2016-05-01T12:00:00Z", "expecting 2 key values, got 1"},
+		{"get yesterday 01", "key field at: parsing time"},
+		{"get 2016-05-01T12:00:00Z 0g", "key field #1: encoding/hex"},
+		{"scan 2016-05-01T12:00:00Z 01 02", "expecting at most 2 key values, got 3"},
+		{"patch 2016-05-01T12:00:00Z 01 n", "expecting 2 key values, a field name and a value"},
+		{"patch 2016-05-01T12:00:00Z 01 n 256", "field n: strconv.ParseUint"},
+		{"patch 2016-05-01T12:00:00Z 01 size 1", "schema has no field size"},
+		{"patch 2016-05-01T13:00:00Z 09 n 1", "unpacking value 1 (raw)"},
+	} {
+		_, err := exec(c.line)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("%q: expecting error containing %q, got %v", c.line, c.err, err)
+		}
+	}
+	// A failed patch leaves the record unchanged
+	out, _ = exec("get 2016-05-01T12:00:00Z 01")
+	if out != `{"note":"tab\there","raw":"AP8=","n":1}`+"\n" {
+		t.Fatalf("unexpected record %q", out)
+	}
+}
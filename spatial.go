@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// interleave returns the bits of vals interleaved into a big-endian byte
+// sequence, taking the most significant bit of each value in turn.
+func interleave(vals []uint32) []byte {
+	sl := make([]byte, 4*len(vals))
+	pos := 0
+	for bit := 31; bit >= 0; bit-- {
+		for _, v := range vals {
+			if v>>uint(bit)&1 != 0 {
+				sl[pos/8] |= 0x80 >> uint(pos%8)
+			}
+			pos++
+		}
+	}
+	return sl
+}
+
+// ZOrder stores the specified values into the receiving key buffer with their
+// bits interleaved, most significant first, to form a Z-order (Morton) code of
+// four bytes per value. Points that are near each other in the
+// multidimensional space of the values tend to have nearby keys, so a range
+// scan over a region of that space touches a relatively small number of key
+// ranges.
+func (kb *KeyBuffer) ZOrder(vals ...uint32) {
+	kb.write(interleave(vals))
+}
+
+// ZOrder extracts values that were stored with KeyBuffer.ZOrder from the
+// receiving key buffer. The number of values must match the number that was
+// stored.
+func (kg *KeyGetBuffer) ZOrder(vals ...*uint32) {
+	if sl := kg.next(4 * len(vals)); sl != nil {
+		for _, v := range vals {
+			*v = 0
+		}
+		pos := 0
+		for bit := 31; bit >= 0; bit-- {
+			for _, v := range vals {
+				if sl[pos/8]&(0x80>>uint(pos%8)) != 0 {
+					*v |= 1 << uint(bit)
+				}
+				pos++
+			}
+		}
+	}
+}
+
+// Geohash returns the geohash of the specified location with precision
+// characters. Each character narrows the cell that contains the location by
+// five bits, alternately refining longitude and latitude. Geohash characters
+// are in ascending byte order, so locations within a cell share the cell's
+// geohash as a prefix and sort together. An error is returned if lat is not
+// within [-90, 90], lon is not within [-180, 180] or precision is not within
+// [1, 12].
+func Geohash(lat, lon float64, precision int) (string, error) {
+	if !(lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180) {
+		return "", fmt.Errorf("invalid location %g, %g", lat, lon)
+	}
+	if precision < 1 || precision > 12 {
+		return "", fmt.Errorf("geohash precision %d is not within [1, 12]", precision)
+	}
+	latLo, latHi, lonLo, lonHi := -90.0, 90.0, -180.0, 180.0
+	sl := make([]byte, precision)
+	even := true
+	for j := range sl {
+		var ch int
+		for bit := 0; bit < 5; bit++ {
+			ch <<= 1
+			if even {
+				if mid := (lonLo + lonHi) / 2; lon >= mid {
+					ch |= 1
+					lonLo = mid
+				} else {
+					lonHi = mid
+				}
+			} else {
+				if mid := (latLo + latHi) / 2; lat >= mid {
+					ch |= 1
+					latLo = mid
+				} else {
+					latHi = mid
+				}
+			}
+			even = !even
+		}
+		sl[j] = geohashAlphabet[ch]
+	}
+	return string(sl), nil
+}
+
+// ParseGeohash returns the location at the center of the cell identified by
+// hash.
+func ParseGeohash(hash string) (lat, lon float64, err error) {
+	latLo, latHi, lonLo, lonHi := -90.0, 90.0, -180.0, 180.0
+	even := true
+	for j := 0; j < len(hash); j++ {
+		ch := strings.IndexByte(geohashAlphabet, hash[j])
+		if ch < 0 {
+			return 0, 0, fmt.Errorf("invalid geohash %q", hash)
+		}
+		for bit := 4; bit >= 0; bit-- {
+			set := ch>>uint(bit)&1 != 0
+			if even {
+				if mid := (lonLo + lonHi) / 2; set {
+					lonLo = mid
+				} else {
+					lonHi = mid
+				}
+			} else {
+				if mid := (latLo + latHi) / 2; set {
+					latLo = mid
+				} else {
+					latHi = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return (latLo + latHi) / 2, (lonLo + lonHi) / 2, nil
+}
+
+// Geohash stores the geohash of the specified location, as returned by the
+// Geohash function, into the receiving key buffer. It occupies precision
+// bytes.
+func (kb *KeyBuffer) Geohash(lat, lon float64, precision int) {
+	if kb.err == nil {
+		var hash string
+		hash, kb.err = Geohash(lat, lon, precision)
+		kb.write([]byte(hash))
+	}
+}
+
+// Geohash extracts a geohash of the specified precision from the receiving
+// key buffer and assigns the location at the center of its cell to lat and
+// lon.
+func (kg *KeyGetBuffer) Geohash(lat, lon *float64, precision int) {
+	if sl := kg.next(precision); sl != nil {
+		var la, lo float64
+		la, lo, kg.err = ParseGeohash(string(sl))
+		if kg.err == nil {
+			*lat, *lon = la, lo
+		}
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ExampleKeyBuffer_ZOrder demonstrates the interleaving of two values.
+func ExampleKeyBuffer_ZOrder() {
+	var kb KeyBuffer
+	kb.ZOrder(0xffff0000, 0x0000ffff)
+	key, err := kb.Data()
+	if err == nil {
+		var x, y uint32
+		kg := NewKeyGetBuffer(key)
+		kg.ZOrder(&x, &y)
+		err = kg.Done()
+		if err == nil {
+			fmt.Printf("% x\n%08x %08x\n", key, x, y)
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// aa aa aa aa 55 55 55 55
+	// ffff0000 0000ffff
+}
+
+// Ensure that Z-order keys are restored for several dimensions
+func TestKeyBuffer_ZOrder(t *testing.T) {
+	vals := []uint32{0x12345678, 0x9abcdef0, 0x0f0f0f0f}
+	var kb KeyBuffer
+	kb.ZOrder(vals...)
+	key, err := kb.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a, b, c uint32
+	kg := NewKeyGetBuffer(key)
+	kg.ZOrder(&a, &b, &c)
+	if err = kg.Done(); err != nil {
+		t.Fatal(err)
+	}
+	if a != vals[0] || b != vals[1] || c != vals[2] {
+		t.Fatalf("unexpected values %x %x %x", a, b, c)
+	}
+}
+
+// ExampleGeohash demonstrates geohash location keys.
+func ExampleGeohash() {
+	var kb KeyBuffer
+	kb.Geohash(57.64911, 10.40744, 11)
+	key, err := kb.Data()
+	if err == nil {
+		var lat, lon float64
+		kg := NewKeyGetBuffer(key)
+		kg.Geohash(&lat, &lon, 11)
+		err = kg.Done()
+		if err == nil {
+			fmt.Printf("%s %.5f %.5f\n", key, lat, lon)
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	_, err = Geohash(91, 0, 5)
+	fmt.Println(err)
+	// Output:
+	// u4pruydqqvj 57.64911 10.40744
+	// invalid location 91, 0
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// RecordSplitter packs records into batches that each fit within a byte
+// budget, such as the message size limit of a message bus. Each record in a
+// batch is framed with its length in the manner of PutBuffer.Bytes, and
+// completed batches are passed to a callback. Use SplitBatch to recover the
+// records of a batch.
+type RecordSplitter struct {
+	limit int
+	emit  func(batch []byte) error
+	put   PutBuffer
+	err   error
+}
+
+// NewRecordSplitter returns a splitter that emits batches of at most limit
+// bytes by calling emit. The slice passed to emit is not modified by the
+// splitter after emit returns. If emit returns an error, the splitter's
+// internal error is set.
+func NewRecordSplitter(limit int, emit func(batch []byte) error) *RecordSplitter {
+	return &RecordSplitter{limit: limit, emit: emit}
+}
+
+// framedLen returns the number of bytes occupied by a framed record of length
+// n.
+func framedLen(n int) int {
+	var hold [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(hold[:], uint64(n)) + n
+}
+
+// Add appends rec to the current batch. If the framed record does not fit in
+// the remainder of the current batch, the batch is emitted first. A record
+// that cannot fit within an empty batch sets the internal error rather than
+// producing an oversized batch.
+func (rs *RecordSplitter) Add(rec []byte) {
+	if rs.err == nil {
+		n := framedLen(len(rec))
+		if n > rs.limit {
+			rs.err = fmt.Errorf("record of %d bytes exceeds batch limit of %d bytes", n, rs.limit)
+			return
+		}
+		if len(rs.put.buf)+n > rs.limit {
+			rs.Flush()
+		}
+		if rs.err == nil {
+			rs.put.Bytes(rec)
+			rs.err = rs.put.err
+		}
+	}
+}
+
+// Flush emits the current batch if it contains any records. It returns the
+// internal error, which will be nil if all records have been successfully
+// added and emitted.
+func (rs *RecordSplitter) Flush() error {
+	if rs.err == nil && len(rs.put.buf) > 0 {
+		var data []byte
+		data, rs.err = rs.put.DataUnsafe()
+		if rs.err == nil {
+			rs.put = PutBuffer{}
+			rs.err = rs.emit(data)
+		}
+	}
+	return rs.err
+}
+
+// SplitBatch returns the records contained in a batch that was emitted by a
+// RecordSplitter.
+func SplitBatch(batch []byte) (list [][]byte, err error) {
+	get := NewGetBuffer(batch)
+	for get.err == nil && get.more() {
+		var rec []byte
+		get.Bytes(&rec)
+		list = append(list, rec)
+	}
+	if err = get.Done(); err != nil {
+		list = nil
+	}
+	return
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// ExampleRecordSplitter demonstrates the packing of records into batches
+// that fit within a size limit.
+func ExampleRecordSplitter() {
+	var count int
+	rs := NewRecordSplitter(32, func(batch []byte) error {
+		list, err := SplitBatch(batch)
+		if err == nil {
+			fmt.Printf("batch of %d bytes holds %d records\n", len(batch), len(list))
+			count += len(list)
+		}
+		return err
+	})
+	for j := 1; j <= 8; j++ {
+		rs.Add(bytes.Repeat([]byte{byte(j)}, j))
+	}
+	err := rs.Flush()
+	if err == nil {
+		fmt.Printf("%d records emitted\n", count)
+	} else {
+		fmt.Println(err)
+	}
+	// Output:
+	// batch of 27 bytes holds 6 records
+	// batch of 17 bytes holds 2 records
+	// 8 records emitted
+}
+
+// Ensure that a record too large for any batch is reported
+func TestRecordSplitter_Oversized(t *testing.T) {
+	var emitted int
+	rs := NewRecordSplitter(16, func(batch []byte) error {
+		emitted++
+		return nil
+	})
+	rs.Add(make([]byte, 4))
+	rs.Add(make([]byte, 16))
+	if rs.Flush() == nil {
+		t.Fatal("oversized record not reported")
+	}
+	if emitted != 0 {
+		t.Fatal("batch emitted after error")
+	}
+}
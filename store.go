@@ -17,14 +17,78 @@
 package store
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/base32"
 	"encoding/binary"
 	"errors"
-	"strings"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
 	"time"
+	"unicode/utf8"
 )
 
-var errNonempty = errors.New("the get buffer has not been completely emptied")
+// The following errors describe categories of failure. Errors returned by
+// the buffers of this package can be matched against them with errors.Is.
+var (
+	// ErrShortBuffer indicates that content ended before a value was
+	// completely unpacked.
+	ErrShortBuffer = errors.New("short buffer")
+	// ErrLeftover indicates that content remained after all values were
+	// unpacked.
+	ErrLeftover = errors.New("leftover content")
+	// ErrValueRange indicates that a value does not fit in its type or its
+	// declared width.
+	ErrValueRange = errors.New("value out of range")
+	// ErrLimitExceeded indicates that unpacking a value would exceed a limit
+	// assigned with GetBuffer.SetLimits.
+	ErrLimitExceeded = errors.New("limit exceeded")
+	// ErrSchemaMismatch indicates that a record was packed with a schema
+	// other than the one used to unpack it.
+	ErrSchemaMismatch = errors.New("schema mismatch")
+	// ErrChecksum indicates that content does not match the checksum that
+	// was packed with it.
+	ErrChecksum = errors.New("checksum mismatch")
+)
+
+// categoryError is an error with its own message that belongs to one of the
+// exported error categories.
+type categoryError struct {
+	msg      string
+	category error
+}
+
+func (e *categoryError) Error() string {
+	return e.msg
+}
+
+func (e *categoryError) Unwrap() error {
+	return e.category
+}
+
+// categoryErrorf returns an error with the formatted message that belongs to
+// the specified category.
+func categoryErrorf(category error, format string, args ...interface{}) error {
+	return &categoryError{msg: fmt.Sprintf(format, args...), category: category}
+}
+
+var (
+	errNonempty           = &categoryError{"the get buffer has not been completely emptied", ErrLeftover}
+	errKeyNonempty        = &categoryError{"the key get buffer has not been completely emptied", ErrLeftover}
+	errKeyShort           = &categoryError{"the key get buffer does not contain the requested field", ErrShortBuffer}
+	errKeyVarint          = errors.New("invalid variable-length integer in key")
+	errKeyPresence        = errors.New("invalid presence marker in key")
+	errKeyLength          = errors.New("invalid field length in key")
+	errInvalidUTF8        = errors.New("unpacked string is not valid UTF-8")
+	errSlot               = errors.New("put slot is not valid for this buffer")
+	errVarintOverflow     = errors.New("variable-length integer overflows 64 bits")
+	errVarintNoncanonical = categoryErrorf(ErrValueRange, "variable-length integer is not in canonical form")
+	errPutMark            = errors.New("put mark is not valid for this buffer")
+	errTeeModify          = errors.New("bytes already copied to the tee writer cannot be modified")
+	errRewind             = errors.New("a get buffer reading from an io.Reader cannot be rewound")
+)
 
 // KeyUint64 returns a comparable eight byte slice representation of val
 // suitable for use in keys.
@@ -74,12 +138,74 @@ func KeyInt8(val int8) byte {
 	return uint8(val) + 1<<7
 }
 
+// PrefixSuccessor returns the smallest key that is greater than every key
+// that begins with prefix. This is the exclusive upper bound of a range scan
+// over all keys with the given prefix. Trailing 0xff bytes are dropped and the
+// last remaining byte is incremented. If prefix is empty or consists only of
+// 0xff bytes, no such key exists and nil is returned, meaning that the scan is
+// unbounded above. The returned slice does not share memory with prefix.
+func PrefixSuccessor(prefix []byte) []byte {
+	for j := len(prefix) - 1; j >= 0; j-- {
+		if prefix[j] != 0xff {
+			sl := append([]byte(nil), prefix[:j+1]...)
+			sl[j]++
+			return sl
+		}
+	}
+	return nil
+}
+
+var keyEncoding = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// KeyText returns a textual representation of key that sorts in the same
+// order as key itself. It uses the base32hex alphabet without padding, so it
+// is suitable for systems that accept only string keys or for inclusion in
+// URLs. Use ParseKeyText to recover the key.
+func KeyText(key []byte) string {
+	return keyEncoding.EncodeToString(key)
+}
+
+// ParseKeyText returns the key that was converted to text with KeyText.
+func ParseKeyText(str string) ([]byte, error) {
+	return keyEncoding.DecodeString(str)
+}
+
+// SplitKey divides key into segments of the specified widths without
+// decoding them. A width of zero denotes a variable-length segment as stored
+// by KeyBuffer.VarBytes or KeyBuffer.VarStr; the returned segment includes its
+// escape sequences and terminator. An error is returned if key is too short
+// for the widths or contains content beyond them. The returned segments share
+// memory with key.
+func SplitKey(key []byte, widths ...uint) (list [][]byte, err error) {
+	kg := NewKeyGetBuffer(key)
+	for _, wd := range widths {
+		pos := len(key) - len(kg.buf)
+		if wd == 0 {
+			kg.VarBytes(new([]byte))
+		} else {
+			kg.next(int(wd))
+		}
+		if kg.err != nil {
+			break
+		}
+		list = append(list, key[pos:len(key)-len(kg.buf)])
+	}
+	if err = kg.Done(); err != nil {
+		list = nil
+	}
+	return
+}
+
 // KeyBuffer facilitates the storage of one or more fields to be used in
 // comparable, fixed-length index keys. The zero value for a variable of type
 // KeyBuffer is ready to use.
 type KeyBuffer struct {
-	buf bytes.Buffer
-	err error
+	buf     bytes.Buffer
+	err     error
+	collate func(string) []byte
+	strict  bool
+	pad     byte
+	padSet  bool
 }
 
 func (kb *KeyBuffer) write(sl []byte) {
@@ -94,6 +220,45 @@ func (kb *KeyBuffer) Time(tm time.Time) {
 	kb.write(KeyInt64(tm.Unix()))
 }
 
+// ReverseTime stores the specified time.Time value into the receiving key
+// buffer such that later times sort before earlier ones. It occupies eight
+// bytes, the ones' complement of the bytes stored by Time. A range scan over
+// keys that share a prefix then visits the most recent entries first.
+func (kb *KeyBuffer) ReverseTime(tm time.Time) {
+	kb.write(KeyUint64(^(uint64(tm.Unix()) + 1<<63)))
+}
+
+// TimeNano stores the specified time.Time value into the receiving key
+// buffer with nanosecond resolution. It occupies twelve bytes: the seconds as
+// stored by Time followed by the nanoseconds within the second.
+func (kb *KeyBuffer) TimeNano(tm time.Time) {
+	kb.write(KeyInt64(tm.Unix()))
+	kb.write(KeyUint32(uint32(tm.Nanosecond())))
+}
+
+// TimeBucket stores the start of the interval of the specified width that
+// contains tm into the receiving key buffer, as stored by Time. Intervals are
+// aligned to the zero time, so widths such as time.Hour and 24*time.Hour
+// produce hour and UTC day buckets. When a bucket leads a key, all keys
+// within a bucket share a prefix and an expired bucket can be dropped with a
+// single range deletion. See TimeBucketKey.
+func (kb *KeyBuffer) TimeBucket(tm time.Time, width time.Duration) {
+	kb.Time(tm.Truncate(width))
+}
+
+// TimeBucketKey returns the prefix of the bucket of the specified width that
+// contains tm, as stored by KeyBuffer.TimeBucket, and a key made up of that
+// prefix followed by tm as stored by KeyBuffer.TimeNano. The key can be
+// extended with further fields to distinguish entries with equal times.
+func TimeBucketKey(tm time.Time, width time.Duration) (prefix, key []byte) {
+	var kb KeyBuffer
+	kb.TimeBucket(tm, width)
+	prefix = append([]byte(nil), kb.buf.Bytes()...)
+	kb.TimeNano(tm)
+	key = kb.buf.Bytes()
+	return
+}
+
 // Uint64 stores the specified uint64 value into the receiving key
 // buffer.
 func (kb *KeyBuffer) Uint64(val uint64) {
@@ -141,9 +306,65 @@ func (kb *KeyBuffer) Int8(val int8) {
 	kb.Uint8(KeyInt8(val))
 }
 
+// SetStrict controls the handling of Str and Bytes values that are longer
+// than their declared width. By default, such values are silently truncated,
+// which can cause distinct values to produce identical keys. In strict mode,
+// the internal error is set instead.
+func (kb *KeyBuffer) SetStrict(strict bool) {
+	kb.strict = strict
+}
+
+func (kb *KeyBuffer) checkWidth(ln int, width uint) {
+	if kb.err == nil && kb.strict && ln > int(width) {
+		kb.err = categoryErrorf(ErrValueRange, "key field of length %d exceeds width %d", ln, width)
+	}
+}
+
+// keyUvarint appends to sl the minimal big-endian representation of val,
+// preceded by hdr plus its length in bytes.
+func keyUvarint(sl []byte, hdr uint8, val uint64) []byte {
+	var hold [8]byte
+	binary.BigEndian.PutUint64(hold[:], val)
+	n := 8
+	for n > 0 && hold[8-n] == 0 {
+		n--
+	}
+	return append(append(sl, hdr+uint8(n)), hold[8-n:]...)
+}
+
+// Uvarint stores the specified uint64 value into the receiving key buffer
+// using a variable-length encoding that preserves order. The value occupies
+// one byte holding its length followed by its significant bytes, so small
+// values produce compact keys that still compare correctly byte-wise.
+func (kb *KeyBuffer) Uvarint(val uint64) {
+	var hold [9]byte
+	kb.write(keyUvarint(hold[:0], 0, val))
+}
+
+// Varint stores the specified int64 value into the receiving key buffer using
+// a variable-length encoding that preserves order. Non-negative values are
+// stored like Uvarint with a length byte offset by 8. Negative values are
+// stored as the ones' complement of their magnitude with a length byte of 8
+// minus the magnitude's length, so they sort before all non-negative values.
+func (kb *KeyBuffer) Varint(val int64) {
+	var hold [9]byte
+	if val >= 0 {
+		kb.write(keyUvarint(hold[:0], 8, uint64(val)))
+	} else {
+		sl := keyUvarint(hold[:0], 0, uint64(-(val+1))+1)
+		sl[0] = 8 - sl[0]
+		for j := 1; j < len(sl); j++ {
+			sl[j] = ^sl[j]
+		}
+		kb.write(sl)
+	}
+}
+
 // Bytes stores the specified byte slice values into the receiving key buffer.
 // It will be either truncated or zero-filled to the length specified by width.
+// See SetStrict for an alternative to truncation.
 func (kb *KeyBuffer) Bytes(sl []byte, width uint) {
+	kb.checkWidth(len(sl), width)
 	if kb.err == nil {
 		wd := int(width)
 		ln := len(sl)
@@ -159,26 +380,106 @@ func (kb *KeyBuffer) Bytes(sl []byte, width uint) {
 	}
 }
 
+// SetCollator assigns a function that converts strings to sort keys. When
+// set, Str and VarStr store the sort key returned by fn rather than the bytes
+// of the string itself. This permits language-correct ordering, for example
+// by passing the KeyFromString method of a golang.org/x/text/collate Buffer.
+// Note that a sort key generally cannot be converted back to its string. A
+// nil value restores byte-wise ordering.
+func (kb *KeyBuffer) SetCollator(fn func(string) []byte) {
+	kb.collate = fn
+}
+
+// SetPad assigns the byte used to fill string fields that are shorter than
+// their declared width. The default pad byte is a space (0x20), which sorts a
+// short string after longer strings that continue with control characters.
+// A pad byte of 0x00 sorts every string before its extensions.
+func (kb *KeyBuffer) SetPad(pad byte) {
+	kb.pad = pad
+	kb.padSet = true
+}
+
 // Str stores the specified string value into the receiving key buffer.
 // It will be either truncated or space-filled to the length specified by
-// width.
+// width. If a pad byte has been assigned with SetPad, it is used instead of a
+// space. If a collator has been assigned, its sort key for str is stored
+// instead and is zero-filled. See SetStrict for an alternative to truncation.
 func (kb *KeyBuffer) Str(str string, width uint) {
-	// Consider case insensitivity
+	if kb.collate != nil {
+		kb.Bytes(kb.collate(str), width)
+	} else if kb.padSet {
+		kb.StrPad(str, width, kb.pad)
+	} else {
+		kb.StrPad(str, width, ' ')
+	}
+}
+
+// StrPad stores the specified string value into the receiving key buffer. It
+// will be either truncated or filled with the pad byte to the length
+// specified by width. Unlike Str, it is not affected by SetPad or
+// SetCollator.
+func (kb *KeyBuffer) StrPad(str string, width uint, pad byte) {
+	kb.checkWidth(len(str), width)
 	if kb.err == nil {
 		wd := int(width)
 		ln := len(str)
-		if ln >= int(wd) {
+		if ln >= wd {
 			_, kb.err = kb.buf.WriteString(str[:wd])
 		} else {
 			_, kb.err = kb.buf.WriteString(str)
-			if kb.err == nil {
-				// Following could be optimized by maintaining space buffer
-				_, kb.err = kb.buf.WriteString(strings.Repeat(" ", wd-ln))
+			for ln < wd && kb.err == nil {
+				kb.err = kb.buf.WriteByte(pad)
+				ln++
 			}
 		}
 	}
 }
 
+// VarBytes stores the specified byte slice into the receiving key buffer
+// without truncation or padding. Each zero byte in sl is escaped as 0x00 0xff
+// and the value is terminated with a single zero byte, so keys that contain
+// variable-length segments remain comparable.
+func (kb *KeyBuffer) VarBytes(sl []byte) {
+	for _, b := range sl {
+		kb.Uint8(b)
+		if b == 0 {
+			kb.Uint8(0xff)
+		}
+	}
+	kb.Uint8(0)
+}
+
+// VarStr stores the specified string value into the receiving key buffer
+// without truncation or padding. The encoding is the same as that of
+// VarBytes. If a collator has been assigned, its sort key for str is stored
+// instead.
+func (kb *KeyBuffer) VarStr(str string) {
+	if kb.collate != nil {
+		kb.VarBytes(kb.collate(str))
+	} else {
+		kb.VarBytes([]byte(str))
+	}
+}
+
+// NullsFirst stores a marker indicating whether the value of an optional
+// field is present into the receiving key buffer. If present is true, the
+// caller follows it with the value itself; otherwise the field is complete.
+// Keys in which the value is absent sort before all keys in which it is
+// present. See NullsLast for the opposite placement.
+func (kb *KeyBuffer) NullsFirst(present bool) {
+	if present {
+		kb.Uint8(1)
+	} else {
+		kb.Uint8(0)
+	}
+}
+
+// NullsLast is like NullsFirst except that keys in which the value is absent
+// sort after all keys in which it is present.
+func (kb *KeyBuffer) NullsLast(present bool) {
+	kb.NullsFirst(!present)
+}
+
 // SetError permits the caller to assign an error value to the key buffer. In
 // some cases, this may simplify the construction of a key by deferring the
 // handling of an error to the point at which Data() is called. This method
@@ -197,45 +498,423 @@ func (kb *KeyBuffer) Data() ([]byte, error) {
 	return nil, kb.err
 }
 
-func (put *PutBuffer) vluEncode(val uint64) {
-	if put.err == nil {
-		var hold [binary.MaxVarintLen64]byte // Holds enough septets to contain a uint64
-		len := binary.PutUvarint(hold[:], val)
-		_, put.err = put.buf.Write(hold[0:len])
+// AppendTo appends the stored values in the receiving key buffer to dst and
+// returns the extended slice. This is followed by the internal error code
+// which will be nil if each key field has been properly loaded, in which case
+// the returned slice does not share memory with the key buffer. Used with
+// Reset, it permits many keys to be built without per-key allocations.
+func (kb *KeyBuffer) AppendTo(dst []byte) ([]byte, error) {
+	if kb.err == nil {
+		return append(dst, kb.buf.Bytes()...), nil
+	}
+	return dst, kb.err
+}
+
+// Reset discards the stored values and the internal error of the receiving
+// key buffer so that it can be used to build another key. The memory that
+// holds the values is retained for reuse, so a slice previously returned by
+// Data is overwritten by subsequent key building. Settings such as those made
+// by SetStrict, SetPad and SetCollator are not affected.
+func (kb *KeyBuffer) Reset() {
+	kb.buf.Reset()
+	kb.err = nil
+}
+
+// RangeEnd returns the exclusive upper bound of a range scan over all keys
+// that begin with the contents of the receiving key buffer. See
+// PrefixSuccessor for details, including the meaning of a nil return value
+// when the error is nil.
+func (kb *KeyBuffer) RangeEnd() ([]byte, error) {
+	sl, err := kb.Data()
+	if err == nil {
+		return PrefixSuccessor(sl), nil
 	}
+	return nil, err
 }
 
-func vluDecode(buf *bytes.Buffer) (val uint64, err error) {
-	val, err = binary.ReadUvarint(buf)
+// KeyGetBuffer facilitates the extraction of fields from a key that was built
+// with a KeyBuffer. The sequence of get method calls, including the widths of
+// string and byte slice fields, must mirror the calls used to build the key.
+type KeyGetBuffer struct {
+	buf []byte
+	err error
+}
+
+// NewKeyGetBuffer returns an initialized buffer that can be used to extract
+// fields from key. key specifies a byte slice that was generated using a
+// KeyBuffer.
+func NewKeyGetBuffer(key []byte) *KeyGetBuffer {
+	return &KeyGetBuffer{buf: key}
+}
+
+func (kg *KeyGetBuffer) next(n int) (sl []byte) {
+	if kg.err == nil {
+		if n < 0 {
+			kg.err = errKeyLength
+		} else if len(kg.buf) < n {
+			kg.err = errKeyShort
+		} else {
+			sl = kg.buf[:n]
+			kg.buf = kg.buf[n:]
+		}
+	}
 	return
 }
 
-func (put *PutBuffer) vlsEncode(val int64) {
+// Time extracts a time.Time value from the receiving key buffer.
+func (kg *KeyGetBuffer) Time(tm *time.Time) {
+	var val int64
+	kg.Int64(&val)
+	if kg.err == nil {
+		*tm = time.Unix(val, 0)
+	}
+}
+
+// ReverseTime extracts a time.Time value that was stored with
+// KeyBuffer.ReverseTime from the receiving key buffer.
+func (kg *KeyGetBuffer) ReverseTime(tm *time.Time) {
+	var val uint64
+	kg.Uint64(&val)
+	if kg.err == nil {
+		*tm = time.Unix(int64(^val-1<<63), 0)
+	}
+}
+
+// TimeNano extracts a time.Time value that was stored with
+// KeyBuffer.TimeNano from the receiving key buffer.
+func (kg *KeyGetBuffer) TimeNano(tm *time.Time) {
+	var sec int64
+	var nsec uint32
+	kg.Int64(&sec)
+	kg.Uint32(&nsec)
+	if kg.err == nil {
+		*tm = time.Unix(sec, int64(nsec))
+	}
+}
+
+// Uint64 extracts a uint64 value from the receiving key buffer.
+func (kg *KeyGetBuffer) Uint64(val *uint64) {
+	if sl := kg.next(8); sl != nil {
+		*val = binary.BigEndian.Uint64(sl)
+	}
+}
+
+// Int64 extracts an int64 value from the receiving key buffer.
+func (kg *KeyGetBuffer) Int64(val *int64) {
+	if sl := kg.next(8); sl != nil {
+		*val = int64(binary.BigEndian.Uint64(sl) - 1<<63)
+	}
+}
+
+// Uint32 extracts a uint32 value from the receiving key buffer.
+func (kg *KeyGetBuffer) Uint32(val *uint32) {
+	if sl := kg.next(4); sl != nil {
+		*val = binary.BigEndian.Uint32(sl)
+	}
+}
+
+// Int32 extracts an int32 value from the receiving key buffer.
+func (kg *KeyGetBuffer) Int32(val *int32) {
+	if sl := kg.next(4); sl != nil {
+		*val = int32(binary.BigEndian.Uint32(sl) - 1<<31)
+	}
+}
+
+// Uint16 extracts a uint16 value from the receiving key buffer.
+func (kg *KeyGetBuffer) Uint16(val *uint16) {
+	if sl := kg.next(2); sl != nil {
+		*val = binary.BigEndian.Uint16(sl)
+	}
+}
+
+// Int16 extracts an int16 value from the receiving key buffer.
+func (kg *KeyGetBuffer) Int16(val *int16) {
+	if sl := kg.next(2); sl != nil {
+		*val = int16(binary.BigEndian.Uint16(sl) - 1<<15)
+	}
+}
+
+// Uint8 extracts a uint8 value from the receiving key buffer.
+func (kg *KeyGetBuffer) Uint8(val *uint8) {
+	if sl := kg.next(1); sl != nil {
+		*val = sl[0]
+	}
+}
+
+// Int8 extracts an int8 value from the receiving key buffer.
+func (kg *KeyGetBuffer) Int8(val *int8) {
+	if sl := kg.next(1); sl != nil {
+		*val = int8(sl[0] - 1<<7)
+	}
+}
+
+// Uvarint extracts a uint64 value that was stored with KeyBuffer.Uvarint from
+// the receiving key buffer.
+func (kg *KeyGetBuffer) Uvarint(val *uint64) {
+	if hdr := kg.next(1); hdr != nil {
+		if hdr[0] > 8 {
+			kg.err = errKeyVarint
+		} else if sl := kg.next(int(hdr[0])); sl != nil {
+			var u uint64
+			for _, b := range sl {
+				u = u<<8 | uint64(b)
+			}
+			*val = u
+		}
+	}
+}
+
+// Varint extracts an int64 value that was stored with KeyBuffer.Varint from
+// the receiving key buffer.
+func (kg *KeyGetBuffer) Varint(val *int64) {
+	if hdr := kg.next(1); hdr != nil {
+		neg := hdr[0] < 8
+		n := int(hdr[0]) - 8
+		if neg {
+			n = -n
+		}
+		if n > 8 {
+			kg.err = errKeyVarint
+		} else if sl := kg.next(n); sl != nil {
+			var u uint64
+			for _, b := range sl {
+				if neg {
+					b = ^b
+				}
+				u = u<<8 | uint64(b)
+			}
+			if neg && (u == 0 || u > 1<<63) {
+				kg.err = errKeyVarint
+			} else if neg {
+				*val = -int64(u-1) - 1
+			} else if u > 1<<63-1 {
+				kg.err = errKeyVarint
+			} else {
+				*val = int64(u)
+			}
+		}
+	}
+}
+
+// Bytes extracts a byte slice of the specified width from the receiving key
+// buffer. Any zero-filling applied when the key was built is retained.
+func (kg *KeyGetBuffer) Bytes(sl *[]byte, width uint) {
+	if seg := kg.next(int(width)); seg != nil {
+		*sl = append([]byte(nil), seg...)
+	}
+}
+
+// Str extracts a string of the specified width from the receiving key buffer.
+// Trailing spaces are removed, so a string that originally ended with spaces
+// is not restored exactly.
+func (kg *KeyGetBuffer) Str(str *string, width uint) {
+	kg.StrPad(str, width, ' ')
+}
+
+// StrPad extracts a string of the specified width from the receiving key
+// buffer. Trailing pad bytes are removed. This is the counterpart of
+// KeyBuffer.StrPad and of KeyBuffer.Str after a call to SetPad.
+func (kg *KeyGetBuffer) StrPad(str *string, width uint, pad byte) {
+	if seg := kg.next(int(width)); seg != nil {
+		ln := len(seg)
+		for ln > 0 && seg[ln-1] == pad {
+			ln--
+		}
+		*str = string(seg[:ln])
+	}
+}
+
+// VarBytes extracts a byte slice that was stored with KeyBuffer.VarBytes from
+// the receiving key buffer.
+func (kg *KeyGetBuffer) VarBytes(sl *[]byte) {
+	if kg.err == nil {
+		var val []byte
+		for pos := 0; pos < len(kg.buf); pos++ {
+			b := kg.buf[pos]
+			if b == 0 {
+				if pos+1 < len(kg.buf) && kg.buf[pos+1] == 0xff {
+					pos++
+				} else {
+					*sl = val
+					kg.buf = kg.buf[pos+1:]
+					return
+				}
+			}
+			val = append(val, b)
+		}
+		kg.err = errKeyShort
+	}
+}
+
+// VarStr extracts a string that was stored with KeyBuffer.VarStr from the
+// receiving key buffer.
+func (kg *KeyGetBuffer) VarStr(str *string) {
+	var sl []byte
+	kg.VarBytes(&sl)
+	if kg.err == nil {
+		*str = string(sl)
+	}
+}
+
+// NullsFirst extracts a presence marker that was stored with
+// KeyBuffer.NullsFirst from the receiving key buffer. If present is set to
+// true, the value of the optional field follows.
+func (kg *KeyGetBuffer) NullsFirst(present *bool) {
+	var marker uint8
+	kg.Uint8(&marker)
+	if kg.err == nil {
+		if marker > 1 {
+			kg.err = errKeyPresence
+		} else {
+			*present = marker == 1
+		}
+	}
+}
+
+// NullsLast extracts a presence marker that was stored with
+// KeyBuffer.NullsLast from the receiving key buffer.
+func (kg *KeyGetBuffer) NullsLast(present *bool) {
+	var absent bool
+	kg.NullsFirst(&absent)
+	if kg.err == nil {
+		*present = !absent
+	}
+}
+
+// SetError permits the caller to assign an error value to the key get buffer.
+// This method unconditionally overwrites the current internal error value.
+func (kg *KeyGetBuffer) SetError(err error) {
+	kg.err = err
+}
+
+// Error returns the current value for the extraction operation. This value
+// may be nil, in which case no error has occurred.
+func (kg *KeyGetBuffer) Error() error {
+	return kg.err
+}
+
+// Done is called to indicate that all get operations have been performed. If
+// no error has occurred and no content remains in the key, nil is returned,
+// otherwise an appropriate error value.
+func (kg *KeyGetBuffer) Done() error {
+	if kg.err == nil && len(kg.buf) > 0 {
+		kg.err = errKeyNonempty
+	}
+	return kg.err
+}
+
+func (put *PutBuffer) vluEncode(val uint64) {
 	if put.err == nil {
-		var hold [binary.MaxVarintLen64]byte // Holds enough septets to contain an int64
-		len := binary.PutVarint(hold[:], val)
-		_, put.err = put.buf.Write(hold[0:len])
+		if put.dry {
+			put.size += uvarintLen(val)
+			return
+		}
+		if put.buf == nil {
+			put.buf = make([]byte, 0, putBufferMin)
+		}
+		for val >= 0x80 {
+			put.buf = append(put.buf, byte(val)|0x80)
+			val >>= 7
+		}
+		put.buf = append(put.buf, byte(val))
+		if put.tee != nil {
+			put.teeOut()
+		}
 	}
 }
 
-func vlsDecode(buf *bytes.Buffer) (val int64, err error) {
-	val, err = binary.ReadVarint(buf)
+// vluDecode reads a variable-length unsigned integer from buf. If canonical
+// is true, an encoding that is longer than necessary is rejected. This is used
+// for get buffers returned by NewGetReader; see GetBuffer.uvarint for the
+// equivalent operation on a byte slice.
+func vluDecode(buf io.ByteReader, canonical bool) (val uint64, err error) {
+	var shift uint
+	for j := 0; j < binary.MaxVarintLen64; j++ {
+		var b byte
+		b, err = buf.ReadByte()
+		if err != nil {
+			if j > 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		if b < 0x80 {
+			if j == binary.MaxVarintLen64-1 && b > 1 {
+				return 0, errVarintOverflow
+			}
+			if canonical && b == 0 && j > 0 {
+				return 0, errVarintNoncanonical
+			}
+			return val | uint64(b)<<shift, nil
+		}
+		val |= uint64(b&0x7f) << shift
+		shift += 7
+	}
+	return 0, errVarintOverflow
+}
+
+// uvarintLen returns the number of bytes in the variable-length encoding of
+// val.
+func uvarintLen(val uint64) (n int) {
+	for n = 1; val >= 0x80; n++ {
+		val >>= 7
+	}
 	return
 }
 
+func (put *PutBuffer) vlsEncode(val int64) {
+	put.vluEncode(uint64(val<<1) ^ uint64(val>>63))
+}
+
+// putBufferMin is the initial capacity of a put buffer's storage, which
+// accommodates many small records without growth.
+const putBufferMin = 64
+
 // PutBuffer facilitates the packing of structures so that they can implement
 // the encoding.BinaryMarshaler interface. The zero value for a variable of
 // type PutBuffer is ready to use.
 type PutBuffer struct {
-	buf bytes.Buffer
-	err error
+	buf      []byte
+	err      error
+	dry      bool
+	size     int
+	tee      io.Writer
+	teeLen   int
+	dict     *strDict
+	compress int
+	checksum bool
+	out      []byte
+	outLen   int
 }
 
 // GetBuffer facilitates the unpacking of structures so that they can implement
 // the encoding.BinaryUnmarshaler interface.
 type GetBuffer struct {
-	buf bytes.Buffer
-	err error
+	data      []byte
+	pos       int
+	rd        *bufio.Reader
+	cr        *countingReader
+	err       error
+	alloc     Allocator
+	valueMax  uint64
+	totalMax  uint64
+	total     uint64
+	strict    bool
+	trailing  bool
+	utf8      bool
+	canonical bool
+	depth     int
+	depthMax  int
+	inflate   bool
+	verify    bool
+	loaded    []byte
+	scratch   []byte
+	dict      []string
+	field     string
+	index     int
+	ctxPos    int64
+	ctxField  string
+	errSet    bool
 }
 
 // NewGetBuffer returns an initialized buffer that can be used to extract
@@ -243,10 +922,342 @@ type GetBuffer struct {
 // PutBuffer.
 func NewGetBuffer(data []byte) (get *GetBuffer) {
 	get = new(GetBuffer)
-	_, get.err = get.buf.Write(data)
+	get.data = append(get.data, data...)
+	return
+}
+
+// NewGetReader returns an initialized buffer that extracts values directly
+// from r rather than from a byte slice, so that a large record does not need
+// to be held in memory in its entirety. The content of r, up to its end, must
+// have been generated using a PutBuffer; in particular, Done reports an error
+// if any content remains in r. Since r is read ahead in blocks, content
+// following the record cannot be read from r afterward.
+func NewGetReader(r io.Reader) *GetBuffer {
+	cr := &countingReader{r: r}
+	return &GetBuffer{rd: bufio.NewReader(cr), cr: cr}
+}
+
+// countingReader counts the bytes read from an underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(sl []byte) (n int, err error) {
+	n, err = cr.r.Read(sl)
+	cr.n += int64(n)
 	return
 }
 
+// Pos returns the number of bytes that have been unpacked from the receiving
+// get buffer. This is the offset, from the beginning of the record, of the
+// next value to be unpacked.
+func (get *GetBuffer) Pos() int64 {
+	if get.rd != nil {
+		return get.cr.n - int64(get.rd.Buffered())
+	}
+	return int64(get.pos)
+}
+
+// Remaining returns the number of bytes that remain to be unpacked from the
+// receiving get buffer, or -1 if the buffer was returned by NewGetReader and
+// the number is therefore not known.
+func (get *GetBuffer) Remaining() int {
+	if get.rd != nil {
+		return -1
+	}
+	return len(get.data) - get.pos
+}
+
+// Reset discards the content and internal error of the receiving get buffer
+// and loads it with data, as though it had been returned by NewGetBuffer. The
+// memory used for earlier content is reused, so a single get buffer can
+// unpack many records without repeated allocation. The assigned allocator is
+// retained.
+func (get *GetBuffer) Reset(data []byte) {
+	if get.loaded != nil {
+		get.data, get.loaded = get.loaded, nil
+	}
+	get.data = append(get.data[:0], data...)
+	get.pos = 0
+	get.err = nil
+	get.rd = nil
+	get.cr = nil
+	get.total = 0
+	get.field = ""
+	get.index = 0
+	get.errSet = false
+	get.load()
+}
+
+// ReadFrom implements the io.ReaderFrom interface. It discards the content and
+// internal error of the receiving get buffer, as Reset does, and loads it with
+// the content of r up to its end. The number of bytes read is returned. Any
+// error other than io.EOF encountered while reading is returned and is also
+// assigned to the internal error of the get buffer. Use NewGetReader instead
+// to unpack a large record without holding it in memory.
+func (get *GetBuffer) ReadFrom(r io.Reader) (n int64, err error) {
+	get.Reset(nil)
+	buf := bytes.NewBuffer(get.data)
+	n, err = buf.ReadFrom(r)
+	get.data = buf.Bytes()
+	get.err = err
+	if err == nil {
+		get.load()
+	}
+	return
+}
+
+// GetMark records the state of a get buffer so that unpacking can later be
+// resumed from that point. See GetBuffer.Mark.
+type GetMark struct {
+	pos   int
+	err   error
+	index int
+	total uint64
+}
+
+// Mark returns the current state of the receiving get buffer. Passing it to
+// Rewind restores that state, including the internal error. This permits a
+// decoder to attempt one interpretation of the content, for example a
+// particular record version, and to try another if the first one fails.
+func (get *GetBuffer) Mark() GetMark {
+	return GetMark{pos: int(get.Pos()), err: get.err, index: get.index, total: get.total}
+}
+
+// Rewind restores the state of the receiving get buffer that was recorded by
+// Mark. Values unpacked since then are unpacked again by subsequent calls.
+// The mark must have been obtained from the same buffer since its creation or
+// most recent Reset. Rewinding is not possible for a buffer returned by
+// NewGetReader; in that case the internal error is set.
+func (get *GetBuffer) Rewind(m GetMark) {
+	if get.rd != nil {
+		if get.err == nil {
+			get.err = errRewind
+		}
+		return
+	}
+	get.pos = m.pos
+	get.err = m.err
+	get.errSet = false
+	get.index = m.index
+	get.total = m.total
+}
+
+// uvarint unpacks a variable-length unsigned integer from the receiving get
+// buffer.
+func (get *GetBuffer) uvarint() (val uint64, err error) {
+	if get.rd != nil {
+		return vluDecode(get.rd, get.canonical)
+	}
+	sl := get.data[get.pos:]
+	val, n := binary.Uvarint(sl)
+	switch {
+	case n > 1 && get.canonical && sl[n-1] == 0:
+		err = errVarintNoncanonical
+	case n > 0:
+		get.pos += n
+	case n < 0:
+		err = errVarintOverflow
+	case len(sl) == 0:
+		err = io.EOF
+	default:
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		val = 0
+	}
+	return
+}
+
+// varint unpacks a variable-length signed integer from the receiving get
+// buffer.
+func (get *GetBuffer) varint() (val int64, err error) {
+	var u uint64
+	u, err = get.uvarint()
+	val = int64(u >> 1)
+	if u&1 != 0 {
+		val = ^val
+	}
+	return
+}
+
+// readByte unpacks a single byte from the receiving get buffer.
+func (get *GetBuffer) readByte() (byte, error) {
+	if get.rd != nil {
+		return get.rd.ReadByte()
+	}
+	if get.pos < len(get.data) {
+		get.pos++
+		return get.data[get.pos-1], nil
+	}
+	return 0, io.EOF
+}
+
+// read unpacks exactly len(sl) bytes from the receiving get buffer into sl.
+// As with io.ReadFull, io.EOF is returned only if no bytes were available.
+func (get *GetBuffer) read(sl []byte) (err error) {
+	if get.rd != nil {
+		_, err = io.ReadFull(get.rd, sl)
+		return
+	}
+	n := copy(sl, get.data[get.pos:])
+	get.pos += n
+	if n < len(sl) {
+		if n == 0 {
+			err = io.EOF
+		} else {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	return
+}
+
+// more reports whether any content remains to be unpacked from the receiving
+// get buffer.
+func (get *GetBuffer) more() bool {
+	if get.rd != nil {
+		_, err := get.rd.Peek(1)
+		if err != nil && err != io.EOF && get.err == nil {
+			get.err = err
+		}
+		return err == nil
+	}
+	return get.pos < len(get.data)
+}
+
+// SetAllocator assigns the allocator that the receiving get buffer uses for
+// the memory of unpacked byte sequences, including those unpacked by Bytes
+// and Raw. Strings do not use the allocator, since a Go string requires memory
+// of its own. A nil value restores the default of allocating from the heap.
+func (get *GetBuffer) SetAllocator(alloc Allocator) {
+	get.alloc = alloc
+}
+
+// SetStrict controls the handling of unpacked values that do not fit in the
+// type requested by Uint32, Int32, Uint16 or Int16. By default, such values
+// are silently truncated, which can mask corrupted content or a mismatch
+// between put and get calls. In strict mode, the internal error is set
+// instead.
+func (get *GetBuffer) SetStrict(strict bool) {
+	get.strict = strict
+}
+
+// SetValidateUTF8 controls whether Str verifies that unpacked strings are
+// valid UTF-8. By default it does not. When validation is enabled, the
+// internal error is set if an invalid string is encountered, and the string
+// is not assigned.
+func (get *GetBuffer) SetValidateUTF8(validate bool) {
+	get.utf8 = validate
+}
+
+// SetCanonical controls whether integers, lengths and counts must be packed
+// in their shortest form. By default, longer forms that represent the same
+// value are accepted. When canonical form is required, the internal error is
+// set if a longer form is encountered, so that each sequence of values has
+// exactly one valid byte representation. This is useful when packed records
+// are hashed or signed. PutBuffer always produces the shortest form.
+func (get *GetBuffer) SetCanonical(canonical bool) {
+	get.canonical = canonical
+}
+
+// SetAllowTrailing controls whether Done reports an error when content
+// remains after all get operations have been performed. By default it does.
+// Permitting trailing content allows a reader to unpack records written by a
+// newer version of an application that appends fields to the record, with
+// the appended fields being ignored.
+func (get *GetBuffer) SetAllowTrailing(allow bool) {
+	get.trailing = allow
+}
+
+// SetLimits assigns the maximum number of bytes that the receiving get
+// buffer will allocate for a single string or byte sequence (valueMax) and
+// for all strings and byte sequences combined (totalMax). A limit of zero
+// means no limit, which is the default. If unpacking a value would exceed
+// either limit, the internal error is set rather than allocating memory. This
+// protects an application from corrupted or malicious length prefixes that
+// would otherwise request huge allocations, which is of particular concern
+// for buffers returned by NewGetReader. Limits are retained by Reset, but
+// Reset begins a new total.
+func (get *GetBuffer) SetLimits(valueMax, totalMax uint64) {
+	get.valueMax = valueMax
+	get.totalMax = totalMax
+}
+
+// SetDepthLimit assigns the maximum number of levels of nested content that
+// can be unpacked with Sub below the receiving get buffer. Each buffer
+// returned by Sub inherits the limit, and the internal error is set, with a
+// category of ErrLimitExceeded, if Sub would exceed it. A limit of zero, the
+// default, means no limit. This bounds the work done on content with
+// deliberately deep nesting.
+func (get *GetBuffer) SetDepthLimit(depthMax int) {
+	get.depthMax = depthMax
+}
+
+// Default limits applied by SetUntrusted
+const (
+	untrustedValueMax = 1 << 20
+	untrustedTotalMax = 16 << 20
+	untrustedDepthMax = 16
+)
+
+// SetUntrusted configures the receiving get buffer for content that comes
+// from outside the application, such as data received from another
+// organization, with a single call. It enables strict mode, UTF-8 validation
+// and canonical form, disallows trailing content so that the record must be
+// read completely, and assigns limits of 1 MiB for a single string or byte
+// sequence, 16 MiB for all of them combined and 16 levels of nesting. A limit
+// that has already been assigned with SetLimits or SetDepthLimit is retained,
+// so a caller can choose different limits before calling SetUntrusted. Each
+// setting can still be changed individually afterward.
+func (get *GetBuffer) SetUntrusted() {
+	get.strict = true
+	get.utf8 = true
+	get.canonical = true
+	get.trailing = false
+	if get.valueMax == 0 {
+		get.valueMax = untrustedValueMax
+	}
+	if get.totalMax == 0 {
+		get.totalMax = untrustedTotalMax
+	}
+	if get.depthMax == 0 {
+		get.depthMax = untrustedDepthMax
+	}
+}
+
+// make returns a slice of length n to be filled with an unpacked value, or
+// nil with the internal error set if n is not acceptable.
+func (get *GetBuffer) make(n uint64) []byte {
+	if !get.reserve(n) {
+		return nil
+	}
+	if get.alloc != nil {
+		return get.alloc.Alloc(int(n))
+	}
+	return make([]byte, n)
+}
+
+// reserve applies the limits assigned with SetLimits to a value of n bytes
+// and accounts for it. It returns false, with the internal error set, if the
+// value exceeds a limit or the remaining content.
+func (get *GetBuffer) reserve(n uint64) bool {
+	switch {
+	case get.valueMax > 0 && n > get.valueMax:
+		get.err = categoryErrorf(ErrLimitExceeded, "value of %d bytes exceeds limit of %d bytes", n, get.valueMax)
+	case get.totalMax > 0 && get.total+n > get.totalMax:
+		get.err = categoryErrorf(ErrLimitExceeded, "value of %d bytes exceeds remaining allocation limit of %d bytes",
+			n, get.totalMax-get.total)
+	case get.rd == nil && n > uint64(len(get.data)-get.pos):
+		get.err = io.ErrUnexpectedEOF
+	}
+	if get.err != nil {
+		return false
+	}
+	get.total += n
+	return true
+}
+
 // Time packs the specified time.Time value into the receiving storage
 // buffer.
 func (put *PutBuffer) Time(tm time.Time) {
@@ -256,8 +1267,8 @@ func (put *PutBuffer) Time(tm time.Time) {
 // Time unpacks a time.Time value from the receiving storage buffer.
 func (get *GetBuffer) Time(tm *time.Time) {
 	var val int64
-	if get.err == nil {
-		val, get.err = vlsDecode(&get.buf)
+	if get.start() {
+		val, get.err = get.varint()
 		if get.err == nil {
 			*tm = time.Unix(val, 0)
 		}
@@ -272,8 +1283,8 @@ func (put *PutBuffer) Uint64(val uint64) {
 
 // Uint64 unpacks a uint64 value from the receiving storage buffer.
 func (get *GetBuffer) Uint64(val *uint64) {
-	if get.err == nil {
-		*val, get.err = vluDecode(&get.buf)
+	if get.start() {
+		*val, get.err = get.uvarint()
 	}
 }
 
@@ -284,8 +1295,8 @@ func (put *PutBuffer) Int64(val int64) {
 
 // Int64 unpacks an int64 value from the receiving storage buffer.
 func (get *GetBuffer) Int64(val *int64) {
-	if get.err == nil {
-		*val, get.err = vlsDecode(&get.buf)
+	if get.start() {
+		*val, get.err = get.varint()
 	}
 }
 
@@ -297,11 +1308,15 @@ func (put *PutBuffer) Uint32(val uint32) {
 
 // Uint32 unpacks a uint32 value from the receiving storage buffer.
 func (get *GetBuffer) Uint32(val *uint32) {
-	if get.err == nil {
+	if get.start() {
 		var u uint64
-		u, get.err = vluDecode(&get.buf)
+		u, get.err = get.uvarint()
 		if get.err == nil {
-			*val = uint32(u)
+			if get.strict && (u > math.MaxUint32) {
+				get.err = categoryErrorf(ErrValueRange, "unpacked value %d is out of range for uint32", u)
+			} else {
+				*val = uint32(u)
+			}
 		}
 	}
 }
@@ -314,11 +1329,15 @@ func (put *PutBuffer) Int32(val int32) {
 
 // Int32 unpacks an int32 value from the receiving storage buffer.
 func (get *GetBuffer) Int32(val *int32) {
-	if get.err == nil {
+	if get.start() {
 		var s int64
-		s, get.err = vlsDecode(&get.buf)
+		s, get.err = get.varint()
 		if get.err == nil {
-			*val = int32(s)
+			if get.strict && (s < math.MinInt32 || s > math.MaxInt32) {
+				get.err = categoryErrorf(ErrValueRange, "unpacked value %d is out of range for int32", s)
+			} else {
+				*val = int32(s)
+			}
 		}
 	}
 }
@@ -331,11 +1350,15 @@ func (put *PutBuffer) Uint16(val uint16) {
 
 // Uint16 unpacks a uint16 value from the receiving storage buffer.
 func (get *GetBuffer) Uint16(val *uint16) {
-	if get.err == nil {
+	if get.start() {
 		var u uint64
-		u, get.err = vluDecode(&get.buf)
+		u, get.err = get.uvarint()
 		if get.err == nil {
-			*val = uint16(u)
+			if get.strict && (u > math.MaxUint16) {
+				get.err = categoryErrorf(ErrValueRange, "unpacked value %d is out of range for uint16", u)
+			} else {
+				*val = uint16(u)
+			}
 		}
 	}
 }
@@ -348,11 +1371,15 @@ func (put *PutBuffer) Int16(val int16) {
 
 // Int16 unpacks an int16 value from the receiving storage buffer.
 func (get *GetBuffer) Int16(val *int16) {
-	if get.err == nil {
+	if get.start() {
 		var s int64
-		s, get.err = vlsDecode(&get.buf)
+		s, get.err = get.varint()
 		if get.err == nil {
-			*val = int16(s)
+			if get.strict && (s < math.MinInt16 || s > math.MaxInt16) {
+				get.err = categoryErrorf(ErrValueRange, "unpacked value %d is out of range for int16", s)
+			} else {
+				*val = int16(s)
+			}
 		}
 	}
 }
@@ -360,54 +1387,145 @@ func (get *GetBuffer) Int16(val *int16) {
 // Uint8 packs the specified uint8 value into the receiving storage buffer.
 func (put *PutBuffer) Uint8(val uint8) {
 	if put.err == nil {
-		put.err = put.buf.WriteByte(val)
+		if put.dry {
+			put.size++
+		} else {
+			put.buf = append(put.buf, val)
+			if put.tee != nil {
+				put.teeOut()
+			}
+		}
 	}
 }
 
 // Uint8 unpacks a uint8 value from the receiving storage buffer.
 func (get *GetBuffer) Uint8(val *uint8) {
-	if get.err == nil {
-		*val, get.err = get.buf.ReadByte()
+	if get.start() {
+		*val, get.err = get.readByte()
 	}
 }
 
 // Int8 packs the specified int8 value into the receiving storage buffer.
 func (put *PutBuffer) Int8(val int8) {
 	if put.err == nil {
-		put.err = put.buf.WriteByte(uint8(val))
+		if put.dry {
+			put.size++
+		} else {
+			put.buf = append(put.buf, uint8(val))
+			if put.tee != nil {
+				put.teeOut()
+			}
+		}
 	}
 }
 
 // Int8 unpacks an int8 value from the receiving storage buffer.
 func (get *GetBuffer) Int8(val *int8) {
-	if get.err == nil {
+	if get.start() {
 		var b uint8
-		b, get.err = get.buf.ReadByte()
+		b, get.err = get.readByte()
 		if get.err == nil {
 			*val = int8(b)
 		}
 	}
 }
 
+// Uint64Fixed packs the specified uint64 value into the receiving storage
+// buffer as eight bytes in big-endian order. Unlike Uint64, which uses a
+// variable-length encoding, the field always has the same width, so its
+// offset within a record can be computed and large values take no more than
+// eight bytes.
+func (put *PutBuffer) Uint64Fixed(val uint64) {
+	var hold [8]byte
+	binary.BigEndian.PutUint64(hold[:], val)
+	put.write(hold[:])
+}
+
+// Uint64Fixed unpacks a uint64 value that was packed with
+// PutBuffer.Uint64Fixed from the receiving storage buffer.
+func (get *GetBuffer) Uint64Fixed(val *uint64) {
+	var hold [8]byte
+	get.fill(hold[:])
+	if get.err == nil {
+		*val = binary.BigEndian.Uint64(hold[:])
+	}
+}
+
+// Uint32Fixed packs the specified uint32 value into the receiving storage
+// buffer as four bytes in big-endian order. See Uint64Fixed.
+func (put *PutBuffer) Uint32Fixed(val uint32) {
+	var hold [4]byte
+	binary.BigEndian.PutUint32(hold[:], val)
+	put.write(hold[:])
+}
+
+// Uint32Fixed unpacks a uint32 value that was packed with
+// PutBuffer.Uint32Fixed from the receiving storage buffer.
+func (get *GetBuffer) Uint32Fixed(val *uint32) {
+	var hold [4]byte
+	get.fill(hold[:])
+	if get.err == nil {
+		*val = binary.BigEndian.Uint32(hold[:])
+	}
+}
+
 // Str packs the specified string value into the receiving storage
 // buffer.
 func (put *PutBuffer) Str(str string) {
-	put.vluEncode(uint64(len(str)))
+	if put.dict != nil {
+		if idx, ok := put.dict.lookup(str); ok {
+			put.vluEncode(idx<<1 | 1)
+			return
+		}
+		put.vluEncode(uint64(len(str)) << 1)
+	} else {
+		put.vluEncode(uint64(len(str)))
+	}
 	if put.err == nil {
-		_, put.err = put.buf.Write([]byte(str[:]))
+		if put.dry {
+			put.size += len(str)
+		} else {
+			put.buf = append(put.buf, str...)
+			if put.tee != nil {
+				put.teeOut()
+			}
+		}
 	}
 }
 
 // Str unpacks a string value from the receiving storage buffer.
 func (get *GetBuffer) Str(str *string) {
-	if get.err == nil {
+	if get.start() {
 		var u uint64
-		u, get.err = vluDecode(&get.buf)
-		if get.err == nil {
-			sl := make([]byte, u)
-			_, get.err = get.buf.Read(sl)
+		u, get.err = get.uvarint()
+		if get.err == nil && get.dict != nil {
+			if u&1 == 1 {
+				get.dictStr(u>>1, str)
+				return
+			}
+			u >>= 1
+		}
+		if get.err == nil && get.reserve(u) {
+			// The bytes are converted to a string directly from the content
+			// or from scratch memory, so the only allocation is that of the
+			// string itself
+			var sl []byte
+			if get.rd == nil {
+				sl = get.data[get.pos : get.pos+int(u)]
+				get.pos += int(u)
+			} else {
+				if uint64(cap(get.scratch)) < u {
+					get.scratch = make([]byte, u)
+				}
+				sl = get.scratch[:u]
+				get.err = get.read(sl)
+			}
 			if get.err == nil {
-				*str = string(sl)
+				if get.utf8 && !utf8.Valid(sl) {
+					get.err = errInvalidUTF8
+				} else {
+					*str = string(sl)
+				}
 			}
 		}
 	}
@@ -417,22 +1535,330 @@ func (get *GetBuffer) Str(str *string) {
 func (put *PutBuffer) Bytes(sl []byte) {
 	put.vluEncode(uint64(len(sl)))
 	if put.err == nil {
-		_, put.err = put.buf.Write(sl)
+		if put.dry {
+			put.size += len(sl)
+		} else {
+			put.buf = append(put.buf, sl...)
+			if put.tee != nil {
+				put.teeOut()
+			}
+		}
 	}
 }
 
 // Bytes unpacks a byte sequence from the receiving storage buffer.
 func (get *GetBuffer) Bytes(sl *[]byte) {
-	if get.err == nil {
+	if get.start() {
 		var u uint64
-		u, get.err = vluDecode(&get.buf)
+		u, get.err = get.uvarint()
+		if get.err == nil {
+			val := get.make(u)
+			if get.err == nil {
+				get.err = get.read(val)
+				*sl = val
+			}
+		}
+	}
+}
+
+// BytesInto unpacks a byte sequence that was packed with PutBuffer.Bytes and
+// appends it to dst, returning the extended slice. If dst has sufficient
+// capacity, no memory is allocated, so a scan loop can reuse one slice for
+// many records. The second return value is the internal error of the get
+// buffer; if it is not nil, dst is returned unchanged. The per-value limit
+// assigned with SetLimits applies, but the allocator and total limit do not.
+func (get *GetBuffer) BytesInto(dst []byte) ([]byte, error) {
+	if get.start() {
+		var u uint64
+		u, get.err = get.uvarint()
+		if get.err == nil {
+			switch {
+			case get.valueMax > 0 && u > get.valueMax:
+				get.err = categoryErrorf(ErrLimitExceeded, "value of %d bytes exceeds limit of %d bytes",
+					u, get.valueMax)
+			case get.rd == nil && u > uint64(len(get.data)-get.pos):
+				get.err = io.ErrUnexpectedEOF
+			default:
+				ln := len(dst)
+				if uint64(cap(dst)-ln) < u {
+					grown := make([]byte, ln, ln+int(u))
+					copy(grown, dst)
+					dst = grown
+				}
+				get.err = get.read(dst[ln : ln+int(u)])
+				if get.err == nil {
+					return dst[:ln+int(u)], nil
+				}
+				return dst[:ln], get.context()
+			}
+		}
+	}
+	return dst, get.context()
+}
+
+// Sub unpacks a byte sequence that was packed with PutBuffer.Bytes, typically
+// the output of another put buffer, and returns a get buffer that unpacks
+// values from it. The nested content can then be unpacked later, or by
+// another goroutine, independently of the receiving buffer. Unless the
+// receiving buffer was returned by NewGetReader, the returned buffer shares
+// memory with it rather than copying the sequence. The returned buffer
+// inherits the allocator, limits, strict mode, UTF-8 validation, canonical
+// mode and nesting depth limit of the receiving buffer. If an error occurs,
+// the returned buffer holds the same error.
+func (get *GetBuffer) Sub() *GetBuffer {
+	sub := &GetBuffer{alloc: get.alloc, valueMax: get.valueMax, totalMax: get.totalMax,
+		strict: get.strict, utf8: get.utf8, canonical: get.canonical, depth: get.depth + 1,
+		depthMax: get.depthMax}
+	if get.start() {
+		var u uint64
+		if get.depthMax > 0 && get.depth >= get.depthMax {
+			get.err = categoryErrorf(ErrLimitExceeded, "nested content exceeds depth limit of %d", get.depthMax)
+		} else {
+			u, get.err = get.uvarint()
+		}
+		if get.err == nil {
+			if get.rd != nil {
+				sl := get.make(u)
+				if get.err == nil {
+					_, get.err = io.ReadFull(get.rd, sl)
+					sub.data = sl
+				}
+			} else if u > uint64(len(get.data)-get.pos) {
+				get.err = io.ErrUnexpectedEOF
+			} else {
+				end := get.pos + int(u)
+				sub.data = get.data[get.pos:end:end]
+				get.pos = end
+			}
+		}
+	}
+	sub.err = get.err
+	return sub
+}
+
+// StrMap packs the specified map of strings into the receiving storage buffer
+// as an entry count followed by alternating keys and values. The entries are
+// packed in unspecified order.
+func (put *PutBuffer) StrMap(mp map[string]string) {
+	put.vluEncode(uint64(len(mp)))
+	for k, v := range mp {
+		put.Str(k)
+		put.Str(v)
+	}
+}
+
+// Raw packs sl into the receiving storage buffer without a length prefix.
+// This permits externally framed data, such as a fixed header, a hash or an
+// existing wire message, to be embedded in a record. The reader must know the
+// length by other means; see GetBuffer.Raw.
+func (put *PutBuffer) Raw(sl []byte) {
+	put.write(sl)
+}
+
+// Raw unpacks the next n bytes, which were packed with PutBuffer.Raw or are
+// otherwise of known length, from the receiving storage buffer and assigns
+// them to dst. The memory is obtained as for Bytes, so the allocator and
+// limits of the get buffer apply.
+func (get *GetBuffer) Raw(n int, dst *[]byte) {
+	if get.start() {
+		if n < 0 {
+			get.err = categoryErrorf(ErrValueRange, "negative raw length %d", n)
+			return
+		}
+		sl := get.make(uint64(n))
+		if get.err == nil {
+			get.err = get.read(sl)
+			if get.err == nil {
+				*dst = sl
+			}
+		}
+	}
+}
+
+// PutSlot identifies a fixed-width field reserved in a put buffer by Reserve.
+type PutSlot struct {
+	pos   int
+	width int
+}
+
+// Reserve packs a fixed-width field of width bytes, initially zero, into the
+// receiving storage buffer and returns a slot with which its value can be
+// assigned later by Patch. This permits a length or count that is not known
+// until subsequent fields have been packed, such as the size of a nested
+// structure, to precede those fields in a single pass. width must be between
+// 1 and 8. The field is unpacked with GetBuffer.Reserved. A slot is
+// invalidated by Reset.
+func (put *PutBuffer) Reserve(width int) (slot PutSlot) {
+	if put.err == nil {
+		if width < 1 || width > 8 {
+			put.err = categoryErrorf(ErrValueRange, "reserved width %d is not between 1 and 8", width)
+			return
+		}
+		slot = PutSlot{pos: put.Len(), width: width}
+		var zero [8]byte
+		put.write(zero[:width])
+	}
+	return
+}
+
+// Patch assigns val to the field identified by slot, which was returned by
+// Reserve on the receiving storage buffer. The value is stored in big-endian
+// order. The internal error is set if val does not fit in the slot's width.
+// In dry run mode, Patch only checks the value.
+func (put *PutBuffer) Patch(slot PutSlot, val uint64) {
+	if put.err == nil {
+		switch {
+		case slot.width == 0 || slot.pos+slot.width > put.Len():
+			put.err = errSlot
+		case !put.dry && slot.pos+slot.width > len(put.buf):
+			// The slot was reserved in dry run mode
+			put.err = errSlot
+		case put.tee != nil && slot.pos < put.teeLen:
+			put.err = errTeeModify
+		case slot.width < 8 && val>>(8*uint(slot.width)) != 0:
+			put.err = categoryErrorf(ErrValueRange, "value %d does not fit in %d bytes", val, slot.width)
+		case !put.dry:
+			for j := slot.pos + slot.width - 1; j >= slot.pos; j-- {
+				put.buf[j] = byte(val)
+				val >>= 8
+			}
+			put.out = nil
+		}
+	}
+}
+
+// Reserved unpacks a fixed-width field of width bytes that was packed with
+// PutBuffer.Reserve and assigned with PutBuffer.Patch.
+func (get *GetBuffer) Reserved(width int, val *uint64) {
+	if get.start() {
+		if width < 1 || width > 8 {
+			get.err = categoryErrorf(ErrValueRange, "reserved width %d is not between 1 and 8", width)
+			return
+		}
+		var hold [8]byte
+		get.err = get.read(hold[:width])
+		if get.err == io.EOF {
+			get.err = io.ErrUnexpectedEOF
+		}
 		if get.err == nil {
-			*sl = make([]byte, u)
-			_, get.err = get.buf.Read(*sl)
+			var u uint64
+			for _, b := range hold[:width] {
+				u = u<<8 | uint64(b)
+			}
+			*val = u
+		}
+	}
+}
+
+// write packs sl into the receiving storage buffer without a length prefix.
+func (put *PutBuffer) write(sl []byte) {
+	if put.err == nil {
+		if put.dry {
+			put.size += len(sl)
+		} else {
+			put.buf = append(put.buf, sl...)
+			if put.tee != nil {
+				put.teeOut()
+			}
+		}
+	}
+}
+
+// fill unpacks exactly len(sl) bytes from the receiving storage buffer into
+// sl.
+func (get *GetBuffer) fill(sl []byte) {
+	if get.start() {
+		get.err = get.read(sl)
+		if get.err == io.EOF {
+			get.err = io.ErrUnexpectedEOF
+		}
+	}
+}
+
+// skip discards the next n bytes of the receiving storage buffer.
+func (get *GetBuffer) skip(n uint64) {
+	if get.start() {
+		if get.rd != nil {
+			var k int64
+			k, get.err = io.CopyN(ioutil.Discard, get.rd, int64(n))
+			if get.err == io.EOF || (get.err == nil && uint64(k) < n) {
+				get.err = io.ErrUnexpectedEOF
+			}
+		} else if uint64(len(get.data)-get.pos) < n {
+			get.err = io.ErrUnexpectedEOF
+		} else {
+			get.pos += int(n)
+		}
+	}
+}
+
+// StrMapIter unpacks a map of strings that was packed with StrMap, calling fn
+// with each entry in turn rather than materializing the map. If fn returns
+// false, the remaining entries are skipped without being converted to
+// strings.
+func (get *GetBuffer) StrMapIter(fn func(k, v string) bool) {
+	var count, u uint64
+	var k, v string
+	get.Uint64(&count)
+	more := true
+	for j := uint64(0); j < count && get.err == nil; j++ {
+		if more {
+			get.Str(&k)
+			get.Str(&v)
+			if get.err == nil {
+				more = fn(k, v)
+			}
+		} else if get.dict != nil {
+			get.Str(&k)
+			get.Str(&k)
+		} else {
+			for n := 0; n < 2; n++ {
+				get.Uint64(&u)
+				get.skip(u)
+			}
+		}
+	}
+}
+
+// SliceIter unpacks an element count and then calls fn with the index of each
+// element in turn. fn is responsible for unpacking the element's fields from
+// the receiving buffer, so a slice can be scanned without being materialized.
+// If fn returns false, iteration stops and the remaining elements are left
+// unread. Since their size is not known to the buffer, unpacking of any
+// subsequent fields is then not possible.
+func (get *GetBuffer) SliceIter(fn func(i int) bool) {
+	var count uint64
+	get.Uint64(&count)
+	for j := uint64(0); j < count && get.err == nil; j++ {
+		if !fn(int(j)) {
+			return
 		}
 	}
 }
 
+// Repeat packs the element count n and then calls fn with the index of each
+// element in turn. fn is responsible for packing the element's fields into
+// the receiving buffer. Iteration stops if an error occurs. The packed
+// sequence is unpacked with GetBuffer.Repeat or GetBuffer.SliceIter.
+func (put *PutBuffer) Repeat(n int, fn func(i int)) {
+	put.Uint64(uint64(n))
+	for j := 0; j < n && put.err == nil; j++ {
+		fn(j)
+	}
+}
+
+// Repeat unpacks an element count into count and then calls fn with the index
+// of each element in turn. fn is responsible for unpacking the element's
+// fields from the receiving buffer; since count is assigned before the first
+// call, fn can allocate storage for all elements when i is zero. Iteration
+// stops if an error occurs.
+func (get *GetBuffer) Repeat(count *uint32, fn func(i int)) {
+	get.Uint32(count)
+	for j := uint32(0); get.err == nil && j < *count; j++ {
+		fn(int(j))
+	}
+}
+
 // SetError permits the caller to assign an error value to the put buffer. In
 // some cases, this may simplify record packing by deferring the handling of an
 // error to the point at which Data() is called. This method unconditionally
@@ -453,32 +1879,273 @@ func (put PutBuffer) Error() error {
 // unconditionally overwrites the current internal error value.
 func (get *GetBuffer) SetError(err error) {
 	get.err = err
+	get.errSet = err != nil
 }
 
 // Done is called to indicate that all get operations have been performed. If
 // no error has occurred and no content remains buffered, nil is returned,
-// otherwise an appropriate error value.
+// otherwise an appropriate error value. See SetAllowTrailing for an
+// alternative to reporting remaining content.
 func (get GetBuffer) Done() error {
-	if get.err == nil {
-		if get.buf.Len() > 0 {
-			get.err = errNonempty
-		}
+	if get.err == nil && !get.trailing && get.more() {
+		return errNonempty
 	}
-	return get.err
+	return get.context()
 }
 
 // Error returns the current value for the packing or unpacking operation. This
 // value may be nil, in which case no error has occurred.
 func (get GetBuffer) Error() error {
-	return get.err
+	return get.context()
 }
 
-// Data returns the currently packed fields in the form of a byte slice. The
-// second return value is an error code that will be nil if all fields have
-// been successfully packed.
+// DecodeError describes the failure to unpack a value from a get buffer.
+// Offset is the position, in bytes from the beginning of the record, of the
+// value that could not be unpacked. Index is the ordinal, counting from
+// zero, of that value among the values unpacked from the buffer; a method
+// such as StrMapIter that unpacks several values counts each of them. Field
+// is the name most recently assigned with GetBuffer.Field, if any.
+type DecodeError struct {
+	Offset int64
+	Index  int
+	Field  string
+	Err    error
+}
+
+// Error implements the error interface.
+func (e *DecodeError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("unpacking value %d (%s) at offset %d: %s", e.Index, e.Field, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("unpacking value %d at offset %d: %s", e.Index, e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error belongs to the category target. This permits
+// the io.EOF and io.ErrUnexpectedEOF errors that occur when content ends
+// prematurely to be matched with ErrShortBuffer.
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrShortBuffer && (e.Err == io.EOF || e.Err == io.ErrUnexpectedEOF)
+}
+
+// Field assigns a name that identifies the values unpacked by subsequent
+// method calls, for example the name of the structure field that is about to
+// be unpacked. If unpacking fails, the name is included in the error. Naming
+// fields is optional.
+func (get *GetBuffer) Field(name string) {
+	get.field = name
+}
+
+// start is called at the beginning of each operation that unpacks a value. If
+// no error has occurred, it records the context of the operation for use in
+// an error report and returns true.
+func (get *GetBuffer) start() bool {
+	if get.err == nil {
+		get.ctxPos = get.Pos()
+		get.ctxField = get.field
+		get.index++
+		return true
+	}
+	return false
+}
+
+// context returns the internal error, if any, wrapped in a DecodeError
+// describing the failing operation. Errors assigned with SetError are
+// returned as they are.
+func (get *GetBuffer) context() error {
+	if get.err == nil || get.errSet || get.index == 0 {
+		return get.err
+	}
+	return &DecodeError{Offset: get.ctxPos, Index: get.index - 1, Field: get.ctxField, Err: get.err}
+}
+
+// Len returns the number of bytes that have been packed into the receiving
+// storage buffer. This includes bytes counted but not stored in dry run mode.
+func (put *PutBuffer) Len() int {
+	return len(put.buf) + put.size
+}
+
+// WriteTo implements the io.WriterTo interface. It writes the currently packed
+// fields to w and returns the number of bytes written. The packed fields are
+// retained, so Data and WriteTo may be called again afterward. If an error has
+// occurred during packing, nothing is written and that error is returned.
+func (put *PutBuffer) WriteTo(w io.Writer) (n int64, err error) {
+	if put.err != nil {
+		return 0, put.err
+	}
+	var count int
+	out := put.output()
+	count, err = w.Write(out)
+	n = int64(count)
+	if err == nil && count != len(out) {
+		err = io.ErrShortWrite
+	}
+	return
+}
+
+// AppendTo appends the currently packed fields to dst and returns the
+// extended slice. Unlike Data, the result does not refer to memory owned by
+// the receiving storage buffer, so dst can be reused across records without
+// allocation. If an error has occurred, dst is returned unchanged along with
+// the error.
+func (put *PutBuffer) AppendTo(dst []byte) ([]byte, error) {
+	if put.err == nil {
+		return append(dst, put.output()...), nil
+	}
+	return dst, put.err
+}
+
+// Reset discards the packed fields and the internal error of the receiving
+// storage buffer so that it can be used to pack another record. The memory
+// that holds the fields is retained for reuse, so a slice previously returned
+// by DataUnsafe is overwritten by subsequent packing.
+func (put *PutBuffer) Reset() {
+	put.buf = put.buf[:0]
+	put.err = nil
+	put.size = 0
+	put.teeLen = 0
+	put.out = nil
+}
+
+// SetTee assigns a writer, typically a hash.Hash, to which the receiving
+// storage buffer copies bytes as they are packed, so that a digest of the
+// record can be computed during packing rather than in a second pass over the
+// result of Data. Any bytes already packed are written to w immediately. An
+// error returned by w becomes the internal error of the put buffer. Since
+// bytes are copied as they are packed, a field packed with Reserve cannot be
+// patched and a rollback cannot discard copied bytes while a writer is
+// assigned; attempting either sets the internal error. Nothing is copied in
+// dry run mode. The writer is retained by Reset, so it must be reset by the
+// caller between records if appropriate. A nil value removes the writer.
+func (put *PutBuffer) SetTee(w io.Writer) {
+	put.tee = w
+	put.teeLen = 0
+	if w != nil {
+		put.teeOut()
+	}
+}
+
+// teeOut copies newly packed bytes to the writer assigned with SetTee.
+func (put *PutBuffer) teeOut() {
+	if put.err == nil {
+		_, put.err = put.tee.Write(put.buf[put.teeLen:])
+		put.teeLen = len(put.buf)
+	}
+}
+
+// Clone returns a new storage buffer that holds a copy of the values packed
+// into the receiving buffer, along with its internal error, dry run mode,
+// envelope settings and, for a record of a BatchWriter, string dictionary.
+// Subsequent packing into either buffer does not affect the other, so a
+// common record prefix can be packed once and then completed in different
+// ways. The clone has no tee writer; see SetTee.
+func (put *PutBuffer) Clone() *PutBuffer {
+	sl := make([]byte, len(put.buf), cap(put.buf))
+	copy(sl, put.buf)
+	return &PutBuffer{buf: sl, err: put.err, dry: put.dry, size: put.size, compress: put.compress,
+		checksum: put.checksum, dict: put.dict}
+}
+
+// PutMark records the state of a put buffer so that packing can later be
+// resumed from that point. See PutBuffer.Mark.
+type PutMark struct {
+	len  int
+	size int
+	err  error
+}
+
+// Mark returns the current state of the receiving storage buffer. Passing it
+// to Rollback discards the values packed since then and restores the internal
+// error. This permits an optional section of a record to be abandoned, for
+// example when a nested value fails to pack or turns out to be empty, without
+// packing the whole record again.
+func (put *PutBuffer) Mark() PutMark {
+	return PutMark{len: len(put.buf), size: put.size, err: put.err}
+}
+
+// Rollback restores the state of the receiving storage buffer that was
+// recorded by Mark. The mark must have been obtained from the same buffer
+// since its creation or most recent Reset; otherwise the internal error is
+// set. Slots returned by Reserve after the mark was obtained are invalidated.
+func (put *PutBuffer) Rollback(m PutMark) {
+	if m.len > len(put.buf) || m.size > put.size {
+		if put.err == nil {
+			put.err = errPutMark
+		}
+		return
+	}
+	if put.tee != nil && m.len < put.teeLen {
+		if put.err == nil {
+			put.err = errTeeModify
+		}
+		return
+	}
+	put.buf = put.buf[:m.len]
+	put.size = m.size
+	put.err = m.err
+	put.out = nil
+}
+
+// SetDryRun controls whether the receiving storage buffer operates in dry run
+// mode. In this mode, put methods count the bytes they would pack and report
+// errors as usual, but nothing is stored; Len reports the count and Data
+// returns only what was packed before the mode was entered. This permits an
+// application to determine the exact size of a record before packing it, for
+// example to allocate an output buffer or to enforce a size quota. The mode is
+// retained by Reset.
+func (put *PutBuffer) SetDryRun(dry bool) {
+	put.dry = dry
+}
+
+// Size returns the number of bytes that enc packs, as determined by calling
+// it with a put buffer in dry run mode. The error, if not nil, is the put
+// buffer's internal error after enc returns.
+func Size(enc func(put *PutBuffer)) (int, error) {
+	var put PutBuffer
+	put.SetDryRun(true)
+	enc(&put)
+	return put.size, put.err
+}
+
+// Grow ensures that at least n more bytes can be packed into the receiving
+// storage buffer without another allocation. Calling Grow with the expected
+// size of a record before packing it avoids repeated growth of the buffer.
+func (put *PutBuffer) Grow(n int) {
+	if n > 0 {
+		if cap(put.buf)-len(put.buf) < n {
+			grown := make([]byte, len(put.buf), len(put.buf)+n)
+			copy(grown, put.buf)
+			put.buf = grown
+		}
+	}
+}
+
+// Data returns a copy of the currently packed fields in the form of a byte
+// slice. The second return value is an error code that will be nil if all
+// fields have been successfully packed. The returned slice belongs to the
+// caller and remains valid after the put buffer is reset, released or reused.
 func (put *PutBuffer) Data() ([]byte, error) {
 	if put.err == nil {
-		return put.buf.Bytes(), nil
+		return append([]byte(nil), put.output()...), nil
+	}
+	return nil, put.err
+}
+
+// DataUnsafe is like Data but returns a slice that refers to the memory of the
+// receiving storage buffer rather than a copy, avoiding an allocation. If
+// compression or a checksum is enabled, the envelope that holds the fields is
+// allocated the first time it is retrieved after the buffer is modified, and
+// retained for later calls. The slice remains valid only until the next call
+// to Reset, Rollback or Patch, or until the buffer is released with
+// ReleasePut. Packing further values does not alter the bytes of a previously
+// returned slice.
+func (put *PutBuffer) DataUnsafe() ([]byte, error) {
+	if put.err == nil {
+		return put.output(), nil
 	}
 	return nil, put.err
 }
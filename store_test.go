@@ -23,10 +23,16 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
+	"math/rand"
 	"os"
 	"sort"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
+
+	"github.com/piniondb/store/storetest"
 )
 
 var (
@@ -115,7 +121,12 @@ func (r all) String() string {
 // from the store package.
 func storeRecToBuf(rec all) ([]byte, error) {
 	var put PutBuffer
-	// Pack structure into buffer
+	storePack(&put, rec)
+	return put.Data()
+}
+
+// storePack packs all record fields into put.
+func storePack(put *PutBuffer, rec all) {
 	put.Uint64(rec.U64)
 	put.Int64(rec.S64)
 	put.Uint32(rec.U32)
@@ -137,7 +148,6 @@ func storeRecToBuf(rec all) ([]byte, error) {
 		put.Str(k)
 		put.Str(v)
 	}
-	return put.Data()
 }
 
 // storeBufToRec unpacks all record fields from a byte slice using a get buffer
@@ -278,6 +288,118 @@ func ExampleGetBuffer() {
 	// Original structure is the same as the restored structure
 }
 
+// ExampleGetBuffer_StrMapIter demonstrates scanning map and slice fields
+// without materializing them.
+func ExampleGetBuffer_StrMapIter() {
+	var put PutBuffer
+	put.StrMap(map[string]string{"key1": "value1", "key2": "value2", "key3": "value3"})
+	put.Uint16(3)
+	for _, val := range []uint64{123, 345, 567} {
+		put.Uint64(val)
+	}
+	put.Str("trailer")
+	data, err := put.Data()
+	if err == nil {
+		var found string
+		var sum uint64
+		var trailer string
+		get := NewGetBuffer(data)
+		get.StrMapIter(func(k, v string) bool {
+			if k == "key2" {
+				found = v
+			}
+			return found == ""
+		})
+		get.SliceIter(func(i int) bool {
+			var val uint64
+			get.Uint64(&val)
+			sum += val
+			return true
+		})
+		get.Str(&trailer)
+		err = get.Done()
+		if err == nil {
+			fmt.Println(found, sum, trailer)
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// value2 1035 trailer
+}
+
+// ExampleGetBuffer_Sub demonstrates the deferred unpacking of a nested
+// record.
+func ExampleGetBuffer_Sub() {
+	var inner, outer PutBuffer
+	inner.Str("nested")
+	inner.Uint32(99)
+	data, err := inner.Data()
+	if err == nil {
+		outer.Uint8(1)
+		outer.Bytes(data)
+		outer.Str("after")
+		data, err = outer.Data()
+	}
+	if err == nil {
+		var version uint8
+		var after, name string
+		var val uint32
+		get := NewGetBuffer(data)
+		get.Uint8(&version)
+		sub := get.Sub()
+		get.Str(&after)
+		err = get.Done()
+		if err == nil {
+			sub.Str(&name)
+			sub.Uint32(&val)
+			err = sub.Done()
+			if err == nil {
+				fmt.Println(version, after, name, val)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// 1 after nested 99
+}
+
+// ExampleGetBuffer_Repeat demonstrates packing and unpacking a slice of
+// structures.
+func ExampleGetBuffer_Repeat() {
+	var put PutBuffer
+	list := []sub{{U64: 123, S8: 2}, {U64: 345, S8: 5}, {U64: 567, S8: -8}}
+	put.Repeat(len(list), func(i int) {
+		put.Uint64(list[i].U64)
+		put.Int8(list[i].S8)
+	})
+	data, err := put.Data()
+	if err == nil {
+		var restored []sub
+		var count uint32
+		get := NewGetBuffer(data)
+		get.Repeat(&count, func(i int) {
+			if i == 0 {
+				restored = make([]sub, count)
+			}
+			get.Uint64(&restored[i].U64)
+			get.Int8(&restored[i].S8)
+		})
+		err = get.Done()
+		if err == nil {
+			fmt.Println(count, restored)
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// 3 [{123 2} {345 5} {567 -8}]
+}
+
 // Write a hexadecimal representation of the byte slice to the specified writer.
 func out(w io.Writer, sl []byte) {
 	slen := len(sl)
@@ -320,6 +442,116 @@ func ExampleKeyBuffer_build() {
 	// 03 04 01 02 00 00
 }
 
+// ExampleKeyGetBuffer demonstrates the extraction of fields from a key that
+// was built with a KeyBuffer.
+func ExampleKeyGetBuffer() {
+	var kb KeyBuffer
+	kb.Time(timeTest)
+	kb.Int32(-50129)
+	kb.Str("example", 10)
+	kb.VarStr("nul\x00byte")
+	kb.Uint8(212)
+	key, err := kb.Data()
+	if err == nil {
+		var tm time.Time
+		var s32 int32
+		var str, varStr string
+		var u8 uint8
+		kg := NewKeyGetBuffer(key)
+		kg.Time(&tm)
+		kg.Int32(&s32)
+		kg.Str(&str, 10)
+		kg.VarStr(&varStr)
+		kg.Uint8(&u8)
+		err = kg.Done()
+		if err == nil {
+			fmt.Printf("%s, %d, [%s], %q, %d\n", tm.UTC(), s32, str, varStr, u8)
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// 1997-11-28 12:00:00 +0000 UTC, -50129, [example], "nul\x00byte", 212
+}
+
+// Ensure that reading past the end of a key is reported
+func TestKeyGetBuffer_Short(t *testing.T) {
+	var v uint64
+	kg := NewKeyGetBuffer(KeyUint32(7))
+	kg.Uint64(&v)
+	if kg.Error() == nil {
+		t.Fatal("KeyGetBuffer did not report short key")
+	}
+	var str string
+	kg = NewKeyGetBuffer([]byte{'a', 0, 0xff})
+	kg.VarStr(&str)
+	if kg.Done() == nil {
+		t.Fatal("KeyGetBuffer did not report unterminated segment")
+	}
+	kg = NewKeyGetBuffer(KeyUint32(7))
+	kg.Uint16(new(uint16))
+	if kg.Done() == nil {
+		t.Fatal("Remaining key content not reported")
+	}
+}
+
+// Ensure that prefix successors handle carry and overflow
+func TestPrefixSuccessor(t *testing.T) {
+	for _, tc := range []struct {
+		prefix, next []byte
+	}{
+		{[]byte{1, 2, 3}, []byte{1, 2, 4}},
+		{[]byte{1, 2, 0xff}, []byte{1, 3}},
+		{[]byte{1, 0xff, 0xff}, []byte{2}},
+		{[]byte{0xff, 0xff}, nil},
+		{nil, nil},
+	} {
+		next := PrefixSuccessor(tc.prefix)
+		if !bytes.Equal(next, tc.next) || (next == nil) != (tc.next == nil) {
+			t.Fatalf("successor of %x: expecting %x, got %x", tc.prefix, tc.next, next)
+		}
+	}
+	var kb KeyBuffer
+	kb.Uint16(0x12ff)
+	end, err := kb.RangeEnd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(end, []byte{0x13}) {
+		t.Fatalf("unexpected range end %x", end)
+	}
+	if sl, _ := kb.Data(); !bytes.Equal(sl, []byte{0x12, 0xff}) {
+		t.Fatal("RangeEnd modified key buffer content")
+	}
+}
+
+// Ensure that keys are split into segments of the specified widths
+func TestSplitKey(t *testing.T) {
+	var kb KeyBuffer
+	kb.Uint16(7)
+	kb.VarStr("a\x00b")
+	kb.Str("xy", 3)
+	key, err := kb.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := SplitKey(key, 2, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || !bytes.Equal(list[0], []byte{0, 7}) ||
+		!bytes.Equal(list[1], []byte{'a', 0, 0xff, 'b', 0}) || string(list[2]) != "xy " {
+		t.Fatalf("unexpected segments %q", list)
+	}
+	if _, err = SplitKey(key, 2, 0); err == nil {
+		t.Fatal("excess key content not reported")
+	}
+	if _, err = SplitKey(key, 2, 0, 4); err == nil {
+		t.Fatal("short key not reported")
+	}
+}
+
 // type simple includes a few elementary types
 type simple struct {
 	a int64
@@ -528,14 +760,1246 @@ func TestGetBuffer_Leftover(t *testing.T) {
 	}
 }
 
-// Ensure that error in key buffer loading is reported
-func TestKeyBuffer_Error(t *testing.T) {
+// Ensure that records can be unpacked directly from a reader
+func TestNewGetReader(t *testing.T) {
+	var rec all
+	recPopulate(&rec)
+	data, err := storeRecToBuf(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var put PutBuffer
+	put.Bytes(data)
+	put.StrMap(rec.Mp)
+	put.Uint8(7)
+	data, err = put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sl []byte
+	var u8 uint8
+	get := NewGetReader(iotest.OneByteReader(bytes.NewReader(data)))
+	get.Bytes(&sl)
+	get.StrMapIter(func(k, v string) bool { return false })
+	get.Uint8(&u8)
+	if err = get.Done(); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := storeBufToRec(sl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.String() != rec.String() || u8 != 7 {
+		t.Fatal("record not restored from reader")
+	}
+	get = NewGetReader(bytes.NewReader(data))
+	get.Bytes(&sl)
+	if get.Done() == nil {
+		t.Fatal("remaining reader content not reported")
+	}
+	get = NewGetReader(bytes.NewReader(data[:20]))
+	get.Bytes(&sl)
+	if get.Error() == nil {
+		t.Fatal("truncated reader content not reported")
+	}
+}
+
+// Ensure that a get buffer can be reused without allocation
+func TestGetBuffer_Reset(t *testing.T) {
+	var put PutBuffer
+	put.Uint32(12345)
+	put.Int8(-3)
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := NewGetBuffer(nil)
+	get.Uint8(new(uint8))
+	if get.Error() == nil {
+		t.Fatal("empty buffer not reported")
+	}
+	var u32 uint32
+	var s8 int8
+	allocs := testing.AllocsPerRun(100, func() {
+		get.Reset(data)
+		get.Uint32(&u32)
+		get.Int8(&s8)
+		err = get.Done()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u32 != 12345 || s8 != -3 {
+		t.Fatalf("unexpected values %d, %d", u32, s8)
+	}
+	if allocs > 0 {
+		t.Fatalf("expecting no allocations, got %.1f", allocs)
+	}
+}
+
+// Ensure that allocation limits and implausible lengths are reported
+func TestGetBuffer_SetLimits(t *testing.T) {
+	var put PutBuffer
+	put.Str("abcdef")
+	put.Bytes([]byte{1, 2, 3, 4})
+	put.Str("gh")
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var str string
+	var sl []byte
+	get := NewGetBuffer(data)
+	get.SetLimits(6, 12)
+	get.Str(&str)
+	get.Bytes(&sl)
+	get.Str(&str)
+	if err = get.Done(); err != nil {
+		t.Fatal(err)
+	}
+	get.Reset(data)
+	get.SetLimits(5, 0)
+	get.Str(&str)
+	if get.Error() == nil {
+		t.Fatal("value limit not enforced")
+	}
+	get.Reset(data)
+	get.SetLimits(0, 11)
+	get.Str(&str)
+	get.Bytes(&sl)
+	get.Str(&str)
+	if get.Error() == nil {
+		t.Fatal("total limit not enforced")
+	}
+	// Length prefix claims far more content than the buffer holds
+	get = NewGetBuffer([]byte{0xff, 0xff, 0xff, 0xff, 0x0f, 'a'})
+	get.Bytes(&sl)
+	if get.Error() == nil {
+		t.Fatal("implausible length not reported")
+	}
+}
+
+// Ensure that out-of-range values are reported in strict mode
+func TestGetBuffer_Strict(t *testing.T) {
+	var put PutBuffer
+	put.Uint64(math.MaxUint16 + 1)
+	put.Int64(math.MinInt32 - 1)
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var u16 uint16
+	var s32 int32
+	get := NewGetBuffer(data)
+	get.Uint16(&u16)
+	get.Int32(&s32)
+	if err = get.Done(); err != nil {
+		t.Fatal("GetBuffer reported error for truncated value by default")
+	}
+	get.Reset(data)
+	get.SetStrict(true)
+	get.Uint16(&u16)
+	if get.Error() == nil {
+		t.Fatal("out-of-range uint16 not reported in strict mode")
+	}
+	get.Reset(data)
+	get.Uint32(new(uint32))
+	get.Int32(&s32)
+	if get.Error() == nil {
+		t.Fatal("out-of-range int32 not reported in strict mode")
+	}
+	get.Reset(data)
+	get.Uint32(new(uint32))
+	get.Int64(new(int64))
+	if err = get.Done(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that buffer positions are reported for both kinds of source
+func TestGetBuffer_Pos(t *testing.T) {
+	var put PutBuffer
+	put.Uint16(300)
+	if put.Len() != 2 {
+		t.Fatalf("expecting length 2, got %d", put.Len())
+	}
+	put.Str("abc")
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, get := range []*GetBuffer{NewGetBuffer(data), NewGetReader(bytes.NewReader(data))} {
+		var u16 uint16
+		get.Uint16(&u16)
+		if get.Pos() != 2 {
+			t.Fatalf("expecting position 2, got %d", get.Pos())
+		}
+		get.Str(new(string))
+		if get.Pos() != int64(len(data)) {
+			t.Fatalf("expecting position %d, got %d", len(data), get.Pos())
+		}
+	}
+	get := NewGetBuffer(data)
+	get.Uint16(new(uint16))
+	if get.Remaining() != 4 {
+		t.Fatalf("expecting 4 bytes remaining, got %d", get.Remaining())
+	}
+}
+
+// Ensure that unpacking errors identify the failing value
+func TestGetBuffer_DecodeError(t *testing.T) {
+	var put PutBuffer
+	put.Uint32(7)
+	put.Str("someone@example.com")
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var str string
+	get := NewGetBuffer(data[:len(data)-2])
+	get.Field("id")
+	get.Uint32(new(uint32))
+	get.Field("email")
+	get.Str(&str)
+	get.Field("name")
+	get.Str(&str)
+	err = get.Done()
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("expecting DecodeError, got %v", err)
+	}
+	if de.Offset != 1 || de.Index != 1 || de.Field != "email" {
+		t.Fatalf("unexpected error context: %s", err)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("underlying error not available: %s", err)
+	}
+	get = NewGetBuffer(data)
+	get.SetError(errTest)
+	if get.Done() != errTest {
+		t.Fatal("assigned error was altered")
+	}
+}
+
+// Ensure that errors can be matched with the exported categories
+func TestErrorCategories(t *testing.T) {
+	var put PutBuffer
+	put.Uint32(70000)
+	put.Str("abc")
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := NewGetBuffer(data[:3])
+	get.Uint32(new(uint32))
+	get.Str(new(string))
+	if err = get.Done(); !errors.Is(err, ErrShortBuffer) {
+		t.Fatalf("expecting short buffer error, got %v", err)
+	}
+	get = NewGetBuffer(data)
+	get.Uint32(new(uint32))
+	if err = get.Done(); !errors.Is(err, ErrLeftover) {
+		t.Fatalf("expecting leftover error, got %v", err)
+	}
+	get = NewGetBuffer(data)
+	get.SetStrict(true)
+	get.Uint16(new(uint16))
+	if err = get.Done(); !errors.Is(err, ErrValueRange) {
+		t.Fatalf("expecting range error, got %v", err)
+	}
+	get = NewGetBuffer(data)
+	get.SetLimits(2, 0)
+	get.Uint32(new(uint32))
+	get.Str(new(string))
+	if err = get.Done(); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expecting limit error, got %v", err)
+	}
+	kg := NewKeyGetBuffer([]byte{1})
+	kg.Uint16(new(uint16))
+	if err = kg.Done(); !errors.Is(err, ErrShortBuffer) {
+		t.Fatalf("expecting short buffer error, got %v", err)
+	}
 	var kb KeyBuffer
-	kb.Uint32(3)
-	kb.SetError(errTest)
-	sl, err := kb.Data()
-	if sl != nil || err == nil {
-		t.Fatal("KeyBuffer error not reported")
+	kb.SetStrict(true)
+	kb.Str("abc", 2)
+	if _, err = kb.Data(); !errors.Is(err, ErrValueRange) {
+		t.Fatalf("expecting range error, got %v", err)
+	}
+}
+
+// Ensure that trailing content is ignored when permitted
+func TestGetBuffer_SetAllowTrailing(t *testing.T) {
+	var put PutBuffer
+	put.Str("v1 field")
+	put.Uint32(2)
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := NewGetBuffer(data)
+	get.SetAllowTrailing(true)
+	get.Str(new(string))
+	if err = get.Done(); err != nil {
+		t.Fatal(err)
+	}
+	get.SetAllowTrailing(false)
+	if get.Done() == nil {
+		t.Fatal("trailing content not reported")
+	}
+}
+
+// Ensure that byte sequences are appended to a reused slice
+func TestGetBuffer_BytesInto(t *testing.T) {
+	var put PutBuffer
+	put.Bytes([]byte("abc"))
+	put.Bytes([]byte("defgh"))
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := NewGetBuffer(data)
+	dst, err := get.BytesInto([]byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err = get.BytesInto(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(dst) != "xabcdefgh" {
+		t.Fatalf("unexpected content %q", dst)
+	}
+	buf := make([]byte, 0, 16)
+	allocs := testing.AllocsPerRun(100, func() {
+		get.Reset(data)
+		buf, err = get.BytesInto(buf[:0])
+		buf, err = get.BytesInto(buf[:0])
+	})
+	if err != nil || string(buf) != "defgh" {
+		t.Fatalf("unexpected result %q, %v", buf, err)
+	}
+	if allocs > 0 {
+		t.Fatalf("expecting no allocations, got %.1f", allocs)
+	}
+	get.Reset(data[:3])
+	if dst, err = get.BytesInto(dst[:1]); err == nil || string(dst) != "x" {
+		t.Fatal("short content not reported")
+	}
+}
+
+// Ensure that a failed interpretation can be rolled back and retried
+func TestGetBuffer_Rewind(t *testing.T) {
+	var put PutBuffer
+	put.Str("name")
+	put.Uint32(70000)
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var str string
+	var u32 uint32
+	get := NewGetBuffer(data)
+	get.SetStrict(true)
+	mark := get.Mark()
+	get.Str(&str)
+	get.Uint16(new(uint16))
+	if get.Error() == nil {
+		t.Fatal("out-of-range value not reported")
+	}
+	get.Rewind(mark)
+	get.Str(&str)
+	get.Uint32(&u32)
+	if err = get.Done(); err != nil {
+		t.Fatal(err)
+	}
+	if str != "name" || u32 != 70000 {
+		t.Fatalf("unexpected values %q, %d", str, u32)
+	}
+	get = NewGetReader(bytes.NewReader(data))
+	get.Rewind(get.Mark())
+	if get.Error() == nil {
+		t.Fatal("rewinding reader not reported")
+	}
+}
+
+// Ensure that invalid UTF-8 is reported when validation is enabled
+func TestGetBuffer_SetValidateUTF8(t *testing.T) {
+	var put PutBuffer
+	put.Str("héllo")
+	put.Str("bad\xff")
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a, b string
+	get := NewGetBuffer(data)
+	get.Str(&a)
+	get.Str(&b)
+	if err = get.Done(); err != nil {
+		t.Fatal(err)
+	}
+	get.Reset(data)
+	get.SetValidateUTF8(true)
+	get.Str(&a)
+	if err = get.Error(); err != nil {
+		t.Fatal(err)
+	}
+	b = ""
+	get.Str(&b)
+	if get.Error() == nil || b != "" {
+		t.Fatal("invalid UTF-8 not reported")
+	}
+	put.Reset()
+	put.Bytes(data)
+	nested, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get.Reset(nested)
+	sub := get.Sub()
+	sub.Str(&a)
+	sub.Str(&b)
+	if sub.Error() == nil {
+		t.Fatal("invalid UTF-8 in nested record not reported")
+	}
+}
+
+// Ensure that nested content deeper than the depth limit is rejected
+func TestGetBuffer_SetDepthLimit(t *testing.T) {
+	nest := func(levels int) []byte {
+		data := []byte{}
+		for j := 0; j < levels; j++ {
+			var put PutBuffer
+			put.Bytes(data)
+			data, _ = put.Data()
+		}
+		return data
+	}
+	for _, levels := range []int{3, 4} {
+		get := NewGetBuffer(nest(levels))
+		get.SetDepthLimit(3)
+		sub := get
+		for j := 0; j < levels; j++ {
+			sub = sub.Sub()
+		}
+		err := sub.Done()
+		if levels == 3 && err != nil {
+			t.Fatalf("unexpected error at depth %d: %v", levels, err)
+		}
+		if levels == 4 && !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("expecting depth limit error, got %v", err)
+		}
+	}
+}
+
+// Ensure that the untrusted preset enables each of its checks
+func TestGetBuffer_SetUntrusted(t *testing.T) {
+	var put PutBuffer
+	put.Bytes(make([]byte, untrustedValueMax+1))
+	big, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{
+		{0x85, 0x00},
+		{0x80, 0x80, 0x04},
+		{1, 2},
+		big,
+	} {
+		var val uint16
+		var sl []byte
+		get := NewGetBuffer(data)
+		get.SetAllowTrailing(true)
+		get.SetUntrusted()
+		if len(data) == len(big) {
+			get.Bytes(&sl)
+		} else {
+			get.Uint16(&val)
+		}
+		if get.Done() == nil {
+			t.Fatalf("expecting error for %x", data[:2])
+		}
+	}
+	var str string
+	get := NewGetBuffer([]byte{2, 0xff, 0xfe})
+	get.SetUntrusted()
+	get.Str(&str)
+	if get.Done() == nil {
+		t.Fatal("invalid UTF-8 not reported")
+	}
+	get = NewGetBuffer(big)
+	get.SetLimits(0, 1<<30)
+	get.SetDepthLimit(2)
+	get.SetUntrusted()
+	if get.valueMax != untrustedValueMax || get.totalMax != 1<<30 || get.depthMax != 2 {
+		t.Fatal("assigned limits not retained")
+	}
+}
+
+// Ensure that non-minimal varints are rejected only in canonical mode
+func TestGetBuffer_SetCanonical(t *testing.T) {
+	data := []byte{0x85, 0x00, 0x81, 0x80, 0x00}
+	var a uint32
+	var b int64
+	get := NewGetBuffer(data)
+	get.Uint32(&a)
+	get.Int64(&b)
+	if err := get.Done(); err != nil || a != 5 || b != -1 {
+		t.Fatalf("non-minimal varints not accepted: %v", err)
+	}
+	get.Reset(data)
+	get.SetCanonical(true)
+	get.Uint32(&a)
+	if err := get.Error(); !errors.Is(err, ErrValueRange) {
+		t.Fatalf("non-minimal varint not reported: %v", err)
+	}
+	var put PutBuffer
+	put.Uint64(math.MaxUint64)
+	put.Int64(math.MinInt64)
+	put.Uint32(0)
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c uint64
+	var d int64
+	get.Reset(data)
+	get.Uint64(&c)
+	get.Int64(&d)
+	get.Uint32(&a)
+	if err = get.Done(); err != nil || c != math.MaxUint64 || d != math.MinInt64 || a != 0 {
+		t.Fatalf("canonical varints not unpacked: %v", err)
+	}
+	get.Reset(bytes.Repeat([]byte{0xff}, 10))
+	get.Uint64(&c)
+	if get.Error() == nil {
+		t.Fatal("varint overflow not reported")
+	}
+	put.Reset()
+	put.Bytes([]byte{0x85, 0x00})
+	if data, err = put.Data(); err != nil {
+		t.Fatal(err)
+	}
+	get.Reset(data)
+	sub := get.Sub()
+	sub.Uint32(&a)
+	if err = sub.Error(); !errors.Is(err, ErrValueRange) {
+		t.Fatalf("non-minimal varint in nested record not reported: %v", err)
+	}
+}
+
+// Ensure that error in key buffer loading is reported
+func TestKeyBuffer_Error(t *testing.T) {
+	var kb KeyBuffer
+	kb.Uint32(3)
+	kb.SetError(errTest)
+	sl, err := kb.Data()
+	if sl != nil || err == nil {
+		t.Fatal("KeyBuffer error not reported")
+	}
+}
+
+// Ensure that overlong fields are reported in strict mode
+func TestKeyBuffer_Strict(t *testing.T) {
+	var kb KeyBuffer
+	kb.Str("example", 4)
+	if _, err := kb.Data(); err != nil {
+		t.Fatal("KeyBuffer reported error for truncated string by default")
+	}
+	kb = KeyBuffer{}
+	kb.SetStrict(true)
+	kb.Str("exam", 4)
+	kb.Bytes([]byte{1, 2}, 2)
+	if _, err := kb.Data(); err != nil {
+		t.Fatal(err)
+	}
+	kb.Str("example", 4)
+	if _, err := kb.Data(); err == nil {
+		t.Fatal("KeyBuffer did not report overlong string in strict mode")
+	}
+	kb = KeyBuffer{}
+	kb.SetStrict(true)
+	kb.Bytes([]byte{1, 2, 3}, 2)
+	if _, err := kb.Data(); err == nil {
+		t.Fatal("KeyBuffer did not report overlong byte slice in strict mode")
+	}
+}
+
+// Ensure that the pad byte can be assigned per buffer and per field
+func TestKeyBuffer_Pad(t *testing.T) {
+	var kb KeyBuffer
+	kb.SetPad(0)
+	kb.Str("ab", 4)
+	kb.StrPad("cd", 3, '.')
+	kb.Str("ef", 3)
+	sl, err := kb.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sl) != "ab\x00\x00cd.ef\x00" {
+		t.Fatalf("unexpected key %q", sl)
+	}
+	var a, b, c string
+	kg := NewKeyGetBuffer(sl)
+	kg.StrPad(&a, 4, 0)
+	kg.StrPad(&b, 3, '.')
+	kg.StrPad(&c, 3, 0)
+	if err = kg.Done(); err != nil {
+		t.Fatal(err)
+	}
+	if a != "ab" || b != "cd" || c != "ef" {
+		t.Fatalf("unexpected fields %q, %q, %q", a, b, c)
+	}
+}
+
+// Ensure that nanosecond time keys are ordered and restored exactly
+func TestKeyBuffer_TimeNano(t *testing.T) {
+	var prev []byte
+	for _, tm := range []time.Time{
+		timeTest.Add(-time.Second),
+		timeTest.Add(-1),
+		timeTest,
+		timeTest.Add(1),
+		timeTest.Add(999999999),
+		timeTest.Add(time.Second),
+	} {
+		var kb KeyBuffer
+		var restored time.Time
+		kb.TimeNano(tm)
+		sl, err := kb.Data()
+		if err == nil {
+			kg := NewKeyGetBuffer(sl)
+			kg.TimeNano(&restored)
+			err = kg.Done()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !restored.Equal(tm) {
+			t.Fatalf("expecting %s, got %s", tm, restored)
+		}
+		if prev != nil && bytes.Compare(prev, sl) >= 0 {
+			t.Fatalf("key for %s does not sort after its predecessor", tm)
+		}
+		prev = sl
+	}
+}
+
+// Ensure that reverse time keys sort latest first and are restored
+func TestKeyBuffer_ReverseTime(t *testing.T) {
+	storetest.KeyOrder(t, 5000, func(r *rand.Rand) interface{} {
+		return time.Unix(r.Int63n(1<<40)-1<<39, 0)
+	}, func(val interface{}) ([]byte, error) {
+		var kb KeyBuffer
+		var restored time.Time
+		kb.ReverseTime(val.(time.Time))
+		key, err := kb.Data()
+		if err == nil {
+			kg := NewKeyGetBuffer(key)
+			kg.ReverseTime(&restored)
+			err = kg.Done()
+		}
+		if err == nil && !restored.Equal(val.(time.Time)) {
+			err = fmt.Errorf("%s restored as %s", val, restored)
+		}
+		return key, err
+	}, func(a, b interface{}) int {
+		ta, tb := a.(time.Time), b.(time.Time)
+		return cmpInt(tb.Before(ta), tb.After(ta))
+	})
+}
+
+// Ensure that absent optional values are placed first or last as requested
+func TestKeyBuffer_Nulls(t *testing.T) {
+	build := func(val *uint32, last bool) []byte {
+		var kb KeyBuffer
+		if last {
+			kb.NullsLast(val != nil)
+		} else {
+			kb.NullsFirst(val != nil)
+		}
+		if val != nil {
+			kb.Uint32(*val)
+		}
+		kb.Uint8(9)
+		key, err := kb.Data()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var present bool
+		var u uint32
+		kg := NewKeyGetBuffer(key)
+		if last {
+			kg.NullsLast(&present)
+		} else {
+			kg.NullsFirst(&present)
+		}
+		if present {
+			kg.Uint32(&u)
+		}
+		kg.Uint8(new(uint8))
+		if err = kg.Done(); err != nil {
+			t.Fatal(err)
+		}
+		if present != (val != nil) || (present && u != *val) {
+			t.Fatal("optional value not restored")
+		}
+		return key
+	}
+	zero, max := uint32(0), uint32(math.MaxUint32)
+	if bytes.Compare(build(nil, false), build(&zero, false)) >= 0 {
+		t.Fatal("absent value does not sort first")
+	}
+	if bytes.Compare(build(nil, true), build(&max, true)) <= 0 {
+		t.Fatal("absent value does not sort last")
+	}
+	kg := NewKeyGetBuffer([]byte{2})
+	kg.NullsFirst(new(bool))
+	if kg.Error() == nil {
+		t.Fatal("invalid presence marker not reported")
+	}
+}
+
+// Ensure that time bucket prefixes group times by interval
+func TestTimeBucketKey(t *testing.T) {
+	day := 24 * time.Hour
+	prefix, key := TimeBucketKey(timeTest.Add(3*time.Hour), day)
+	if !bytes.HasPrefix(key, prefix) || len(key) != 20 {
+		t.Fatalf("unexpected key %x for prefix %x", key, prefix)
+	}
+	var bucket, tm time.Time
+	kg := NewKeyGetBuffer(key)
+	kg.Time(&bucket)
+	kg.TimeNano(&tm)
+	if err := kg.Done(); err != nil {
+		t.Fatal(err)
+	}
+	if !bucket.Equal(timeTest.Add(-12*time.Hour)) || !tm.Equal(timeTest.Add(3*time.Hour)) {
+		t.Fatalf("unexpected bucket %s and time %s", bucket.UTC(), tm.UTC())
+	}
+	other, _ := TimeBucketKey(timeTest.Add(-12*time.Hour), day)
+	next, _ := TimeBucketKey(timeTest.Add(12*time.Hour), day)
+	if !bytes.Equal(prefix, other) || bytes.Equal(prefix, next) {
+		t.Fatal("times not grouped by day")
+	}
+}
+
+// Ensure that variable-length integer keys preserve order and are restored
+func TestKeyBuffer_Varint(t *testing.T) {
+	storetest.KeyOrder(t, 5000, func(r *rand.Rand) interface{} {
+		return r.Int63()>>uint(r.Intn(64)) - r.Int63()>>uint(r.Intn(64))
+	}, func(val interface{}) ([]byte, error) {
+		var kb KeyBuffer
+		kb.Varint(val.(int64))
+		return kb.Data()
+	}, func(a, b interface{}) int {
+		return cmpInt(a.(int64) < b.(int64), a.(int64) > b.(int64))
+	})
+	storetest.KeyOrder(t, 5000, func(r *rand.Rand) interface{} {
+		return r.Uint64() >> uint(r.Intn(65))
+	}, func(val interface{}) ([]byte, error) {
+		var kb KeyBuffer
+		kb.Uvarint(val.(uint64))
+		return kb.Data()
+	}, func(a, b interface{}) int {
+		return cmpInt(a.(uint64) < b.(uint64), a.(uint64) > b.(uint64))
+	})
+	var kb KeyBuffer
+	ilist := []int64{math.MinInt64, -256, -255, -1, 0, 1, 255, 256, math.MaxInt64}
+	ulist := []uint64{0, 1, 255, 256, math.MaxUint64}
+	for _, v := range ilist {
+		kb.Varint(v)
+	}
+	for _, v := range ulist {
+		kb.Uvarint(v)
+	}
+	sl, err := kb.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kg := NewKeyGetBuffer(sl)
+	for _, v := range ilist {
+		var r int64
+		kg.Varint(&r)
+		if kg.Error() == nil && r != v {
+			t.Fatalf("expecting %d, got %d", v, r)
+		}
+	}
+	for _, v := range ulist {
+		var r uint64
+		kg.Uvarint(&r)
+		if kg.Error() == nil && r != v {
+			t.Fatalf("expecting %d, got %d", v, r)
+		}
+	}
+	if err = kg.Done(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// cmpInt returns -1 if lt is true, 1 if gt is true and 0 otherwise.
+func cmpInt(lt, gt bool) int {
+	switch {
+	case lt:
+		return -1
+	case gt:
+		return 1
+	}
+	return 0
+}
+
+// Ensure that variable-length string key segments sort like their values
+func TestKeyBuffer_VarStr(t *testing.T) {
+	list := []string{"", "\x00", "\x00\x00", "\x00\x01", "a", "a\x00", "a\x00b",
+		"a\x01", "ab", "abc", "b"}
+	var prev []byte
+	for _, str := range list {
+		var kb KeyBuffer
+		kb.VarStr(str)
+		kb.Uint8(0)
+		sl, err := kb.Data()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prev != nil && bytes.Compare(prev, sl) >= 0 {
+			t.Fatalf("key for %q does not sort after its predecessor", str)
+		}
+		prev = sl
+	}
+}
+
+// ExampleKeyBuffer_SetCollator demonstrates the use of a collator to order
+// string key segments without regard to case.
+func ExampleKeyBuffer_SetCollator() {
+	var keyList []string
+	mp := make(map[string]string)
+	for _, str := range []string{"banana", "Cherry", "apple", "Banana"} {
+		var kb KeyBuffer
+		kb.SetCollator(func(s string) []byte { return []byte(strings.ToLower(s)) })
+		kb.Str(str, 8)
+		kb.SetCollator(nil)
+		kb.Str(str, 8)
+		sl, err := kb.Data()
+		if err == nil {
+			keyList = append(keyList, string(sl))
+			mp[string(sl)] = str
+		}
+	}
+	sort.Strings(keyList)
+	for _, key := range keyList {
+		fmt.Println(mp[key])
+	}
+	// Output:
+	// apple
+	// Banana
+	// banana
+	// Cherry
+}
+
+// Ensure that keys can be appended to a reused slice without allocation
+func TestKeyBuffer_AppendTo(t *testing.T) {
+	var kb KeyBuffer
+	var dst []byte
+	var err error
+	build := func(j uint32) {
+		kb.Reset()
+		kb.Uint32(j)
+		kb.Str("ab", 3)
+		dst, err = kb.AppendTo(dst[:0])
+	}
+	build(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dst, []byte{0, 0, 0, 7, 'a', 'b', ' '}) {
+		t.Fatalf("unexpected key %x", dst)
+	}
+	allocs := testing.AllocsPerRun(100, func() { build(9) })
+	if allocs > 0 {
+		t.Fatalf("expecting no allocations, got %.1f", allocs)
+	}
+	kb.SetError(errTest)
+	if sl, err := kb.AppendTo(dst); err == nil || len(sl) != len(dst) {
+		t.Fatal("KeyBuffer error not reported by AppendTo")
+	}
+	kb.Reset()
+	if sl, err := kb.Data(); err != nil || len(sl) != 0 {
+		t.Fatal("Reset did not clear key buffer")
+	}
+}
+
+// Ensure that a reset put buffer can be reused without allocation
+func TestPutBuffer_Reset(t *testing.T) {
+	var put PutBuffer
+	put.Grow(64)
+	pack := func(j uint32) {
+		put.Reset()
+		put.Uint32(j)
+		put.Str("abc")
+	}
+	allocs := testing.AllocsPerRun(100, func() { pack(300) })
+	if allocs > 0 {
+		t.Fatalf("expecting no allocations, got %.1f", allocs)
+	}
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0xac, 0x02, 3, 'a', 'b', 'c'}) {
+		t.Fatalf("unexpected record %x", data)
+	}
+	put.SetError(errTest)
+	put.Reset()
+	if data, err = put.Data(); err != nil || len(data) != 0 {
+		t.Fatal("Reset did not clear put buffer")
+	}
+}
+
+// Ensure that AppendTo extends a caller-owned slice
+func TestPutBuffer_AppendTo(t *testing.T) {
+	var put PutBuffer
+	dst := []byte{0xff}
+	var err error
+	pack := func(j uint32) {
+		put.Reset()
+		put.Uint32(j)
+		dst, err = put.AppendTo(dst[:1])
+	}
+	pack(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dst, []byte{0xff, 5}) {
+		t.Fatalf("unexpected output %x", dst)
+	}
+	allocs := testing.AllocsPerRun(100, func() { pack(9) })
+	if allocs > 0 {
+		t.Fatalf("expecting no allocations, got %.1f", allocs)
+	}
+	put.SetError(errTest)
+	if sl, err := put.AppendTo(dst); err == nil || len(sl) != len(dst) {
+		t.Fatal("PutBuffer error not reported by AppendTo")
+	}
+}
+
+// Ensure that records can be piped through io.Writer and io.Reader values
+func TestPutBuffer_WriteTo(t *testing.T) {
+	var put PutBuffer
+	put.Str("hello")
+	put.Uint64(1 << 40)
+	var w bytes.Buffer
+	n, err := put.WriteTo(&w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(put.Len()) || w.Len() != put.Len() {
+		t.Fatalf("expecting %d bytes written, got %d", put.Len(), n)
+	}
+	var get GetBuffer
+	get.SetError(errTest)
+	if n, err = get.ReadFrom(iotest.OneByteReader(&w)); err != nil || n != int64(put.Len()) {
+		t.Fatalf("record not read: %v", err)
+	}
+	var str string
+	var val uint64
+	get.Str(&str)
+	get.Uint64(&val)
+	if err = get.Done(); err != nil || str != "hello" || val != 1<<40 {
+		t.Fatalf("record not restored: %v", err)
+	}
+	if _, err = get.ReadFrom(iotest.ErrReader(errTest)); err != errTest {
+		t.Fatal("read error not returned")
+	}
+	if get.Error() == nil {
+		t.Fatal("read error not assigned to get buffer")
+	}
+	put.SetError(errTest)
+	if n, err = put.WriteTo(&w); err == nil || n != 0 {
+		t.Fatal("PutBuffer error not reported by WriteTo")
+	}
+}
+
+// Ensure that dry run mode reports the packed size without storing bytes
+func TestSize(t *testing.T) {
+	var rec all
+	recPopulate(&rec)
+	data, err := storeRecToBuf(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := Size(func(put *PutBuffer) { storePack(put, rec) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(data) {
+		t.Fatalf("expecting size %d, got %d", len(data), n)
+	}
+	var put PutBuffer
+	put.Uint8(1)
+	put.SetDryRun(true)
+	put.Str("abc")
+	put.Uint64(1 << 35)
+	if data, err = put.Data(); err != nil || len(data) != 1 || put.Len() != 11 {
+		t.Fatalf("unexpected dry run result: %x, length %d", data, put.Len())
+	}
+	if _, err = Size(func(put *PutBuffer) { put.SetError(errTest) }); err != errTest {
+		t.Fatal("packing error not reported by Size")
+	}
+}
+
+// Ensure that raw bytes are packed without a prefix and unpacked by length
+func TestPutBuffer_Raw(t *testing.T) {
+	var put PutBuffer
+	put.Raw([]byte("HDR1"))
+	put.Uint8(9)
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "HDR1\x09" {
+		t.Fatalf("unexpected record %x", data)
+	}
+	var hdr []byte
+	var b uint8
+	get := NewGetBuffer(data)
+	get.Raw(4, &hdr)
+	get.Uint8(&b)
+	if err = get.Done(); err != nil || string(hdr) != "HDR1" || b != 9 {
+		t.Fatalf("raw bytes not unpacked: %v", err)
+	}
+	get.Reset(data)
+	get.Raw(6, &hdr)
+	if !errors.Is(get.Error(), ErrShortBuffer) {
+		t.Fatalf("short raw bytes not reported: %v", get.Error())
+	}
+	get.Reset(data)
+	get.Raw(-1, &hdr)
+	if !errors.Is(get.Error(), ErrValueRange) {
+		t.Fatalf("negative length not reported: %v", get.Error())
+	}
+}
+
+// Ensure that a reserved field can be patched after later fields are packed
+func TestPutBuffer_Reserve(t *testing.T) {
+	var put PutBuffer
+	put.Uint8(1)
+	slot := put.Reserve(2)
+	start := put.Len()
+	put.Str("nested")
+	put.Uint64(1 << 20)
+	put.Patch(slot, uint64(put.Len()-start))
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data[:3], []byte{1, 0, 10}) {
+		t.Fatalf("unexpected record %x", data)
+	}
+	var b uint8
+	var n uint64
+	var str string
+	get := NewGetBuffer(data)
+	get.Uint8(&b)
+	get.Reserved(2, &n)
+	sub := get.Pos()
+	get.Str(&str)
+	get.skip(uint64(sub) + n - uint64(get.Pos()))
+	if err = get.Done(); err != nil || n != 10 || str != "nested" {
+		t.Fatalf("reserved field not unpacked: %v", err)
+	}
+	put.Patch(slot, 1<<16)
+	if !errors.Is(put.Error(), ErrValueRange) {
+		t.Fatal("oversized patch value not reported")
+	}
+	put.Reset()
+	put.Patch(slot, 1)
+	if put.Error() == nil {
+		t.Fatal("invalid slot not reported")
+	}
+	put.Reset()
+	put.Reserve(9)
+	if !errors.Is(put.Error(), ErrValueRange) {
+		t.Fatal("invalid width not reported")
+	}
+	size, err := Size(func(put *PutBuffer) {
+		put.Patch(put.Reserve(4), 7)
+	})
+	if err != nil || size != 4 {
+		t.Fatalf("expecting dry run size 4, got %d (%v)", size, err)
+	}
+	put.Reset()
+	put.SetDryRun(true)
+	slot = put.Reserve(4)
+	put.SetDryRun(false)
+	put.Patch(slot, 7)
+	if put.Error() == nil {
+		t.Fatal("slot reserved in dry run mode not reported")
+	}
+}
+
+// Ensure that fixed-width integers occupy a constant number of bytes
+func TestPutBuffer_Uint64Fixed(t *testing.T) {
+	var put PutBuffer
+	put.Uint64Fixed(1)
+	put.Uint32Fixed(math.MaxUint32)
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0, 0, 0, 0, 0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff}) {
+		t.Fatalf("unexpected record %x", data)
+	}
+	var a uint64
+	var b uint32
+	get := NewGetBuffer(data)
+	get.Uint64Fixed(&a)
+	get.Uint32Fixed(&b)
+	if err = get.Done(); err != nil || a != 1 || b != math.MaxUint32 {
+		t.Fatalf("fixed-width values not unpacked: %v", err)
+	}
+	get.Reset(data[:10])
+	get.Uint64Fixed(&a)
+	get.Uint32Fixed(&b)
+	if !errors.Is(get.Error(), ErrShortBuffer) {
+		t.Fatalf("short fixed-width value not reported: %v", get.Error())
+	}
+}
+
+// Ensure that packing can be rolled back to a mark
+func TestPutBuffer_Rollback(t *testing.T) {
+	var put PutBuffer
+	put.Uint8(1)
+	m := put.Mark()
+	put.Str("optional")
+	put.SetError(errTest)
+	put.Rollback(m)
+	put.Uint8(2)
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{1, 2}) {
+		t.Fatalf("unexpected record %x", data)
+	}
+	put.Reset()
+	put.Rollback(m)
+	if put.Error() == nil {
+		t.Fatal("invalid mark not reported")
+	}
+}
+
+// Ensure that a tee writer receives exactly the packed bytes
+func TestPutBuffer_SetTee(t *testing.T) {
+	var put PutBuffer
+	var w bytes.Buffer
+	var rec all
+	recPopulate(&rec)
+	put.Uint8(7)
+	put.SetTee(&w)
+	storePack(&put, rec)
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w.Bytes(), data) {
+		t.Fatalf("tee received %x, expecting %x", w.Bytes(), data)
+	}
+	m := put.Mark()
+	put.Str("abc")
+	put.Rollback(m)
+	if put.Error() == nil {
+		t.Fatal("rollback of copied bytes not reported")
+	}
+	put.Reset()
+	w.Reset()
+	put.Patch(put.Reserve(2), 1)
+	if put.Error() == nil {
+		t.Fatal("patch of copied bytes not reported")
+	}
+	put.Reset()
+	put.Uint8(1)
+	put.SetTee(errWriter{})
+	if put.Error() != errTest {
+		t.Fatal("tee write error not reported")
+	}
+}
+
+// errWriter is an io.Writer that always fails with errTest.
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errTest
+}
+
+// Ensure that a clone shares its prefix but not subsequent packing
+func TestPutBuffer_Clone(t *testing.T) {
+	var put PutBuffer
+	put.Str("hdr")
+	a := put.Clone()
+	b := put.Clone()
+	a.Uint8(1)
+	b.Uint8(2)
+	put.Uint8(3)
+	for j, p := range []*PutBuffer{a, b, &put} {
+		data, err := p.Data()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, []byte{3, 'h', 'd', 'r', byte(j + 1)}) {
+			t.Fatalf("unexpected record %x", data)
+		}
+	}
+	put.SetError(errTest)
+	if put.Clone().Error() != errTest {
+		t.Fatal("error not cloned")
+	}
+}
+
+// Ensure that Data returns a copy and DataUnsafe an alias
+func TestPutBuffer_DataUnsafe(t *testing.T) {
+	put := AcquirePut()
+	put.Str("first")
+	safe, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alias, err := put.DataUnsafe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	put.Reset()
+	put.Str("other")
+	if string(safe[1:]) != "first" {
+		t.Fatalf("copy altered by reuse: %q", safe)
+	}
+	if string(alias[1:]) != "other" {
+		t.Fatalf("expecting alias of reused memory, got %q", alias)
+	}
+	put.SetError(errTest)
+	if sl, err := put.DataUnsafe(); err == nil || sl != nil {
+		t.Fatal("error not reported by DataUnsafe")
+	}
+	ReleasePut(put)
+}
+
+// Ensure that the text form of keys preserves order and is restored
+func TestKeyText(t *testing.T) {
+	storetest.KeyOrder(t, 5000, func(r *rand.Rand) interface{} {
+		sl := make([]byte, r.Intn(12))
+		r.Read(sl)
+		return sl
+	}, func(val interface{}) ([]byte, error) {
+		str := KeyText(val.([]byte))
+		sl, err := ParseKeyText(str)
+		if err == nil && !bytes.Equal(sl, val.([]byte)) {
+			err = fmt.Errorf("key %x restored as %x", val, sl)
+		}
+		return []byte(str), err
+	}, func(a, b interface{}) int {
+		return bytes.Compare(a.([]byte), b.([]byte))
+	})
+	if _, err := ParseKeyText("not base32"); err == nil {
+		t.Fatal("invalid key text not reported")
 	}
 }
 
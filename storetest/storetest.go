@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package storetest provides utilities for testing applications that build
+// keys with the store package.
+package storetest
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// KeyOrder verifies that a key-building scheme preserves the logical order of
+// the values it encodes. It generates n random pairs of values with gen,
+// builds a key for each value with key, and compares the byte order of the
+// keys with the order reported by cmp. cmp returns a negative number, zero or
+// a positive number when a is less than, equal to or greater than b
+// respectively. The first disagreement, including the seed that produced it,
+// is reported as a fatal error through tb.
+//
+// This can catch ordering bugs, for example with floating point values or
+// case-sensitive strings, before keys built with the scheme are written to an
+// index.
+func KeyOrder(tb testing.TB, n int, gen func(r *rand.Rand) interface{},
+	key func(val interface{}) ([]byte, error), cmp func(a, b interface{}) int) {
+	tb.Helper()
+	seed := time.Now().UnixNano()
+	r := rand.New(rand.NewSource(seed))
+	for j := 0; j < n; j++ {
+		a, b := gen(r), gen(r)
+		ka, err := key(a)
+		if err != nil {
+			tb.Fatalf("building key for %v: %s", a, err)
+			return
+		}
+		kb, err := key(b)
+		if err != nil {
+			tb.Fatalf("building key for %v: %s", b, err)
+			return
+		}
+		if sign(bytes.Compare(ka, kb)) != sign(cmp(a, b)) {
+			tb.Fatalf("key order of %v (%x) and %v (%x) does not match value order (seed %d)",
+				a, ka, b, kb, seed)
+			return
+		}
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package storetest
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/piniondb/store"
+)
+
+type failTB struct {
+	testing.TB
+	failed bool
+}
+
+func (tb *failTB) Fatalf(format string, args ...interface{}) {
+	tb.failed = true
+}
+
+func genInt64(r *rand.Rand) interface{} {
+	return r.Int63() - r.Int63()
+}
+
+func cmpInt64(a, b interface{}) int {
+	x, y := a.(int64), b.(int64)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// Ensure that the store package's signed integer keys preserve order
+func TestKeyOrder(t *testing.T) {
+	KeyOrder(t, 1000, genInt64, func(val interface{}) ([]byte, error) {
+		return store.KeyInt64(val.(int64)), nil
+	}, cmpInt64)
+}
+
+// Ensure that a scheme that does not preserve order is reported
+func TestKeyOrder_Failure(t *testing.T) {
+	tb := &failTB{TB: t}
+	KeyOrder(tb, 1000, genInt64, func(val interface{}) ([]byte, error) {
+		sl := make([]byte, 8)
+		binary.LittleEndian.PutUint64(sl, uint64(val.(int64)))
+		return sl, nil
+	}, cmpInt64)
+	if !tb.failed {
+		t.Fatal("KeyOrder did not report misordered keys")
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"math"
+)
+
+// WireType identifies how a field value is packed in the field-tagged mode
+// provided by PutBuffer.FieldTag and GetBuffer.NextField. It determines how a
+// reader that does not recognize a field can skip it.
+type WireType uint8
+
+// Wire types of tagged fields, named after the put methods that pack them.
+const (
+	// WireVarint fields are packed with Uint64, Int64, Uint32, Int32, Uint16,
+	// Int16 or Time.
+	WireVarint WireType = iota
+	// WireBytes fields are packed with Str or Bytes. A nested record, list or
+	// other composite value is packed with a separate put buffer whose output
+	// is packed with Bytes and unpacked with GetBuffer.Sub.
+	WireBytes
+	// WireFixed8 fields are packed with Uint8 or Int8.
+	WireFixed8
+	// WireFixed32 fields are packed with Uint32Fixed.
+	WireFixed32
+	// WireFixed64 fields are packed with Uint64Fixed.
+	WireFixed64
+)
+
+// FieldTag packs the header of a field in field-tagged mode. The header
+// identifies the field by num, which must be at least 1, and the way its
+// value, packed immediately afterward, is encoded. Unlike the default
+// positional layout, in which values must be unpacked in the order they were
+// packed, tagged fields can be read in any order, and fields unknown to the
+// reader can be skipped, so fields can be added, removed or reordered
+// without breaking older or newer readers. The price is at least one extra
+// byte per field.
+func (put *PutBuffer) FieldTag(num uint32, wire WireType) {
+	if put.err == nil {
+		if num == 0 || wire > WireFixed64 {
+			put.err = categoryErrorf(ErrValueRange, "invalid field tag %d with wire type %d", num, wire)
+			return
+		}
+		put.vluEncode(uint64(num)<<3 | uint64(wire))
+	}
+}
+
+// NextField unpacks the header of the next tagged field into num and wire,
+// returning true if a field is available. It returns false when no content
+// remains or an error has occurred. A typical reader loops over the fields,
+// unpacking recognized ones with the method that corresponds to wire and
+// passing the others to SkipField:
+//
+//	for get.NextField(&num, &wire) {
+//		switch num {
+//		case 1:
+//			get.Str(&rec.Name)
+//		default:
+//			get.SkipField(wire)
+//		}
+//	}
+//	err := get.Done()
+func (get *GetBuffer) NextField(num *uint32, wire *WireType) bool {
+	if get.err != nil || !get.more() {
+		return false
+	}
+	var u uint64
+	get.Uint64(&u)
+	if get.err == nil {
+		switch {
+		case u>>3 == 0 || u>>3 > math.MaxUint32 || WireType(u&7) > WireFixed64:
+			get.err = categoryErrorf(ErrValueRange, "invalid field tag %d with wire type %d", u>>3, u&7)
+		default:
+			*num = uint32(u >> 3)
+			*wire = WireType(u & 7)
+			return true
+		}
+	}
+	return false
+}
+
+// SkipField discards the value of a tagged field with the specified wire
+// type, typically one returned by NextField for a field the reader does not
+// recognize.
+func (get *GetBuffer) SkipField(wire WireType) {
+	var u uint64
+	switch wire {
+	case WireVarint:
+		get.Uint64(&u)
+	case WireBytes:
+		get.Uint64(&u)
+		get.skip(u)
+	case WireFixed8:
+		get.skip(1)
+	case WireFixed32:
+		get.skip(4)
+	case WireFixed64:
+		get.skip(8)
+	default:
+		if get.err == nil {
+			get.err = categoryErrorf(ErrValueRange, "invalid wire type %d", wire)
+		}
+	}
+}
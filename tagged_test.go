@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+// Ensure that tagged fields can be reordered and unknown fields skipped
+func TestPutBuffer_FieldTag(t *testing.T) {
+	var nested PutBuffer
+	nested.Uint32(7)
+	inner, err := nested.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var put PutBuffer
+	put.FieldTag(2, WireBytes)
+	put.Str("name")
+	put.FieldTag(9, WireBytes)
+	put.Bytes(inner)
+	put.FieldTag(10, WireFixed8)
+	put.Int8(-1)
+	put.FieldTag(11, WireFixed32)
+	put.Uint32Fixed(1)
+	put.FieldTag(12, WireFixed64)
+	put.Uint64Fixed(1)
+	put.FieldTag(1, WireVarint)
+	put.Uint64(1 << 40)
+	put.FieldTag(13, WireVarint)
+	put.Int16(-300)
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var num uint32
+	var wire WireType
+	var a uint64
+	var str string
+	var seen []uint32
+	get := NewGetBuffer(data)
+	for get.NextField(&num, &wire) {
+		seen = append(seen, num)
+		switch num {
+		case 1:
+			get.Uint64(&a)
+		case 2:
+			get.Str(&str)
+		default:
+			get.SkipField(wire)
+		}
+	}
+	if err = get.Done(); err != nil {
+		t.Fatal(err)
+	}
+	if a != 1<<40 || str != "name" || len(seen) != 7 {
+		t.Fatalf("unexpected fields %v: %d %q", seen, a, str)
+	}
+	put.Reset()
+	put.FieldTag(0, WireVarint)
+	if !errors.Is(put.Error(), ErrValueRange) {
+		t.Fatal("invalid field number not reported")
+	}
+	get.Reset([]byte{1<<3 | 7})
+	if get.NextField(&num, &wire) || !errors.Is(get.Error(), ErrValueRange) {
+		t.Fatal("invalid wire type not reported")
+	}
+	get.Reset([]byte{1<<3 | byte(WireBytes), 5, 'a'})
+	if !get.NextField(&num, &wire) {
+		t.Fatal(get.Error())
+	}
+	get.SkipField(wire)
+	if !errors.Is(get.Error(), ErrShortBuffer) {
+		t.Fatalf("short field not reported: %v", get.Error())
+	}
+}
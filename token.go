@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+var errTokenInvalid = errors.New("token is malformed or has been altered")
+
+// tokenMACLen is the number of bytes of the HMAC-SHA256 value that are
+// included in a token.
+const tokenMACLen = 16
+
+// TokenSealer converts keys to opaque, URL-safe tokens that can be handed to
+// clients, for example as pagination tokens, and converts them back. Each
+// token carries an HMAC-SHA256 authentication code computed with a secret,
+// so a token that has been altered or fabricated by a client is rejected.
+// Note that the key itself is not encrypted and can be read by anyone who
+// holds the token.
+type TokenSealer struct {
+	secret []byte
+}
+
+// NewTokenSealer returns a sealer that authenticates tokens with secret. The
+// secret should be at least 32 random bytes and must be kept private.
+func NewTokenSealer(secret []byte) *TokenSealer {
+	return &TokenSealer{secret: append([]byte(nil), secret...)}
+}
+
+func (ts *TokenSealer) mac(key []byte) []byte {
+	h := hmac.New(sha256.New, ts.secret)
+	h.Write(key)
+	return h.Sum(nil)[:tokenMACLen]
+}
+
+// Seal returns a token that holds key.
+func (ts *TokenSealer) Seal(key []byte) string {
+	sl := append(append([]byte(nil), key...), ts.mac(key)...)
+	return base64.RawURLEncoding.EncodeToString(sl)
+}
+
+// Open returns the key held by token. An error is returned if the token was
+// not produced by Seal with the same secret or has been altered.
+func (ts *TokenSealer) Open(token string) ([]byte, error) {
+	sl, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(sl) < tokenMACLen {
+		return nil, errTokenInvalid
+	}
+	key, mac := sl[:len(sl)-tokenMACLen], sl[len(sl)-tokenMACLen:]
+	if !hmac.Equal(mac, ts.mac(key)) {
+		return nil, errTokenInvalid
+	}
+	return key, nil
+}
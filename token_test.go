@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Ensure that sealed keys are restored and that altered tokens are rejected
+func TestTokenSealer(t *testing.T) {
+	ts := NewTokenSealer([]byte("0123456789abcdef0123456789abcdef"))
+	key, err := Key(uint32(42), "resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := ts.Seal(key)
+	restored, err := ts.Open(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, restored) {
+		t.Fatalf("expecting %x, got %x", key, restored)
+	}
+	altered := []byte(token)
+	if altered[2] == 'A' {
+		altered[2] = 'B'
+	} else {
+		altered[2] = 'A'
+	}
+	for _, str := range []string{string(altered), token[:len(token)-1], "", "!!"} {
+		if _, err = ts.Open(str); err == nil {
+			t.Fatalf("altered token %q not rejected", str)
+		}
+	}
+	if _, err = NewTokenSealer([]byte("other secret")).Open(token); err == nil {
+		t.Fatal("token sealed with another secret not rejected")
+	}
+}
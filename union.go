@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Unions maps the tags of a one-of-N union to functions that unpack the
+// corresponding payload types. Each function unpacks the fields that follow
+// the tag and returns the resulting value.
+type Unions map[uint32]func(get *GetBuffer) interface{}
+
+var (
+	unionMu sync.RWMutex
+	unions  = Unions{}
+)
+
+// RegisterUnion associates tag with dec in a package-level registry that is
+// used by GetBuffer.Union when no other dispatch table is given. As with
+// gob.Register, registration normally takes place in an init function. It
+// panics if tag has already been registered.
+func RegisterUnion(tag uint32, dec func(get *GetBuffer) interface{}) {
+	unionMu.Lock()
+	defer unionMu.Unlock()
+	if _, ok := unions[tag]; ok {
+		panic(fmt.Sprintf("store: union tag %d registered twice", tag))
+	}
+	unions[tag] = dec
+}
+
+// Union packs one member of a union: tag identifies the payload type and fn
+// packs the payload's fields into the receiving storage buffer. The member is
+// unpacked with GetBuffer.Union.
+func (put *PutBuffer) Union(tag uint32, fn func(put *PutBuffer)) {
+	put.Uint32(tag)
+	if put.err == nil {
+		fn(put)
+	}
+}
+
+// Union unpacks a member of a union that was packed with PutBuffer.Union and
+// assigns the resulting value to val. The payload is unpacked by the function
+// that dispatch associates with the member's tag; if dispatch is nil, the
+// package-level registry populated by RegisterUnion is used. An unknown tag
+// sets the internal error.
+func (get *GetBuffer) Union(dispatch Unions, val *interface{}) {
+	var tag uint32
+	get.Uint32(&tag)
+	if get.err != nil {
+		return
+	}
+	dec, ok := dispatch[tag]
+	if dispatch == nil {
+		unionMu.RLock()
+		dec, ok = unions[tag]
+		unionMu.RUnlock()
+	}
+	if !ok {
+		get.err = categoryErrorf(ErrValueRange, "union tag %d is not registered", tag)
+		return
+	}
+	v := dec(get)
+	if get.err == nil {
+		*val = v
+	}
+}
+
+// unionRecord unpacks rec, which holds a single union member, using
+// dispatch.
+func unionRecord(rec []byte, dispatch Unions) (val interface{}, err error) {
+	get := &GetBuffer{data: rec}
+	get.Union(dispatch, &val)
+	if err = get.Done(); err != nil {
+		val = nil
+	}
+	return
+}
+
+// Union writes a union member, packed as by PutBuffer.Union, as the next
+// record of the stream. This permits records of different types, such as
+// events, snapshots and heartbeats, to share one stream; a reader recovers
+// each one with RecordReader.Union or RecordDecoder.Union.
+func (rw *RecordWriter) Union(tag uint32, fn func(put *PutBuffer)) error {
+	if rw.err == nil {
+		put := AcquirePut()
+		put.Union(tag, fn)
+		rw.Put(put)
+		ReleasePut(put)
+	}
+	return rw.err
+}
+
+// Union unpacks the current record, which was written by RecordWriter.Union,
+// with the function that dispatch associates with its tag, or with the
+// package-level registry if dispatch is nil. An error that matches
+// ErrValueRange is returned for a tag that is not registered. Since record
+// framing is unaffected, the caller may skip such a record, for example one
+// of a type introduced by a newer writer, and continue with Next.
+func (rr *RecordReader) Union(dispatch Unions) (interface{}, error) {
+	return unionRecord(rr.rec, dispatch)
+}
+
+// Union unpacks the current record, which was written by RecordWriter.Union,
+// in the manner of RecordReader.Union.
+func (d *RecordDecoder) Union(dispatch Unions) (interface{}, error) {
+	return unionRecord(d.rec, dispatch)
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type unionCircle struct {
+	radius uint32
+}
+
+type unionRect struct {
+	w, h uint32
+}
+
+const (
+	unionTagCircle = iota + 1
+	unionTagRect
+)
+
+var unionShapes = Unions{
+	unionTagCircle: func(get *GetBuffer) interface{} {
+		var c unionCircle
+		get.Uint32(&c.radius)
+		return c
+	},
+	unionTagRect: func(get *GetBuffer) interface{} {
+		var r unionRect
+		get.Uint32(&r.w)
+		get.Uint32(&r.h)
+		return r
+	},
+}
+
+// ExampleGetBuffer_Union demonstrates a record that holds one of several
+// payload types.
+func ExampleGetBuffer_Union() {
+	var put PutBuffer
+	put.Union(unionTagRect, func(put *PutBuffer) {
+		put.Uint32(3)
+		put.Uint32(4)
+	})
+	put.Union(unionTagCircle, func(put *PutBuffer) {
+		put.Uint32(5)
+	})
+	data, err := put.Data()
+	if err == nil {
+		get := NewGetBuffer(data)
+		for j := 0; j < 2; j++ {
+			var shape interface{}
+			get.Union(unionShapes, &shape)
+			switch s := shape.(type) {
+			case unionRect:
+				fmt.Printf("rectangle %dx%d\n", s.w, s.h)
+			case unionCircle:
+				fmt.Printf("circle of radius %d\n", s.radius)
+			}
+		}
+		err = get.Done()
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// rectangle 3x4
+	// circle of radius 5
+}
+
+// Ensure that the package-level registry is used and unknown tags reported
+func TestRegisterUnion(t *testing.T) {
+	RegisterUnion(1000, func(get *GetBuffer) interface{} {
+		var str string
+		get.Str(&str)
+		return str
+	})
+	var put PutBuffer
+	put.Union(1000, func(put *PutBuffer) { put.Str("text") })
+	put.Union(1001, func(put *PutBuffer) {})
+	data, err := put.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var val interface{}
+	get := NewGetBuffer(data)
+	get.Union(nil, &val)
+	if get.Error() != nil || val != "text" {
+		t.Fatalf("registered union not unpacked: %v", get.Error())
+	}
+	get.Union(nil, &val)
+	if !errors.Is(get.Error(), ErrValueRange) {
+		t.Fatal("unknown union tag not reported")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("duplicate registration did not panic")
+		}
+	}()
+	RegisterUnion(1000, nil)
+}
+
+func TestRecordReader_Union(t *testing.T) {
+	var b bytes.Buffer
+	rw := NewRecordWriter(&b)
+	rw.Union(unionTagCircle, func(put *PutBuffer) { put.Uint32(5) })
+	rw.Union(99, func(put *PutBuffer) { put.Str("from a newer writer") })
+	rw.Union(unionTagRect, func(put *PutBuffer) {
+		put.Uint32(3)
+		put.Uint32(4)
+	})
+	if err := rw.Union(unionTagCircle, func(put *PutBuffer) { put.SetError(errTest) }); err != errTest {
+		t.Fatalf("expecting test error, got %v", err)
+	}
+	stream := b.Bytes()
+	var list []interface{}
+	rr := NewRecordReader(bytes.NewReader(stream))
+	for rr.Next() {
+		val, err := rr.Union(unionShapes)
+		if errors.Is(err, ErrValueRange) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, val)
+	}
+	if err := rr.Done(); err != nil || len(list) != 2 ||
+		list[0] != (unionCircle{5}) || list[1] != (unionRect{3, 4}) {
+		t.Fatalf("unexpected values %v: %v", list, err)
+	}
+	d := NewRecordDecoder()
+	d.Feed(stream)
+	if !d.Next() {
+		t.Fatal("expecting record")
+	}
+	if val, err := d.Union(unionShapes); err != nil || val != (unionCircle{5}) {
+		t.Fatalf("unexpected value %v: %v", val, err)
+	}
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+)
+
+// Validate performs a trial conversion of a record and discards the result.
+// enc is called to pack the record into an empty put buffer. If packing
+// succeeds and dec is not nil, dec is called to unpack the packed bytes from
+// a get buffer, and the get buffer's Done method is checked. The returned
+// error, which is nil if both steps succeed, identifies the failing step and
+// the number of bytes involved. This permits an application to check a new
+// record version before it is used to write production data.
+func Validate(enc func(put *PutBuffer), dec func(get *GetBuffer)) error {
+	var put PutBuffer
+	enc(&put)
+	data, err := put.DataUnsafe()
+	if err != nil {
+		return fmt.Errorf("packing record: %s", err)
+	}
+	if dec != nil {
+		get := NewGetBuffer(data)
+		dec(get)
+		if err = get.Done(); err != nil {
+			return fmt.Errorf("unpacking record of %d bytes: %s", len(data), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"testing"
+)
+
+// Ensure that failures in either conversion step are reported
+func TestValidate(t *testing.T) {
+	var rec all
+	recPopulate(&rec)
+	enc := func(put *PutBuffer) {
+		data, err := storeRecToBuf(rec)
+		put.Bytes(data)
+		put.SetError(err)
+	}
+	dec := func(get *GetBuffer) {
+		var data []byte
+		get.Bytes(&data)
+		if get.Error() == nil {
+			_, err := storeBufToRec(data)
+			get.SetError(err)
+		}
+	}
+	if err := Validate(enc, dec); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(func(put *PutBuffer) { put.SetError(errTest) }, dec); err == nil {
+		t.Fatal("packing error not reported")
+	}
+	short := func(get *GetBuffer) {
+		var u uint8
+		get.Uint8(&u)
+	}
+	if err := Validate(enc, short); err == nil {
+		t.Fatal("leftover bytes not reported")
+	}
+	if err := Validate(enc, nil); err != nil {
+		t.Fatal(err)
+	}
+}
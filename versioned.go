@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+)
+
+// Versioned packs and unpacks records of type T preceded by a schema version
+// number. Records packed with earlier layouts remain readable: each
+// historical version is registered with AddVersion along with a function
+// that upgrades its value to the next version, and Get applies the chain of
+// upgrades to deliver the current type. Registration must be complete before
+// a Versioned value is used concurrently.
+type Versioned[T any] struct {
+	version uint32
+	enc     func(put *PutBuffer, val T)
+	dec     func(get *GetBuffer, val *T)
+	history map[uint32]versionStep
+}
+
+// versionStep unpacks a historical record version and upgrades the result to
+// the following version.
+type versionStep struct {
+	dec func(get *GetBuffer) interface{}
+	up  func(val interface{}) (interface{}, error)
+}
+
+// NewVersioned returns a Versioned value for which version is the current
+// schema version, enc packs a record of the current layout and dec unpacks
+// it.
+func NewVersioned[T any](version uint32, enc func(put *PutBuffer, val T),
+	dec func(get *GetBuffer, val *T)) *Versioned[T] {
+	return &Versioned[T]{version: version, enc: enc, dec: dec, history: map[uint32]versionStep{}}
+}
+
+// AddVersion registers version, which precedes the current version of vr.
+// dec unpacks a record of type Old that was packed with that version, and up
+// converts it to the type of the next version, Next. The Next type of one
+// version must be the Old type of the following version, and the Next type of
+// the version just before the current one must be T; a mismatch is reported
+// by Get.
+func AddVersion[T, Old, Next any](vr *Versioned[T], version uint32,
+	dec func(get *GetBuffer, val *Old), up func(val Old) (Next, error)) {
+	vr.history[version] = versionStep{
+		dec: func(get *GetBuffer) interface{} {
+			var val Old
+			dec(get, &val)
+			return val
+		},
+		up: func(val interface{}) (interface{}, error) {
+			old, ok := val.(Old)
+			if !ok {
+				var zero Old
+				return nil, fmt.Errorf("upgrade of version %d expects %T, got %T", version, zero, val)
+			}
+			return up(old)
+		},
+	}
+}
+
+// Put returns the packed form of val, preceded by the current version.
+func (vr *Versioned[T]) Put(val T) ([]byte, error) {
+	var put PutBuffer
+	put.Uint32(vr.version)
+	vr.enc(&put, val)
+	return put.DataUnsafe()
+}
+
+// Get unpacks a record that was packed with Put by the current or any
+// registered historical version and returns it as the current type. The
+// second return value is the version with which the record was packed.
+func (vr *Versioned[T]) Get(data []byte) (val T, version uint32, err error) {
+	get := NewGetBuffer(data)
+	get.Uint32(&version)
+	if err = get.Error(); err != nil {
+		return
+	}
+	if version == vr.version {
+		vr.dec(get, &val)
+		err = get.Done()
+		return
+	}
+	step, ok := vr.history[version]
+	if !ok || version > vr.version {
+		return val, version, fmt.Errorf("record version %d is not registered", version)
+	}
+	cur := step.dec(get)
+	if err = get.Done(); err != nil {
+		return
+	}
+	for v := version; v < vr.version; v++ {
+		if step, ok = vr.history[v]; !ok {
+			return val, version, fmt.Errorf("no upgrade registered from version %d", v)
+		}
+		if cur, err = step.up(cur); err != nil {
+			return
+		}
+	}
+	if val, ok = cur.(T); !ok {
+		return val, version, fmt.Errorf("upgrade to version %d produced %T, expecting %T", vr.version, cur, val)
+	}
+	return
+}
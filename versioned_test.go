@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2016 Kurt Jung (Gmail: piniondb)
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+type personV1 struct {
+	name string
+}
+
+type personV2 struct {
+	first, last string
+}
+
+type personV3 struct {
+	first, last string
+	age         uint8
+}
+
+// versionedPerson returns a Versioned value for which personV3 is current.
+func versionedPerson() *Versioned[personV3] {
+	vr := NewVersioned(3, func(put *PutBuffer, p personV3) {
+		put.Str(p.first)
+		put.Str(p.last)
+		put.Uint8(p.age)
+	}, func(get *GetBuffer, p *personV3) {
+		get.Str(&p.first)
+		get.Str(&p.last)
+		get.Uint8(&p.age)
+	})
+	AddVersion(vr, 1, func(get *GetBuffer, p *personV1) {
+		get.Str(&p.name)
+	}, func(p personV1) (personV2, error) {
+		return personV2{first: p.name}, nil
+	})
+	AddVersion(vr, 2, func(get *GetBuffer, p *personV2) {
+		get.Str(&p.first)
+		get.Str(&p.last)
+	}, func(p personV2) (personV3, error) {
+		return personV3{first: p.first, last: p.last}, nil
+	})
+	return vr
+}
+
+// ExampleVersioned demonstrates reading a record written with an earlier
+// layout.
+func ExampleVersioned() {
+	vr := versionedPerson()
+	var put PutBuffer
+	put.Uint32(1)
+	put.Str("Ada")
+	data, err := put.Data()
+	if err == nil {
+		var p personV3
+		var version uint32
+		p, version, err = vr.Get(data)
+		if err == nil {
+			fmt.Printf("version %d: %+v\n", version, p)
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// version 1: {first:Ada last: age:0}
+}
+
+// Ensure that current records round trip and invalid versions are reported
+func TestVersioned(t *testing.T) {
+	vr := versionedPerson()
+	src := personV3{"Grace", "Hopper", 85}
+	data, err := vr.Put(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, version, err := vr.Get(data)
+	if err != nil || version != 3 || dst != src {
+		t.Fatalf("current record not restored: %v", err)
+	}
+	for _, version := range []uint32{0, 4} {
+		var put PutBuffer
+		put.Uint32(version)
+		data, _ = put.Data()
+		if _, _, err = vr.Get(data); err == nil {
+			t.Fatalf("unregistered version %d not reported", version)
+		}
+	}
+	AddVersion(vr, 2, func(get *GetBuffer, p *personV2) {}, func(p personV2) (personV1, error) {
+		return personV1{}, nil
+	})
+	if _, _, err = vr.Get([]byte{2}); err == nil {
+		t.Fatal("mismatched upgrade chain not reported")
+	}
+}